@@ -15,15 +15,20 @@ import (
 )
 
 func SendEvent(invEvent *jsonrpc.InventoryEvent, tagData []tag.Tag) error {
+	return SendTagEvents(invEvent.Params.ControllerId, tagData)
+}
+
+// SendTagEvents sends the tags, whose event is set, to the cloud connector
+func SendTagEvents(controllerID string, tagData []tag.Tag) error {
 	// todo: do we need to handle splitting the data into segments of 250 max?
 	payload := event.DataPayload{
 		SentOn:             helper.UnixMilliNow(),
-		ControllerId:       invEvent.Params.ControllerId,
+		ControllerId:       controllerID,
 		EventSegmentNumber: 1,
 		TotalEventSegments: 1,
 		TagEvent:           tagData,
 	}
 	triggerCloudConnectorEndpoint := config.AppConfig.CloudConnectorUrl + config.AppConfig.CloudConnectorApiGatewayEndpoint
 
-	return event.TriggerCloudConnector(invEvent.Params.ControllerId, payload.SentOn, payload.TotalEventSegments, payload.EventSegmentNumber, tagData, triggerCloudConnectorEndpoint)
+	return event.TriggerCloudConnector(controllerID, payload.SentOn, payload.TotalEventSegments, payload.EventSegmentNumber, tagData, triggerCloudConnectorEndpoint)
 }
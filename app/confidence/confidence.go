@@ -0,0 +1,76 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package confidence calculates the confidence that a tag is still in the facility. A Provider calculates it
+// with the proprietary probabilistic algorithm plugin, with a remote model over HTTP, or with the built-in model
+// of this file, for hosts without the plugin. They all take the same coefficients.
+//
+// A tag is reported present until it is seen leaving, so the question is how likely it is that a tag not read
+// for some days is still there rather than gone unnoticed. Given t, the days since the last read, and the
+// coefficients:
+//
+//	d  daily inventory percentage, the fraction of the items leaving the facility per day
+//	r  in-store read, the probability per day that a readable tag in the facility is read
+//	u  unread to read, the probability per day that a tag which could not be read becomes readable again
+//	e  exit error, the probability that an item leaves the facility without being seen leaving
+//
+// the item is still there with probability (1-d)^t, and went away unnoticed with probability (1-(1-d)^t)e.
+// An item still there and not read for t days missed its reads: the tag was readable when last read, so it
+// missed the first day with probability 1-r, after which it must have become unreadable and misses each
+// further day with probability 1-ur. The confidence is the probability that the item is still there given
+// that it was not read:
+//
+//	present = (1-d)^t (1-r)^min(t,1) (1-ur)^max(t-1,0)
+//	missing = (1-(1-d)^t) e
+//	confidence = present / (present + missing)
+//
+// A tag just read has confidence 1. Confidence drops faster with higher daily turns, exit errors and read
+// probabilities, since a tag still in the facility would likely have been read. With a perfect exit (e = 0)
+// every tag not seen leaving is still there, so the confidence stays 1.
+package confidence
+
+import (
+	"math"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+)
+
+const millisecondsInDay = float64(24 * time.Hour / time.Millisecond)
+
+// Calculate returns the confidence that the tag last read at the millisecond epoch is still in the facility.
+// Its signature is the one of the CalculateConfidence function of the probabilistic algorithm plugin.
+func Calculate(dailyInvPerc, probUnreadToRead, probInStore, probExitError float64, lastRead int64) float64 {
+	return CalculateAt(dailyInvPerc, probUnreadToRead, probInStore, probExitError, lastRead, helper.UnixMilliNow())
+}
+
+// CalculateAt returns the confidence at the millisecond epoch now that the tag last read at the millisecond
+// epoch lastRead is still in the facility. Tags never read have confidence 0.
+func CalculateAt(dailyInvPerc, probUnreadToRead, probInStore, probExitError float64, lastRead int64, now int64) float64 {
+	if lastRead <= 0 {
+		return 0
+	}
+
+	days := math.Max(float64(now-lastRead)/millisecondsInDay, 0)
+	stay := math.Pow(1-probability(dailyInvPerc), days)
+
+	inStore := probability(probInStore)
+	becomingReadable := probability(probUnreadToRead)
+	missedReads := math.Pow(1-inStore, math.Min(days, 1)) *
+		math.Pow(1-becomingReadable*inStore, math.Max(days-1, 0))
+
+	present := stay * missedReads
+	missing := (1 - stay) * probability(probExitError)
+	if present+missing == 0 {
+		return 0
+	}
+	return present / (present + missing)
+}
+
+// probability clamps the coefficient between 0 and 1
+func probability(coefficient float64) float64 {
+	return math.Min(math.Max(coefficient, 0), 1)
+}
@@ -0,0 +1,95 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package confidence
+
+import (
+	"math"
+	"testing"
+)
+
+// default coefficients of the configuration
+const (
+	dailyInvPerc     = 0.01
+	probUnreadToRead = 0.20
+	probInStore      = 0.75
+	probExitError    = 0.10
+)
+
+const now = int64(1573000000000)
+
+func daysAgo(days float64) int64 {
+	return now - int64(days*millisecondsInDay)
+}
+
+func TestCalculateAt(t *testing.T) {
+	tests := []struct {
+		name             string
+		dailyInvPerc     float64
+		probUnreadToRead float64
+		probInStore      float64
+		probExitError    float64
+		lastRead         int64
+		expected         float64
+	}{
+		{"just read", dailyInvPerc, probUnreadToRead, probInStore, probExitError, now, 1},
+		{"read in the future", dailyInvPerc, probUnreadToRead, probInStore, probExitError, now + 1000, 1},
+		{"never read", dailyInvPerc, probUnreadToRead, probInStore, probExitError, 0, 0},
+		// 0.99*0.25 / (0.99*0.25 + 0.01*0.1)
+		{"not read for a day", dailyInvPerc, probUnreadToRead, probInStore, probExitError, daysAgo(1), 0.995976},
+		// 0.99^7*0.25*0.85^6 / (0.99^7*0.25*0.85^6 + (1-0.99^7)*0.1)
+		{"not read for a week", dailyInvPerc, probUnreadToRead, probInStore, probExitError, daysAgo(7), 0.928245},
+		{"not read for a month", dailyInvPerc, probUnreadToRead, probInStore, probExitError, daysAgo(30), 0.059954},
+		{"perfect exit", dailyInvPerc, probUnreadToRead, probInStore, 0, daysAgo(30), 1},
+		{"always read", dailyInvPerc, probUnreadToRead, 1, probExitError, daysAgo(1), 0},
+		{"everything sold", 1, probUnreadToRead, probInStore, probExitError, daysAgo(1), 0},
+		{"nothing sold", 0, probUnreadToRead, probInStore, probExitError, daysAgo(30), 1},
+		{"out of range", -1, probUnreadToRead, probInStore, 2, daysAgo(1), 1},
+	}
+
+	for _, test := range tests {
+		confidence := CalculateAt(test.dailyInvPerc, test.probUnreadToRead, test.probInStore, test.probExitError,
+			test.lastRead, now)
+		if math.Abs(confidence-test.expected) > 1e-6 {
+			t.Errorf("%s: expected confidence %f, got %f", test.name, test.expected, confidence)
+		}
+	}
+}
+
+func TestCalculateAtDecreases(t *testing.T) {
+	previous := 1.0
+	for days := 0.5; days <= 60; days += 0.5 {
+		confidence := CalculateAt(dailyInvPerc, probUnreadToRead, probInStore, probExitError, daysAgo(days), now)
+		if confidence < 0 || confidence > 1 {
+			t.Fatalf("confidence %f after %v days is not a probability", confidence, days)
+		}
+		if confidence >= previous {
+			t.Fatalf("confidence %f after %v days did not decrease from %f", confidence, days, previous)
+		}
+		previous = confidence
+	}
+}
+
+func TestCalculateAtCoefficients(t *testing.T) {
+	lastRead := daysAgo(10)
+	base := CalculateAt(dailyInvPerc, probUnreadToRead, probInStore, probExitError, lastRead, now)
+
+	tests := []struct {
+		name       string
+		confidence float64
+	}{
+		{"higher daily turn", CalculateAt(0.05, probUnreadToRead, probInStore, probExitError, lastRead, now)},
+		{"more often readable", CalculateAt(dailyInvPerc, 0.5, probInStore, probExitError, lastRead, now)},
+		{"more often read", CalculateAt(dailyInvPerc, probUnreadToRead, 0.9, probExitError, lastRead, now)},
+		{"higher exit error", CalculateAt(dailyInvPerc, probUnreadToRead, probInStore, 0.5, lastRead, now)},
+	}
+
+	for _, test := range tests {
+		if test.confidence >= base {
+			t.Errorf("%s: expected confidence lower than %f, got %f", test.name, base, test.confidence)
+		}
+	}
+}
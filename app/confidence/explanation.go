@@ -0,0 +1,67 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package confidence
+
+const (
+	// ModelPlugin is the proprietary probabilistic algorithm plugin
+	ModelPlugin = "plugin"
+	// ModelBuiltin is the model of this package
+	ModelBuiltin = "builtin"
+	// ModelRemote is a model served over HTTP
+	ModelRemote = "remote"
+	// ModelNone sets every confidence to 0, when the plugin is selected but not installed
+	ModelNone = "none"
+)
+
+const (
+	// SourceFacility is the coefficients of the facility of the tag
+	SourceFacility = "facility"
+	// SourceDefault is the configured coefficients, used when the facility of the tag is unknown
+	SourceDefault = "default"
+	// SourceProduct is the coefficients of the product of the tag, from the SKU mapping
+	SourceProduct = "product"
+	// SourceComputedDailyTurn is the daily turn computed from the history of the product of the tag
+	SourceComputedDailyTurn = "computed_daily_turn"
+)
+
+// Input is a coefficient confidence is calculated from, with where it came from
+type Input struct {
+	// Value of the coefficient
+	Value float64 `json:"value"`
+	// Source of the coefficient: facility, default, product or computed_daily_turn
+	Source string `json:"source"`
+}
+
+// Explanation tells how the confidence of a tag was calculated
+type Explanation struct {
+	// EPC of the tag
+	Epc string `json:"epc"`
+	// Facility of the tag
+	FacilityID string `json:"facility_id"`
+	// Confidence of the tag
+	Confidence float64 `json:"confidence"`
+	// Model the confidence is calculated with: plugin, builtin, remote or none
+	Model string `json:"model"`
+	// Daily inventory percentage
+	DailyInventoryPercentage Input `json:"daily_inventory_percentage"`
+	// Probability of an unread tag becoming readable
+	ProbUnreadToRead Input `json:"prob_unread_to_read"`
+	// Probability of a tag in the facility being read
+	ProbInStoreRead Input `json:"prob_in_store_read"`
+	// Probability of a tag leaving without being seen leaving
+	ProbExitError Input `json:"prob_exit_error"`
+	// Version of the coefficients of the facility, if they are the source of any input
+	CoefficientsVersion int64 `json:"coefficients_version,omitempty"`
+	// Millisecond epoch the tag was last read at
+	LastRead int64 `json:"last_read"`
+	// Milliseconds elapsed since the tag was last read
+	ElapsedMillis int64 `json:"elapsed_millis"`
+	// Qualified state of the tag
+	QualifiedState string `json:"qualified_state,omitempty"`
+	// True if the qualified state of the tag sets its confidence to 0, whatever the inputs
+	BlockedByQualifiedState bool `json:"blocked_by_qualified_state,omitempty"`
+}
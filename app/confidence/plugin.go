@@ -0,0 +1,49 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package confidence
+
+import (
+	"plugin"
+
+	"github.com/pkg/errors"
+)
+
+// PluginPath is where the proprietary probabilistic algorithm plugin is installed
+const PluginPath = "/plugin/inventory-probabilistic-algo"
+
+// pluginProvider calculates confidence with the CalculateConfidence function of the plugin
+type pluginProvider struct {
+	calculate func(float64, float64, float64, float64, int64) float64
+}
+
+// LoadPlugin loads the probabilistic algorithm plugin at the path. The plugin must be built with the toolchain and
+// dependencies of the service.
+func LoadPlugin(path string) (Provider, error) {
+	confidencePlugin, err := plugin.Open(path)
+	if err != nil {
+		return nil, errors.New("Intel Probabilistic Algorithm plugin not found.")
+	}
+	calculateConfidence, err := confidencePlugin.Lookup("CalculateConfidence")
+	if err != nil {
+		return nil, errors.New("Unable to find CalculateConfidence function in plugin")
+	}
+	calculate, ok := calculateConfidence.(func(float64, float64, float64, float64, int64) float64)
+	if !ok {
+		return nil, errors.New("CalculateConfidence function of plugin has an unexpected signature")
+	}
+	return pluginProvider{calculate: calculate}, nil
+}
+
+// Model implements Provider
+func (pluginProvider) Model() string {
+	return ModelPlugin
+}
+
+// Calculate implements Provider
+func (provider pluginProvider) Calculate(requests []Request) ([]float64, error) {
+	return calculateEach(provider.calculate, requests), nil
+}
@@ -0,0 +1,71 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package confidence
+
+// Request is the inputs of the confidence of a tag
+type Request struct {
+	// EPC of the tag
+	Epc string `json:"epc"`
+	// Facility of the tag
+	FacilityID string `json:"facility_id"`
+	// Product of the tag
+	ProductID string `json:"product_id"`
+	// Daily inventory percentage
+	DailyInventoryPercentage float64 `json:"daily_inventory_percentage"`
+	// Probability of an unread tag becoming readable
+	ProbUnreadToRead float64 `json:"prob_unread_to_read"`
+	// Probability of a tag in the facility being read
+	ProbInStoreRead float64 `json:"prob_in_store_read"`
+	// Probability of a tag leaving without being seen leaving
+	ProbExitError float64 `json:"prob_exit_error"`
+	// Millisecond epoch the tag was last read at
+	LastRead int64 `json:"last_read"`
+}
+
+// Provider calculates the confidence of tags
+type Provider interface {
+	// Model is the model of the provider, one of the Model values
+	Model() string
+	// Calculate returns the confidence of each request, in the same order
+	Calculate(requests []Request) ([]float64, error)
+}
+
+// Builtin calculates confidence with the model of this package
+type Builtin struct{}
+
+// Model implements Provider
+func (Builtin) Model() string {
+	return ModelBuiltin
+}
+
+// Calculate implements Provider
+func (Builtin) Calculate(requests []Request) ([]float64, error) {
+	return calculateEach(Calculate, requests), nil
+}
+
+// None sets every confidence to 0
+type None struct{}
+
+// Model implements Provider
+func (None) Model() string {
+	return ModelNone
+}
+
+// Calculate implements Provider
+func (None) Calculate(requests []Request) ([]float64, error) {
+	return make([]float64, len(requests)), nil
+}
+
+// calculateEach calculates the confidence of the requests one by one
+func calculateEach(calculate func(float64, float64, float64, float64, int64) float64, requests []Request) []float64 {
+	confidences := make([]float64, len(requests))
+	for i, request := range requests {
+		confidences[i] = calculate(request.DailyInventoryPercentage, request.ProbUnreadToRead,
+			request.ProbInStoreRead, request.ProbExitError, request.LastRead)
+	}
+	return confidences
+}
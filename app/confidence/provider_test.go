@@ -0,0 +1,97 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package confidence
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func requests() []Request {
+	lastRead := time.Now().Add(-48*time.Hour).UnixNano() / int64(time.Millisecond)
+	return []Request{
+		{Epc: "EPC1", DailyInventoryPercentage: dailyInvPerc, ProbUnreadToRead: probUnreadToRead,
+			ProbInStoreRead: probInStore, ProbExitError: probExitError, LastRead: lastRead},
+		{Epc: "EPC2", DailyInventoryPercentage: 0.1, ProbUnreadToRead: probUnreadToRead,
+			ProbInStoreRead: probInStore, ProbExitError: probExitError, LastRead: lastRead},
+	}
+}
+
+func TestBuiltinAndNone(t *testing.T) {
+	confidences, err := Builtin{}.Calculate(requests())
+	if err != nil || len(confidences) != 2 {
+		t.Fatalf("Expected the confidence of 2 tags, got %v, %v", confidences, err)
+	}
+	if confidences[0] <= confidences[1] || confidences[1] <= 0 {
+		t.Errorf("Expected the tag with the higher daily turn to have a lower confidence, got %v", confidences)
+	}
+
+	confidences, err = None{}.Calculate(requests())
+	if err != nil || len(confidences) != 2 || confidences[0] != 0 || confidences[1] != 0 {
+		t.Errorf("Expected no confidence, got %v, %v", confidences, err)
+	}
+}
+
+func TestLoadPluginNotFound(t *testing.T) {
+	if _, err := LoadPlugin("/plugin/not-installed"); err == nil {
+		t.Error("Expected an error loading a plugin which is not installed")
+	}
+}
+
+func TestRemote(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		valid    bool
+	}{
+		{"calculated", http.StatusOK, `{"confidences": [0.9, 0.4]}`, true},
+		{"missing confidences", http.StatusOK, `{"confidences": [0.9]}`, false},
+		{"out of range", http.StatusOK, `{"confidences": [0.9, 1.5]}`, false},
+		{"not json", http.StatusOK, `confidences`, false},
+		{"failed", http.StatusInternalServerError, `{"confidences": [0.9, 0.4]}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				var body RemoteRequest
+				if err := json.NewDecoder(request.Body).Decode(&body); err != nil || len(body.Tags) != 2 ||
+					body.Tags[1].Epc != "EPC2" || body.Tags[1].DailyInventoryPercentage != 0.1 {
+					t.Errorf("Expected the inputs of both tags in one request, got %+v, %v", body, err)
+				}
+				writer.WriteHeader(test.status)
+				_, _ = writer.Write([]byte(test.response))
+			}))
+			defer server.Close()
+
+			provider := NewRemote(server.URL, time.Second)
+			if provider.Model() != ModelRemote {
+				t.Errorf("Expected the remote model, got %s", provider.Model())
+			}
+			confidences, err := provider.Calculate(requests())
+			if test.valid {
+				if err != nil || len(confidences) != 2 || confidences[0] != 0.9 || confidences[1] != 0.4 {
+					t.Errorf("Expected the confidences of the provider, got %v, %v", confidences, err)
+				}
+			} else if err == nil {
+				t.Errorf("Expected an error, got %v", confidences)
+			}
+		})
+	}
+
+	unreachable := NewRemote("http://127.0.0.1:1", time.Second)
+	if _, err := unreachable.Calculate(requests()); err == nil {
+		t.Error("Expected an error when the provider is unreachable")
+	}
+	if confidences, err := unreachable.Calculate(nil); err != nil || len(confidences) != 0 {
+		t.Errorf("Expected no request without tags, got %v, %v", confidences, err)
+	}
+}
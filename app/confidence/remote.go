@@ -0,0 +1,124 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package confidence
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// RemoteRequest is the body posted to a remote provider
+type RemoteRequest struct {
+	// Inputs of the confidence of each tag
+	Tags []Request `json:"tags"`
+}
+
+// RemoteResponse is the body a remote provider responds with
+type RemoteResponse struct {
+	// Confidence of each tag of the request, in the same order
+	Confidences []float64 `json:"confidences"`
+}
+
+// remote calculates confidence with a model served over HTTP, posting all the tags of a batch at once
+type remote struct {
+	url    string
+	client *http.Client
+}
+
+// NewRemote returns the provider posting the tags to the url, and waiting up to the timeout for their confidence
+func NewRemote(url string, timeout time.Duration) Provider {
+	return remote{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Model implements Provider
+func (remote) Model() string {
+	return ModelRemote
+}
+
+// Calculate implements Provider. The error tells why the remote provider did not respond with the confidence
+// of every tag, between 0 and 1.
+func (provider remote) Calculate(requests []Request) ([]float64, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.RemoteConfidence.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.RemoteConfidence.Success`, nil)
+	mPostErr := metrics.GetOrRegisterGauge(`Inventory.RemoteConfidence.Post-Error`, nil)
+	mResponseErr := metrics.GetOrRegisterGauge(`Inventory.RemoteConfidence.Response-Error`, nil)
+	mPostLatency := metrics.GetOrRegisterTimer(`Inventory.RemoteConfidence.Post-Latency`, nil)
+
+	if len(requests) == 0 {
+		return []float64{}, nil
+	}
+
+	body, err := json.Marshal(RemoteRequest{Tags: requests})
+	if err != nil {
+		return nil, errors.Wrap(err, "problem marshalling the confidence requests")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, provider.url, bytes.NewBuffer(body))
+	if err != nil {
+		mPostErr.Update(1)
+		return nil, errors.Wrap(err, "unable to create the confidence request")
+	}
+	request.Header.Set("content-type", "application/json")
+
+	postTimer := time.Now()
+	response, err := provider.client.Do(request)
+	if err != nil {
+		mPostErr.Update(1)
+		return nil, errors.Wrapf(err, "unable to reach the confidence provider %s", provider.url)
+	}
+	defer func() {
+		if respErr := response.Body.Close(); respErr != nil {
+			log.WithFields(log.Fields{
+				"Method": "remote.Calculate",
+				"Action": "response.Body.Close()",
+			}).Warning("Failed to close response.")
+		}
+	}()
+	mPostLatency.Update(time.Since(postTimer))
+
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		mResponseErr.Update(1)
+		return nil, errors.Wrap(err, "failed to read the response body")
+	}
+	if response.StatusCode != http.StatusOK {
+		mResponseErr.Update(1)
+		return nil, errors.Errorf("confidence provider responded with StatusCode %d, Response %s",
+			response.StatusCode, string(responseData))
+	}
+
+	var remoteResponse RemoteResponse
+	if err := json.Unmarshal(responseData, &remoteResponse); err != nil {
+		mResponseErr.Update(1)
+		return nil, errors.Wrap(err, "unable to parse the response of the confidence provider")
+	}
+	if len(remoteResponse.Confidences) != len(requests) {
+		mResponseErr.Update(1)
+		return nil, errors.Errorf("confidence provider returned %d confidences for %d tags",
+			len(remoteResponse.Confidences), len(requests))
+	}
+	for i, confidence := range remoteResponse.Confidences {
+		if math.IsNaN(confidence) || confidence < 0 || confidence > 1 {
+			mResponseErr.Update(1)
+			return nil, errors.Errorf("confidence provider returned %v for tag %s, not between 0 and 1",
+				confidence, requests[i].Epc)
+		}
+	}
+
+	mSuccess.Update(1)
+	return remoteResponse.Confidences, nil
+}
@@ -24,6 +24,30 @@ const (
 	maxCloudConnectorRetrySeconds = 60
 )
 
+const (
+	// LateReadApply applies reads and events in the order they are received, regardless of their timestamps
+	LateReadApply = "apply"
+	// LateReadIgnore ignores reads and events that are older than the tag's current state
+	LateReadIgnore = "ignore"
+	// LateReadOrdered sorts reads by timestamp before processing them, and uses late reads only
+	// to refresh signal statistics without moving the tag's state backwards in time
+	LateReadOrdered = "ordered"
+)
+
+const (
+	// ConfidenceModelAuto calculates confidence with the probabilistic algorithm plugin if it is installed,
+	// otherwise with the built-in model
+	ConfidenceModelAuto = "auto"
+	// ConfidenceModelPlugin calculates confidence with the probabilistic algorithm plugin only, confidence is 0
+	// if it is not installed
+	ConfidenceModelPlugin = "plugin"
+	// ConfidenceModelBuiltin calculates confidence with the built-in model of the confidence package
+	ConfidenceModelBuiltin = "builtin"
+	// ConfidenceModelRemote calculates confidence with the model served at ConfidenceProviderUrl, falling back
+	// to the built-in model when it fails
+	ConfidenceModelRemote = "remote"
+)
+
 type (
 	variables struct {
 		ServiceName, LoggingLevel, Port                                                                string
@@ -37,6 +61,11 @@ type (
 		RfidAlertURL, RfidAlertMessageEndpoint                                                         string
 		ContextEventFilterProviderID                                                                   string
 		PurgingDays                                                                                    int
+		PurgingBatchSize, PurgingIntervalHours                                                         int
+		ArchiveDepartedDays, ArchiveRetentionDays                                                      int
+		JournalCheckpointIntervalHours                                                                 int
+		ReplaceBatchSize                                                                               int
+		VersionConflictRetries                                                                         int
 		ServerReadTimeOutSeconds                                                                       int
 		ServerWriteTimeOutSeconds                                                                      int
 		ResponseLimit                                                                                  int
@@ -53,6 +82,13 @@ type (
 		DailyTurnComputeUsingMedian                                                                    bool
 		UseComputedDailyTurnInConfidence                                                               bool
 		ProbabilisticAlgorithmPlugin                                                                   bool
+		ConfidenceModel, ConfidenceProviderUrl                                                         string
+		ProductDataCacheTTLSeconds, ProductDataCacheRetrySeconds                                       int
+		ProbablyMissingThreshold                                                                       float64
+		ProbablyMissingIntervalMinutes                                                                 int
+		CoefficientEstimationDays, CoefficientEstimationIntervalHours                                  int
+		CoefficientEstimationMinimumSamples                                                            int
+		CoefficientEstimationByProduct, ApplyEstimatedCoefficients                                     bool
 		TagDecoders                                                                                    []encodingscheme.TagDecoder
 
 		// todo: these should be int64, but that is NOT SUPPORTED by the config library
@@ -63,6 +99,10 @@ type (
 		CoreCommandUrl string
 		EnableCORS     bool
 		CORSOrigin     string
+
+		// LateReadPolicy is one of LateReadApply, LateReadIgnore or LateReadOrdered
+		LateReadPolicy          string
+		LateReadToleranceMillis int
 	}
 )
 
@@ -387,6 +427,19 @@ func InitConfig() error {
 		AppConfig.ProbabilisticAlgorithmPlugin = true
 	}
 
+	AppConfig.ConfidenceModel = getOrDefaultString(config, "confidenceModel", ConfidenceModelAuto)
+	switch AppConfig.ConfidenceModel {
+	case ConfidenceModelAuto, ConfidenceModelPlugin, ConfidenceModelBuiltin, ConfidenceModelRemote:
+	default:
+		return fmt.Errorf("ConfidenceModel must be one of %s, %s, %s or %s! ConfidenceModel: %s",
+			ConfidenceModelAuto, ConfidenceModelPlugin, ConfidenceModelBuiltin, ConfidenceModelRemote, AppConfig.ConfidenceModel)
+	}
+
+	AppConfig.ConfidenceProviderUrl = getOrDefaultString(config, "confidenceProviderUrl", "")
+	if AppConfig.ConfidenceModel == ConfidenceModelRemote && AppConfig.ConfidenceProviderUrl == "" {
+		return fmt.Errorf("ConfidenceProviderUrl is required by the %s confidence model", ConfidenceModelRemote)
+	}
+
 	AppConfig.PosDepartedThresholdMillis = getOrDefaultInt(config, "posDepartedThresholdMillis", 3600000)
 	if AppConfig.PosDepartedThresholdMillis < 0 {
 		return fmt.Errorf("PosDepartedThresholdMillis should not be negative! PosDepartedThresholdMillis: %d", AppConfig.PosDepartedThresholdMillis)
@@ -412,9 +465,111 @@ func InitConfig() error {
 	AppConfig.EnableCORS = getOrDefaultBool(config, "enableCORS", true)
 	AppConfig.CORSOrigin = getOrDefaultString(config, "corsOrigin", "*")
 
+	AppConfig.LateReadPolicy = getOrDefaultString(config, "lateReadPolicy", LateReadIgnore)
+	switch AppConfig.LateReadPolicy {
+	case LateReadApply, LateReadIgnore, LateReadOrdered:
+	default:
+		return fmt.Errorf("LateReadPolicy must be one of %s, %s or %s! LateReadPolicy: %s",
+			LateReadApply, LateReadIgnore, LateReadOrdered, AppConfig.LateReadPolicy)
+	}
+
+	AppConfig.LateReadToleranceMillis = getOrDefaultInt(config, "lateReadToleranceMillis", 1000)
+	if AppConfig.LateReadToleranceMillis < 0 {
+		return fmt.Errorf("LateReadToleranceMillis should not be negative! LateReadToleranceMillis: %d", AppConfig.LateReadToleranceMillis)
+	}
+
+	AppConfig.PurgingBatchSize = getOrDefaultInt(config, "purgingBatchSize", 1000)
+	if AppConfig.PurgingBatchSize <= 0 {
+		return fmt.Errorf("PurgingBatchSize should be greater than 0! PurgingBatchSize: %d", AppConfig.PurgingBatchSize)
+	}
+
+	AppConfig.ReplaceBatchSize = getOrDefaultInt(config, "replaceBatchSize", 500)
+	if AppConfig.ReplaceBatchSize <= 0 {
+		return fmt.Errorf("ReplaceBatchSize should be greater than 0! ReplaceBatchSize: %d", AppConfig.ReplaceBatchSize)
+	}
+
+	AppConfig.VersionConflictRetries = getOrDefaultInt(config, "versionConflictRetries", 3)
+	if AppConfig.VersionConflictRetries < 0 {
+		return fmt.Errorf("VersionConflictRetries should not be negative! VersionConflictRetries: %d", AppConfig.VersionConflictRetries)
+	}
+
+	AppConfig.PurgingIntervalHours = getOrDefaultInt(config, "purgingIntervalHours", 24)
+	if AppConfig.PurgingIntervalHours <= 0 {
+		return fmt.Errorf("PurgingIntervalHours should be greater than 0! PurgingIntervalHours: %d", AppConfig.PurgingIntervalHours)
+	}
+
+	// 0 disables the probably missing reports of facilities without their own threshold
+	AppConfig.ProbablyMissingThreshold = getOrDefaultFloat(config, "probablyMissingThreshold", 0)
+	if AppConfig.ProbablyMissingThreshold < 0 || AppConfig.ProbablyMissingThreshold > 1 {
+		return fmt.Errorf("ProbablyMissingThreshold should be between 0 and 1! ProbablyMissingThreshold: %v", AppConfig.ProbablyMissingThreshold)
+	}
+
+	AppConfig.ProbablyMissingIntervalMinutes = getOrDefaultInt(config, "probablyMissingIntervalMinutes", 60)
+	if AppConfig.ProbablyMissingIntervalMinutes <= 0 {
+		return fmt.Errorf("ProbablyMissingIntervalMinutes should be greater than 0! ProbablyMissingIntervalMinutes: %d", AppConfig.ProbablyMissingIntervalMinutes)
+	}
+
+	// 0 disables the estimation of the coefficients from the reads
+	AppConfig.CoefficientEstimationDays = getOrDefaultInt(config, "coefficientEstimationDays", 14)
+	if AppConfig.CoefficientEstimationDays < 0 {
+		return fmt.Errorf("CoefficientEstimationDays should not be negative! CoefficientEstimationDays: %d", AppConfig.CoefficientEstimationDays)
+	}
+
+	AppConfig.CoefficientEstimationIntervalHours = getOrDefaultInt(config, "coefficientEstimationIntervalHours", 24)
+	if AppConfig.CoefficientEstimationIntervalHours <= 0 {
+		return fmt.Errorf("CoefficientEstimationIntervalHours should be greater than 0! CoefficientEstimationIntervalHours: %d", AppConfig.CoefficientEstimationIntervalHours)
+	}
+
+	AppConfig.CoefficientEstimationMinimumSamples = getOrDefaultInt(config, "coefficientEstimationMinimumSamples", 100)
+	if AppConfig.CoefficientEstimationMinimumSamples <= 0 {
+		return fmt.Errorf("CoefficientEstimationMinimumSamples should be greater than 0! CoefficientEstimationMinimumSamples: %d", AppConfig.CoefficientEstimationMinimumSamples)
+	}
+
+	AppConfig.CoefficientEstimationByProduct = getOrDefaultBool(config, "coefficientEstimationByProduct", false)
+	AppConfig.ApplyEstimatedCoefficients = getOrDefaultBool(config, "applyEstimatedCoefficients", false)
+
+	// 0 disables caching
+	AppConfig.ProductDataCacheTTLSeconds = getOrDefaultInt(config, "productDataCacheTTLSeconds", 300)
+	if AppConfig.ProductDataCacheTTLSeconds < 0 {
+		return fmt.Errorf("ProductDataCacheTTLSeconds should not be negative! ProductDataCacheTTLSeconds: %d", AppConfig.ProductDataCacheTTLSeconds)
+	}
+
+	AppConfig.ProductDataCacheRetrySeconds = getOrDefaultInt(config, "productDataCacheRetrySeconds", 30)
+	if AppConfig.ProductDataCacheRetrySeconds <= 0 {
+		return fmt.Errorf("ProductDataCacheRetrySeconds should be greater than 0! ProductDataCacheRetrySeconds: %d", AppConfig.ProductDataCacheRetrySeconds)
+	}
+
+	// 0 disables archiving
+	AppConfig.ArchiveDepartedDays = getOrDefaultInt(config, "archiveDepartedDays", 0)
+	if AppConfig.ArchiveDepartedDays < 0 {
+		return fmt.Errorf("ArchiveDepartedDays should not be negative! ArchiveDepartedDays: %d", AppConfig.ArchiveDepartedDays)
+	}
+	// 0 keeps the archived tags forever, otherwise they are purged from the archive after ArchiveRetentionDays,
+	// so they must be archived before
+	AppConfig.ArchiveRetentionDays = getOrDefaultInt(config, "archiveRetentionDays", 0)
+	if AppConfig.ArchiveRetentionDays < 0 {
+		return fmt.Errorf("ArchiveRetentionDays should not be negative! ArchiveRetentionDays: %d", AppConfig.ArchiveRetentionDays)
+	}
+	if AppConfig.ArchiveDepartedDays > 0 && AppConfig.ArchiveRetentionDays > 0 && AppConfig.ArchiveRetentionDays <= AppConfig.ArchiveDepartedDays {
+		return fmt.Errorf("ArchiveRetentionDays should be greater than ArchiveDepartedDays! ArchiveRetentionDays: %d, ArchiveDepartedDays: %d",
+			AppConfig.ArchiveRetentionDays, AppConfig.ArchiveDepartedDays)
+	}
+
+	// the inventory as of a past time is rebuilt from the tag event journal since the latest checkpoint
+	AppConfig.JournalCheckpointIntervalHours = getOrDefaultInt(config, "journalCheckpointIntervalHours", 168)
+	if AppConfig.JournalCheckpointIntervalHours <= 0 {
+		return fmt.Errorf("JournalCheckpointIntervalHours should be greater than 0! JournalCheckpointIntervalHours: %d", AppConfig.JournalCheckpointIntervalHours)
+	}
+
 	return nil
 }
 
+// LateReadsGuarded returns true when the late read policy keeps reads that are older
+// than a tag's current state from moving it backwards in time
+func LateReadsGuarded() bool {
+	return AppConfig.LateReadPolicy == LateReadIgnore || AppConfig.LateReadPolicy == LateReadOrdered
+}
+
 func getOrDefaultBool(config *configuration.Configuration, path string, defaultValue bool) bool {
 	value, err := config.GetBool(path)
 	if err != nil {
@@ -442,6 +597,15 @@ func getOrDefaultInt(config *configuration.Configuration, path string, defaultVa
 	return value
 }
 
+func getOrDefaultFloat(config *configuration.Configuration, path string, defaultValue float64) float64 {
+	value, err := config.GetFloat(path)
+	if err != nil {
+		log.Debugf("%s was missing from configuration, setting to default value of %v", path, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
 func getTagDecoders(config *configuration.Configuration) ([]encodingscheme.TagDecoder, error) {
 	var decoders []encodingscheme.TagDecoder
 
@@ -576,42 +740,3 @@ func parseAgeOuts(ageOutString string) (map[string]int, error) {
 
 	return ageOuts, nil
 }
-
-// DbSchema postgreSQL db schema
-const DbSchema = `
-CREATE EXTENSION IF NOT EXISTS pgcrypto;
-
-CREATE TABLE IF NOT EXISTS tags (
-	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-	data JSONB	
-);
-
-CREATE UNIQUE INDEX IF NOT EXISTS idx_epc
-ON tags ((data->>'epc'));
-
-CREATE TABLE IF NOT EXISTS handheldevents (
-	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-	data JSONB	
-);
-
-CREATE TABLE IF NOT EXISTS rspconfig (
-	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-	data JSONB	
-);
-
-CREATE UNIQUE INDEX IF NOT EXISTS idx_device_id
-ON rspconfig ((data->>'device_id'));
-
-CREATE TABLE IF NOT EXISTS facilities (
-	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-	data JSONB	
-);
-
-CREATE TABLE IF NOT EXISTS dailyturnhistory (
-	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-	data JSONB	
-);
-
-CREATE UNIQUE INDEX IF NOT EXISTS idx_product_id
-ON dailyturnhistory ((data->>'product_id'));
-`
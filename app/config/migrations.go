@@ -0,0 +1,266 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package config
+
+import "github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/migration"
+
+// Migrations are the versioned postgreSQL schema migrations, applied in order on startup.
+// Released migrations must not be modified; append a new one instead.
+// The first migrations only create missing objects, so that they also apply to databases
+// created before migrations were versioned.
+var Migrations = []migration.Migration{
+	{
+		Version:     1,
+		Description: "baseline tables",
+		Up: `
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE IF NOT EXISTS tags (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB	
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_epc
+ON tags ((data->>'epc'));
+
+CREATE TABLE IF NOT EXISTS handheldevents (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB	
+);
+
+CREATE TABLE IF NOT EXISTS rspconfig (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB	
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_device_id
+ON rspconfig ((data->>'device_id'));
+
+CREATE TABLE IF NOT EXISTS facilities (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB	
+);
+
+CREATE TABLE IF NOT EXISTS dailyturnhistory (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB	
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_id
+ON dailyturnhistory ((data->>'product_id'));
+`,
+	},
+	{
+		Version:     2,
+		Description: "tag event journal",
+		Up: `
+CREATE TABLE IF NOT EXISTS tag_events (
+	id UUID NOT NULL DEFAULT gen_random_uuid(),
+	timestamp BIGINT NOT NULL,
+	data JSONB,
+	PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+CREATE INDEX IF NOT EXISTS idx_tag_events_epc
+ON tag_events ((data->>'epc'), timestamp);
+
+CREATE INDEX IF NOT EXISTS idx_tag_events_timestamp
+ON tag_events (timestamp);
+`,
+	},
+	{
+		Version:     3,
+		Description: "qualified state definitions and history",
+		Up: `
+CREATE TABLE IF NOT EXISTS qualified_state_definitions (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_qualified_state_facility_id
+ON qualified_state_definitions ((data->>'facility_id'));
+
+CREATE TABLE IF NOT EXISTS qualified_state_history (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_qualified_state_history_epc
+ON qualified_state_history ((data->>'epc'));
+`,
+	},
+	{
+		Version:     4,
+		Description: "structured epc context",
+		Up: `
+-- the structured epc context of a tag, an empty object if its context is not a JSON object
+CREATE OR REPLACE FUNCTION epc_context_object(context TEXT) RETURNS JSONB AS $$
+DECLARE
+	object JSONB;
+BEGIN
+	IF context IS NULL OR left(context, 1) <> '{' THEN
+		RETURN '{}'::JSONB;
+	END IF;
+	object := context::JSONB;
+	IF jsonb_typeof(object) <> 'object' THEN
+		RETURN '{}'::JSONB;
+	END IF;
+	RETURN object;
+EXCEPTION WHEN invalid_text_representation THEN
+	RETURN '{}'::JSONB;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+CREATE TABLE IF NOT EXISTS epc_context_schema (
+	id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+	data JSONB
+);
+`,
+	},
+	{
+		Version:     5,
+		Description: "tags archive",
+		Up: `
+CREATE TABLE IF NOT EXISTS tags_archive (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archive_epc
+ON tags_archive ((data->>'epc'));
+
+CREATE OR REPLACE VIEW tags_all AS
+SELECT id, data FROM tags UNION ALL SELECT id, data FROM tags_archive;
+`,
+	},
+	{
+		Version:     6,
+		Description: "indexes on the hot tag fields",
+		// the indexes are built concurrently so that the tags can still be written meanwhile. An interrupted
+		// concurrent build leaves an invalid index behind, so each index is dropped before being built.
+		NoTransaction: true,
+		Up: `
+DROP INDEX CONCURRENTLY IF EXISTS idx_tags_facility_id;
+CREATE INDEX CONCURRENTLY idx_tags_facility_id
+ON tags ((data->>'facility_id'));
+
+DROP INDEX CONCURRENTLY IF EXISTS idx_tags_epc_state;
+CREATE INDEX CONCURRENTLY idx_tags_epc_state
+ON tags ((data->>'epc_state'));
+
+DROP INDEX CONCURRENTLY IF EXISTS idx_tags_product_id;
+CREATE INDEX CONCURRENTLY idx_tags_product_id
+ON tags ((data->>'product_id'));
+
+DROP INDEX CONCURRENTLY IF EXISTS idx_tags_qualified_state;
+CREATE INDEX CONCURRENTLY idx_tags_qualified_state
+ON tags ((data->>'qualified_state'));
+
+-- the last read is compared as a number, by the OData filters as well as the custom queries
+DROP INDEX CONCURRENTLY IF EXISTS idx_tags_last_read_millis;
+CREATE INDEX CONCURRENTLY idx_tags_last_read_millis
+ON tags (((data->>'last_read')::BIGINT));
+
+-- equality filters on the paths of the structured epc context are containments of the context
+DROP INDEX CONCURRENTLY IF EXISTS idx_tags_epc_context;
+CREATE INDEX CONCURRENTLY idx_tags_epc_context
+ON tags USING GIN (epc_context_object(data->>'epc_context') jsonb_path_ops);
+
+DROP INDEX CONCURRENTLY IF EXISTS idx_archive_epc_context;
+CREATE INDEX CONCURRENTLY idx_archive_epc_context
+ON tags_archive USING GIN (epc_context_object(data->>'epc_context') jsonb_path_ops);
+`,
+	},
+	{
+		Version:     7,
+		Description: "unique facility names",
+		Up: `
+-- duplicates are not deleted, as either may hold customised coefficients: an operator merges them and restarts
+DO $$
+DECLARE
+	duplicates TEXT;
+BEGIN
+	SELECT string_agg(name, ', ' ORDER BY name) INTO duplicates
+	FROM (SELECT data->>'name' AS name FROM facilities GROUP BY 1 HAVING COUNT(*) > 1) duplicate_names;
+	IF duplicates IS NOT NULL THEN
+		RAISE EXCEPTION 'facilities with duplicate names must be merged before migrating: %', duplicates;
+	END IF;
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_facility_name
+ON facilities ((data->>'name'));
+`,
+	},
+	{
+		Version:     8,
+		Description: "coefficients history",
+		Up: `
+CREATE TABLE IF NOT EXISTS coefficients_history (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coefficients_history_version
+ON coefficients_history ((data->>'facility_id'), ((data->>'version')::BIGINT));
+
+-- the coefficients of the existing facilities become their first version
+UPDATE facilities SET data = data || '{"coefficients_version": 1}'::JSONB
+WHERE NOT data ? 'coefficients_version';
+
+INSERT INTO coefficients_history (data)
+SELECT jsonb_build_object(
+	'facility_id', data->>'name',
+	'version', 1,
+	'coefficients', data->'coefficients',
+	'changed_by', '',
+	'reason', 'coefficients before the history was recorded',
+	'timestamp', (extract(epoch FROM now()) * 1000)::BIGINT)
+FROM facilities
+ON CONFLICT DO NOTHING;
+`,
+	},
+	{
+		Version:     9,
+		Description: "probably missing tags",
+		Up: `
+CREATE TABLE IF NOT EXISTS probably_missing (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_probably_missing_epc
+ON probably_missing ((data->>'epc'));
+`,
+	},
+	{
+		Version:     10,
+		Description: "coefficient estimates",
+		Up: `
+CREATE TABLE IF NOT EXISTS coefficient_estimates (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	data JSONB
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coefficient_estimates_facility_product
+ON coefficient_estimates ((data->>'facility_id'), (COALESCE(data->>'product_id', '')));
+`,
+	},
+	{
+		Version:     11,
+		Description: "tag event journal checkpoints",
+		Up: `
+CREATE TABLE IF NOT EXISTS tag_event_checkpoints (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_event_checkpoints_timestamp
+ON tag_event_checkpoints (timestamp);
+`,
+	},
+}
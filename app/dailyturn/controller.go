@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
@@ -77,7 +78,7 @@ func Upsert(dbs *sql.DB, history History) error {
 	return nil
 }
 
-func computeDailyTurnRecord(dbs *sql.DB, productId string) error {
+func computeDailyTurnRecord(dbs *sql.DB, productId string, site facility.Facility) error {
 	history, err := FindHistoryByProductId(dbs, productId)
 	if err != nil {
 		return err
@@ -107,9 +108,10 @@ func computeDailyTurnRecord(dbs *sql.DB, productId string) error {
 			PreviousTimestamp: history.Timestamp,
 			Departed:          result.DepartedTags,
 			Present:           result.PresentTags,
+			BusinessDay:       site.BusinessDay(now),
 		}
 
-		if err := record.ComputeDailyTurn(); err != nil {
+		if err := record.ComputeDailyTurnAt(site); err != nil {
 			return err
 		}
 
@@ -141,9 +143,16 @@ func ProcessIncomingASNList(dbs *sql.DB, asnList []tag.AdvanceShippingNotice) {
 	log.Debug("Process incoming ASN")
 	beginTimer := time.Now()
 
+	// the days are counted in the time zone of the facility of the shipping notice
+	facilities, err := facility.CreateFacilityMap(dbs)
+	if err != nil {
+		log.Errorf("Unable to find the facilities, days are counted in UTC: %v", err)
+	}
+
 	for _, asn := range asnList {
+		site := facilities[asn.SiteID]
 		for _, asnItem := range asn.Items {
-			if err := computeDailyTurnRecord(dbs, asnItem.ItemGTIN); err != nil {
+			if err := computeDailyTurnRecord(dbs, asnItem.ItemGTIN, site); err != nil {
 				// this is not an error because the data may not be ready yet to compute the daily turn
 				log.Infof("Unable to compute the daily turn for product_id %s: %v", asnItem.ItemGTIN, err.Error())
 				continue
@@ -232,7 +241,7 @@ func FindPresentOrDepartedTagsSinceTimestamp(db *sql.DB, productId string, since
 
 	// query for not departed i.e. present tags
 	selectStmt := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s ->> %s = %s"+
-		"AND %s ->> %s != %s AND (%s ->> %s)::numeric > '0'",
+		"AND %s ->> %s != %s AND (%s ->> %s)::BIGINT > '0'",
 		pq.QuoteIdentifier(tagsTable),
 		pq.QuoteIdentifier(jsonb),
 		pq.QuoteLiteral(productIdColumn),
@@ -257,7 +266,7 @@ func FindPresentOrDepartedTagsSinceTimestamp(db *sql.DB, productId string, since
 
 	// query for departed tags
 	selectStmt = fmt.Sprintf("SELECT count(*) FROM %s  WHERE %s ->> %s = %s"+
-		"AND %s ->> %s = %s AND (%s ->> %s)::numeric > %s",
+		"AND %s ->> %s = %s AND (%s ->> %s)::BIGINT > %s",
 		pq.QuoteIdentifier(tagsTable),
 		pq.QuoteIdentifier(jsonb),
 		pq.QuoteLiteral(productIdColumn),
@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/integrationtest"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
@@ -222,6 +223,25 @@ func TestRecord_ComputeDailyTurn2(t *testing.T) {
 	}
 }
 
+func TestRecord_ComputeDailyTurnAt(t *testing.T) {
+	site := facility.Facility{Name: "store1", Timezone: "America/Los_Angeles"}
+
+	// daylight saving time ends on November 3rd 2019, so the local day is 25 hours long
+	record := Record{
+		Timestamp:         1572854400000, // 2019-11-04T08:00:00Z
+		PreviousTimestamp: 1572764400000, // 2019-11-03T07:00:00Z
+		Departed:          100,
+		Present:           300,
+	}
+
+	if err := record.ComputeDailyTurnAt(site); err != nil {
+		t.Fatalf("unexpected error computing daily turn: %v", err.Error())
+	}
+	if math.Abs(record.DailyTurn-0.25) > epsilon {
+		t.Fatalf("Computed daily turn value of %f is not equal to the expected value of 0.25 over one local day", record.DailyTurn)
+	}
+}
+
 func TestRecord_ComputeDailyTurn_ErrTimeTooShort(t *testing.T) {
 	now := helper.UnixMilliNow()
 
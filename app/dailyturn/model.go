@@ -9,6 +9,7 @@ package dailyturn
 import (
 	"errors"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
 	log "github.com/sirupsen/logrus"
 	"math"
 	"sort"
@@ -39,16 +40,23 @@ type Record struct { //nolint :golint
 	DailyTurn         float64 `json:"daily_turn" bson:"daily_turn"`
 	PreviousTimestamp int64   `json:"previous_timestamp" bson:"previous_timestamp"`
 	Timestamp         int64   `json:"timestamp" bson:"timestamp"`
+	// Local date of the business day of the timestamp in the facility of the shipping notice
+	BusinessDay string `json:"business_day,omitempty" bson:"business_day"`
 }
 
 func (record *Record) ComputeDailyTurn() error {
+	return record.ComputeDailyTurnAt(facility.Facility{})
+}
+
+// ComputeDailyTurnAt computes the daily turn with the days counted on the local clock of the facility
+func (record *Record) ComputeDailyTurnAt(site facility.Facility) error {
 	log.Debugf("Compute Daily Turn: %d", record.Timestamp)
 
 	if record.Present+record.Departed == 0 {
 		return ErrNoInventory
 	}
 
-	daysSinceLastTimestamp := float64(record.Timestamp-record.PreviousTimestamp) / millisecondsInDay
+	daysSinceLastTimestamp := site.DaysBetween(record.PreviousTimestamp, record.Timestamp)
 	if daysSinceLastTimestamp < 1.0 {
 		return ErrTimeTooShort
 	}
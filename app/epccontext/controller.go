@@ -0,0 +1,99 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package epccontext
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-gojsonschema"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	schemaTable = "epc_context_schema"
+	jsonb       = "data"
+)
+
+// RegisterSchema registers the JSON schema structured epc contexts must be valid against, replacing the
+// previously registered one
+func RegisterSchema(dbs *sql.DB, schema json.RawMessage) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.EpcContext.RegisterSchema.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.EpcContext.RegisterSchema.Success`, nil)
+	mUpsertErr := metrics.GetOrRegisterGauge(`Inventory.EpcContext.RegisterSchema.Upsert-Error`, nil)
+
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema)); err != nil {
+		return errors.Wrapf(web.ErrValidation, "invalid epc context schema: %s", err.Error())
+	}
+
+	upsertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)
+		ON CONFLICT (id) DO UPDATE SET %s = EXCLUDED.%s;`,
+		pq.QuoteIdentifier(schemaTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(string(schema)),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+	)
+
+	if _, err := dbs.Exec(upsertStmt); err != nil {
+		mUpsertErr.Update(1)
+		return errors.Wrap(err, "error in registering epc context schema")
+	}
+
+	mSuccess.Update(1)
+	return nil
+}
+
+// FindSchema returns the registered epc context schema, or nil if none is registered
+func FindSchema(dbs *sql.DB) (json.RawMessage, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.EpcContext.FindSchema.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.EpcContext.FindSchema.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge(`Inventory.EpcContext.FindSchema.Find-Error`, nil)
+
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(schemaTable),
+	)
+
+	var schema []byte
+	if err := dbs.QueryRow(selectQuery).Scan(&schema); err != nil {
+		if err == sql.ErrNoRows {
+			mSuccess.Update(1)
+			return nil, nil
+		}
+		mFindErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving epc context schema")
+	}
+
+	mSuccess.Update(1)
+	return schema, nil
+}
+
+// DeleteSchema unregisters the epc context schema, after which structured epc contexts are not validated
+func DeleteSchema(dbs *sql.DB) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.EpcContext.DeleteSchema.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.EpcContext.DeleteSchema.Success`, nil)
+	mDeleteErr := metrics.GetOrRegisterGauge(`Inventory.EpcContext.DeleteSchema.Delete-Error`, nil)
+
+	if _, err := dbs.Exec(fmt.Sprintf(`DELETE FROM %s`, pq.QuoteIdentifier(schemaTable))); err != nil {
+		mDeleteErr.Update(1)
+		return errors.Wrap(err, "error in deleting epc context schema")
+	}
+
+	mSuccess.Update(1)
+	return nil
+}
@@ -1,16 +1,22 @@
 /* Apache v2 license
-*  Copyright (C) <2019> Intel Corporation
+*  Copyright (C) <2019> Intel Corporation
 *
 *  SPDX-License-Identifier: Apache-2.0
  */
 
 package epccontext
 
+import (
+	"bytes"
+	"encoding/json"
+)
+
 // PutBody is the struct for the request body to create/update epc context for a given epc
 type PutBody struct {
-	Epc        string `json:"epc"`
-	EpcContext string `json:"epc_context"`
-	FacilityID string `json:"facility_id"`
+	Epc string `json:"epc"`
+	// Either a plain string or a structured JSON object
+	EpcContext json.RawMessage `json:"epc_context"`
+	FacilityID string          `json:"facility_id"`
 }
 
 // DeleteBody is the struct for the request body to delete epc context for a given epc
@@ -18,3 +24,24 @@ type DeleteBody struct {
 	Epc        string `json:"epc"`
 	FacilityID string `json:"facility_id"`
 }
+
+// Context returns the epc context to store, and whether it is a structured JSON object rather than a plain string.
+func (body PutBody) Context() (string, bool, error) {
+	return ParseContext(body.EpcContext)
+}
+
+// ParseContext returns the epc context to store from its value in a request, and whether it is a structured JSON
+// object rather than a plain string. Structured contexts are stored serialized, as the ASN context is, so that
+// both can be queried by path.
+func ParseContext(value json.RawMessage) (string, bool, error) {
+	var context string
+	if err := json.Unmarshal(value, &context); err == nil {
+		return context, false, nil
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, value); err != nil {
+		return "", false, err
+	}
+	return compacted.String(), true, nil
+}
@@ -0,0 +1,35 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package epccontext
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	tests := []struct {
+		body       string
+		context    string
+		structured bool
+	}{
+		{`"received"`, "received", false},
+		{`"{\"asnId\":\"123\"}"`, `{"asnId":"123"}`, false},
+		{`{ "asnId": "123", "carton": {"id": "C7"} }`, `{"asnId":"123","carton":{"id":"C7"}}`, true},
+	}
+
+	for _, test := range tests {
+		context, structured, err := PutBody{EpcContext: json.RawMessage(test.body)}.Context()
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", test.body, err)
+		}
+		if context != test.context || structured != test.structured {
+			t.Errorf("expected %q (structured %v) for %s, got %q (structured %v)",
+				test.context, test.structured, test.body, context, structured)
+		}
+	}
+}
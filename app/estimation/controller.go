@@ -0,0 +1,216 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package estimation
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	estimatesTable   = "coefficient_estimates"
+	jsonb            = "data"
+	facilityIDColumn = "facility_id"
+	productIDColumn  = "product_id"
+)
+
+// Replace replaces all the estimates by the ones of the latest estimation
+func Replace(dbs *sql.DB, estimates []Estimate) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.ReplaceEstimates.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.ReplaceEstimates.Success`, nil)
+	mReplaceErr := metrics.GetOrRegisterGauge(`Inventory.ReplaceEstimates.Replace-Error`, nil)
+	mReplaceLatency := metrics.GetOrRegisterTimer(`Inventory.ReplaceEstimates.Replace-Latency`, nil)
+
+	replaceTimer := time.Now()
+	transaction, err := dbs.Begin()
+	if err != nil {
+		mReplaceErr.Update(1)
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+
+	if err := replaceInTransaction(transaction, estimates); err != nil {
+		mReplaceErr.Update(1)
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return errors.Wrap(rollbackErr, err.Error())
+		}
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		mReplaceErr.Update(1)
+		return errors.Wrap(err, "unable to commit coefficient estimates")
+	}
+	mReplaceLatency.Update(time.Since(replaceTimer))
+
+	mSuccess.Update(1)
+	return nil
+}
+
+func replaceInTransaction(transaction *sql.Tx, estimates []Estimate) error {
+	deleteStmt := fmt.Sprintf(`DELETE FROM %s;`, pq.QuoteIdentifier(estimatesTable))
+	if _, err := transaction.Exec(deleteStmt); err != nil {
+		return errors.Wrap(err, "error in deleting coefficient estimates")
+	}
+
+	if len(estimates) == 0 {
+		return nil
+	}
+	values := make([]string, len(estimates))
+	for i, estimate := range estimates {
+		obj, err := json.Marshal(estimate)
+		if err != nil {
+			return err
+		}
+		values[i] = fmt.Sprintf("(%s)", pq.QuoteLiteral(string(obj)))
+	}
+	insertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES %s;`,
+		pq.QuoteIdentifier(estimatesTable),
+		pq.QuoteIdentifier(jsonb),
+		strings.Join(values, ", "),
+	)
+	if _, err := transaction.Exec(insertStmt); err != nil {
+		return errors.Wrap(err, "error in inserting coefficient estimates")
+	}
+	return nil
+}
+
+// Retrieve returns the estimates, of the facility if not empty, the estimate of each facility
+// before the ones of its products
+func Retrieve(dbs *sql.DB, facilityID string) ([]Estimate, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.RetrieveEstimates.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.RetrieveEstimates.Success`, nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge(`Inventory.RetrieveEstimates.Retrieve-Error`, nil)
+
+	whereClause := ""
+	if facilityID != "" {
+		whereClause = fmt.Sprintf("WHERE %s ->> %s = %s",
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(facilityIDColumn),
+			pq.QuoteLiteral(facilityID),
+		)
+	}
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s %s ORDER BY %s ->> %s, COALESCE(%s ->> %s, '');`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(estimatesTable),
+		whereClause,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(facilityIDColumn),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(productIDColumn),
+	)
+
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving coefficient estimates")
+	}
+	defer rows.Close()
+
+	estimates := make([]Estimate, 0)
+	for rows.Next() {
+		var estimate Estimate
+		if err := rows.Scan(&estimate); err != nil {
+			mRetrieveErr.Update(1)
+			return nil, err
+		}
+		estimates = append(estimates, estimate)
+	}
+	if err = rows.Err(); err != nil {
+		mRetrieveErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return estimates, nil
+}
+
+// Apply replaces the read probabilities of the coefficients of the facility by its estimate, recorded as a new
+// version of its coefficients. The error is web.ErrNotFound if the facility or its estimate does not exist.
+func Apply(dbs *sql.DB, facilityID string, changedBy string, reason string) (facility.CoefficientsVersion, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.ApplyEstimate.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.ApplyEstimate.Success`, nil)
+	mApplyErr := metrics.GetOrRegisterGauge(`Inventory.ApplyEstimate.Apply-Error`, nil)
+
+	estimates, err := Retrieve(dbs, facilityID)
+	if err != nil {
+		mApplyErr.Update(1)
+		return facility.CoefficientsVersion{}, err
+	}
+	if len(estimates) == 0 || estimates[0].ProductID != "" {
+		mApplyErr.Update(1)
+		return facility.CoefficientsVersion{}, errors.Wrapf(web.ErrNotFound, "no estimate for facility %s", facilityID)
+	}
+
+	facilities, err := facility.CreateFacilityMap(dbs)
+	if err != nil {
+		mApplyErr.Update(1)
+		return facility.CoefficientsVersion{}, err
+	}
+	site, found := facilities[facilityID]
+	if !found {
+		mApplyErr.Update(1)
+		return facility.CoefficientsVersion{}, errors.Wrapf(web.ErrNotFound, "facility %s", facilityID)
+	}
+
+	change, err := facility.ChangeCoefficients(dbs, changeOf(site, estimates[0], changedBy, reason))
+	if err != nil {
+		mApplyErr.Update(1)
+		return change, err
+	}
+
+	mSuccess.Update(1)
+	return change, nil
+}
+
+// changeOf is the change of the coefficients of the facility to its estimate, keeping the coefficients which
+// are not estimated
+func changeOf(site facility.Facility, estimate Estimate, changedBy string, reason string) facility.CoefficientsVersion {
+	coefficients := site.Coefficients
+	coefficients.ProbInStoreRead = estimate.ProbInStoreRead
+	coefficients.ProbUnreadToRead = estimate.ProbUnreadToRead
+
+	if reason == "" {
+		reason = fmt.Sprintf("estimated from %d read gaps", estimate.Samples)
+	}
+	return facility.CoefficientsVersion{
+		FacilityID:   site.Name,
+		Coefficients: coefficients,
+		ChangedBy:    changedBy,
+		Reason:       reason,
+	}
+}
+
+// Value implements driver.Valuer interfaces
+func (estimate Estimate) Value() (driver.Value, error) {
+	return json.Marshal(estimate)
+}
+
+// Scan implements sql.Scanner interfaces
+func (estimate *Estimate) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, estimate)
+}
@@ -0,0 +1,124 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package estimation
+
+import (
+	"os"
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/integrationtest"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+var dbHost integrationtest.DBHost
+
+func TestMain(m *testing.M) {
+	dbHost = integrationtest.InitHost("estimation_test")
+	exitCode := m.Run()
+	dbHost.Close()
+	os.Exit(exitCode)
+}
+
+func TestReplaceAndApply(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	initial := facility.Coefficients{DailyInventoryPercentage: 0.01, ProbUnreadToRead: 0.2, ProbInStoreRead: 0.75, ProbExitError: 0.1}
+	if err := facility.Create(testDB.DB, facility.Facility{Name: "store"}, initial, "admin"); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+
+	estimates := []Estimate{
+		{FacilityID: "store", ProductID: "P1", ProbInStoreRead: 0.4, ProbUnreadToRead: 0.1, Samples: 200},
+		{FacilityID: "store", ProbInStoreRead: 0.6, ProbUnreadToRead: 0.3, Samples: 1000},
+		{FacilityID: "unknown", ProbInStoreRead: 0.5, ProbUnreadToRead: 0.5, Samples: 100},
+	}
+	if err := Replace(testDB.DB, estimates); err != nil {
+		t.Fatalf("Unable to replace estimates: %+v", err)
+	}
+
+	stored, err := Retrieve(testDB.DB, "store")
+	if err != nil {
+		t.Fatalf("Unable to retrieve estimates: %+v", err)
+	}
+	if len(stored) != 2 || stored[0] != estimates[1] || stored[1] != estimates[0] {
+		t.Errorf("Expected the estimate of the facility before the one of its product, got %+v", stored)
+	}
+
+	change, err := Apply(testDB.DB, "store", "jdoe", "")
+	if err != nil {
+		t.Fatalf("Unable to apply estimate: %+v", err)
+	}
+	expected := facility.Coefficients{DailyInventoryPercentage: 0.01, ProbUnreadToRead: 0.3, ProbInStoreRead: 0.6, ProbExitError: 0.1}
+	if change.Version != 2 || change.Coefficients != expected || change.ChangedBy != "jdoe" || change.Reason == "" {
+		t.Errorf("Expected version 2 with the estimated read probabilities, got %+v", change)
+	}
+
+	if _, err := Apply(testDB.DB, "unknown", "jdoe", ""); errors.Cause(err) != web.ErrNotFound {
+		t.Errorf("Expected applying the estimate of an unknown facility to fail with not found, got %v", err)
+	}
+	if _, err := Apply(testDB.DB, "nowhere", "jdoe", ""); errors.Cause(err) != web.ErrNotFound {
+		t.Errorf("Expected applying a missing estimate to fail with not found, got %v", err)
+	}
+
+	if err := Replace(testDB.DB, nil); err != nil {
+		t.Fatalf("Unable to replace estimates: %+v", err)
+	}
+	if stored, err := Retrieve(testDB.DB, ""); err != nil || len(stored) != 0 {
+		t.Errorf("Expected the estimates to be replaced, got %+v, %v", stored, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	defaults := config.AppConfig
+	defer func() { config.AppConfig = defaults }()
+	config.AppConfig.CoefficientEstimationDays = 14
+	config.AppConfig.CoefficientEstimationMinimumSamples = 3
+	config.AppConfig.ApplyEstimatedCoefficients = true
+
+	initial := facility.Coefficients{DailyInventoryPercentage: 0.01, ProbUnreadToRead: 0.2, ProbInStoreRead: 0.75, ProbExitError: 0.1}
+	if err := facility.Create(testDB.DB, facility.Facility{Name: "store"}, initial, "admin"); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+
+	// read on days 0, 1, 2, 3 and 7: read again the next day 3 times out of 4, then after 3 days without read
+	now := int64(1551434400000)
+	start := now - 10*millisecondsInDay
+	present := tagevent.State{EpcState: "present", FacilityID: "store"}
+	var events []tagevent.TagEvent
+	for _, day := range []int64{0, 1, 2, 3, 7} {
+		events = append(events, tagevent.TagEvent{Epc: "EPC1", ProductID: "P1", EventType: "cycle_count",
+			Timestamp: start + day*millisecondsInDay, CurrentState: present})
+	}
+	if err := tagevent.Insert(testDB.DB, events); err != nil {
+		t.Fatalf("Unable to insert tag events: %+v", err)
+	}
+
+	estimates, changes, err := Run(testDB.DB, now)
+	if err != nil {
+		t.Fatalf("Unable to estimate coefficients: %+v", err)
+	}
+	if len(estimates) != 1 || estimates[0].ProbInStoreRead != 0.75 || estimates[0].ProbUnreadToRead != 0.444 ||
+		estimates[0].Samples != 4 || estimates[0].Timestamp != now {
+		t.Errorf("Expected the estimate of the facility, got %+v", estimates)
+	}
+	if len(changes) != 1 || changes[0].ChangedBy != ChangedBy || changes[0].Coefficients.ProbUnreadToRead != 0.444 {
+		t.Errorf("Expected the estimate to be applied, got %+v", changes)
+	}
+
+	// the coefficients already are the estimates
+	if _, changes, err = Run(testDB.DB, now); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no change when the estimates did not change, got %+v, %v", changes, err)
+	}
+}
@@ -0,0 +1,47 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package estimation
+
+import (
+	"math"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
+)
+
+// precision the estimates are rounded to, so that noise does not change the coefficients on every estimation
+const precision = 1000
+
+// FromReadGaps estimates the read probabilities from the gaps between the days present tags were read, following
+// the model of the confidence package: a readable tag is read each day with ProbInStoreRead, so a tag read one day
+// is read again the next day with that probability. A tag missed once is unreadable, and is read again each
+// following day with ProbUnreadToRead * ProbInStoreRead, i.e. after a number of days whose mean is the inverse.
+// Present tags missed and not read again yet count as misses, and their following days without read as days
+// they were not read again.
+//
+// False is returned if there are fewer samples than the minimum, or if tags were never, or always, read again
+// the next day, as the probabilities cannot be told apart then.
+func FromReadGaps(gaps tagevent.ReadGaps, minimumSamples int) (Estimate, bool) {
+	samples := gaps.NextDay + gaps.Later + gaps.Missed
+	if samples < int64(minimumSamples) || gaps.NextDay == 0 || gaps.Later == 0 || gaps.UnreadDays == 0 {
+		return Estimate{}, false
+	}
+
+	inStoreRead := float64(gaps.NextDay) / float64(samples)
+	readAgain := float64(gaps.Later) / float64(gaps.UnreadDays)
+
+	return Estimate{
+		FacilityID:       gaps.FacilityID,
+		ProductID:        gaps.ProductID,
+		ProbInStoreRead:  round(inStoreRead),
+		ProbUnreadToRead: round(math.Min(readAgain/inStoreRead, 1)),
+		Samples:          samples,
+	}, true
+}
+
+func round(value float64) float64 {
+	return math.Round(value*precision) / precision
+}
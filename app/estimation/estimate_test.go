@@ -0,0 +1,52 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package estimation
+
+import (
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
+)
+
+func TestFromReadGaps(t *testing.T) {
+	tests := []struct {
+		name       string
+		gaps       tagevent.ReadGaps
+		ok         bool
+		inStore    float64
+		unreadRead float64
+	}{
+		// read again next day 3 times out of 4, and after 4 days without read on average otherwise
+		{"estimated", tagevent.ReadGaps{NextDay: 75, Later: 25, UnreadDays: 100}, true, 0.75, 0.333},
+		// 5 of the misses were not read again yet, after 20 days without read altogether
+		{"censored", tagevent.ReadGaps{NextDay: 75, Later: 20, Missed: 5, UnreadDays: 100}, true, 0.75, 0.267},
+		{"too few samples", tagevent.ReadGaps{NextDay: 7, Later: 2, UnreadDays: 8}, false, 0, 0},
+		{"always read next day", tagevent.ReadGaps{NextDay: 100}, false, 0, 0},
+		{"never read next day", tagevent.ReadGaps{Later: 100, UnreadDays: 300}, false, 0, 0},
+		// read again as soon as missed, more often than read at all
+		{"clamped", tagevent.ReadGaps{NextDay: 10, Later: 90, UnreadDays: 90}, true, 0.1, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.gaps.FacilityID, test.gaps.ProductID = "store", "product"
+			estimate, ok := FromReadGaps(test.gaps, 10)
+			if ok != test.ok {
+				t.Fatalf("expected estimated to be %v, got %v", test.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if estimate.ProbInStoreRead != test.inStore || estimate.ProbUnreadToRead != test.unreadRead {
+				t.Errorf("expected %v and %v, got %+v", test.inStore, test.unreadRead, estimate)
+			}
+			if estimate.FacilityID != "store" || estimate.ProductID != "product" || estimate.Samples != test.gaps.NextDay+test.gaps.Later+test.gaps.Missed {
+				t.Errorf("expected the estimate of the product with its samples, got %+v", estimate)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package estimation
+
+import (
+	"database/sql"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ChangedBy is who the coefficients changes applying the estimates automatically are recorded as
+	ChangedBy         = "coefficient estimation"
+	millisecondsInDay = 24 * 60 * 60 * 1000
+)
+
+// Run estimates the read probabilities of every facility, and of every product if configured, from the reads of
+// the configured number of days before the millisecond epoch now, and replaces the previous estimates.
+// If configured, the estimates of the facilities are applied to their coefficients, unless already equal.
+// The estimates and the coefficients changes are returned.
+func Run(dbs *sql.DB, now int64) ([]Estimate, []facility.CoefficientsVersion, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.EstimateCoefficients.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.EstimateCoefficients.Success`, nil)
+	mEstimateErr := metrics.GetOrRegisterGauge(`Inventory.EstimateCoefficients.Estimate-Error`, nil)
+	mEstimates := metrics.GetOrRegisterGauge(`Inventory.EstimateCoefficients.Estimates`, nil)
+	mApplied := metrics.GetOrRegisterGauge(`Inventory.EstimateCoefficients.Applied`, nil)
+
+	since := now - int64(config.AppConfig.CoefficientEstimationDays)*millisecondsInDay
+	dayStarts, err := businessDayStarts(dbs, since, now)
+	if err != nil {
+		mEstimateErr.Update(1)
+		return nil, nil, err
+	}
+	gaps, err := tagevent.RetrieveReadGaps(dbs, since, now, dayStarts, false)
+	if err != nil {
+		mEstimateErr.Update(1)
+		return nil, nil, err
+	}
+	if config.AppConfig.CoefficientEstimationByProduct {
+		productGaps, err := tagevent.RetrieveReadGaps(dbs, since, now, dayStarts, true)
+		if err != nil {
+			mEstimateErr.Update(1)
+			return nil, nil, err
+		}
+		gaps = append(gaps, productGaps...)
+	}
+
+	estimates := make([]Estimate, 0, len(gaps))
+	for _, group := range gaps {
+		if estimate, ok := FromReadGaps(group, config.AppConfig.CoefficientEstimationMinimumSamples); ok {
+			estimate.Since, estimate.Timestamp = since, now
+			estimates = append(estimates, estimate)
+		}
+	}
+	if err := Replace(dbs, estimates); err != nil {
+		mEstimateErr.Update(1)
+		return nil, nil, err
+	}
+	mEstimates.Update(int64(len(estimates)))
+
+	var changes []facility.CoefficientsVersion
+	if config.AppConfig.ApplyEstimatedCoefficients {
+		if changes, err = applyAll(dbs, estimates); err != nil {
+			mEstimateErr.Update(1)
+			return estimates, changes, err
+		}
+		mApplied.Update(int64(len(changes)))
+	}
+
+	mSuccess.Update(1)
+	return estimates, changes, nil
+}
+
+// businessDayStarts returns the starts of the business days from since to now of the facilities with a time zone
+// or business hours, whose reads are grouped by business day rather than by UTC day
+func businessDayStarts(dbs *sql.DB, since int64, now int64) (map[string][]int64, error) {
+	facilities, err := facility.CreateFacilityMap(dbs)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find the business days of the facilities")
+	}
+
+	dayStarts := make(map[string][]int64)
+	for name, site := range facilities {
+		if site.Timezone == "" && site.BusinessHours == nil {
+			continue
+		}
+		for _, day := range site.DayBuckets(since, now) {
+			dayStarts[name] = append(dayStarts[name], day.Start)
+		}
+	}
+	return dayStarts, nil
+}
+
+// applyAll applies the estimates of the known facilities whose coefficients differ
+func applyAll(dbs *sql.DB, estimates []Estimate) ([]facility.CoefficientsVersion, error) {
+	facilities, err := facility.CreateFacilityMap(dbs)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []facility.CoefficientsVersion
+	for _, estimate := range estimates {
+		site, found := facilities[estimate.FacilityID]
+		if !found || estimate.ProductID != "" ||
+			(site.Coefficients.ProbInStoreRead == estimate.ProbInStoreRead &&
+				site.Coefficients.ProbUnreadToRead == estimate.ProbUnreadToRead) {
+			continue
+		}
+
+		change, err := facility.ChangeCoefficients(dbs, changeOf(site, estimate, ChangedBy, ""))
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
@@ -0,0 +1,58 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package estimation
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/confidence"
+)
+
+// Estimate is the read probabilities of a facility, or of a product in a facility, estimated from the reads
+type Estimate struct {
+	// Facility name
+	FacilityID string `json:"facility_id"`
+	// Product ID, empty for the estimate of the whole facility
+	ProductID string `json:"product_id,omitempty"`
+	// Estimated probability of a tag in the store being read each day
+	ProbInStoreRead float64 `json:"probinstoreread"`
+	// Estimated probability of an unreadable tag becoming readable again each day
+	ProbUnreadToRead float64 `json:"probunreadtoread"`
+	// Number of gaps between reads the estimate is based on
+	Samples int64 `json:"samples"`
+	// Start of the reads the estimate is based on in milliseconds epoch
+	Since int64 `json:"since"`
+	// Time of the estimation in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Comparison is an estimate with the coefficients currently used for the tags it was estimated from
+type Comparison struct {
+	Estimate
+	// Coefficients in use, with their source: facility, default or product
+	Configured Configured `json:"configured"`
+}
+
+// Configured is the read probabilities currently used to calculate confidence
+type Configured struct {
+	// Probability of a tag in the store being read each day
+	ProbInStoreRead confidence.Input `json:"probinstoreread"`
+	// Probability of an unreadable tag becoming readable again each day
+	ProbUnreadToRead confidence.Input `json:"probunreadtoread"`
+	// Version of the facility coefficients, if any of them is used
+	CoefficientsVersion int64 `json:"coefficients_version,omitempty"`
+}
+
+// ApplyRequestBody represents a struct for the requestBody to apply the estimate of a facility to its coefficients
+type ApplyRequestBody struct {
+	FacilityID string `json:"facility_id"`
+	ChangedBy  string `json:"changed_by"`
+	Reason     string `json:"reason"`
+}
+
+// Response is the estimates compared to the configured coefficients
+type Response struct {
+	Results []Comparison `json:"results"`
+}
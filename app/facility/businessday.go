@@ -0,0 +1,141 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package facility
+
+import (
+	"sync"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+// businessDayFormat is the format of the local date of a business day
+const businessDayFormat = "2006-01-02"
+
+// hoursFormat is the format of the local opening and closing times of a facility
+const hoursFormat = "15:04"
+
+// BusinessHours are the local opening and closing times of a facility, e.g. 09:00 and 21:00.
+// A facility closing at or before its opening time closes after midnight.
+type BusinessHours struct {
+	// Local opening time, HH:MM
+	Open string `json:"open"`
+	// Local closing time, HH:MM
+	Close string `json:"close"`
+}
+
+// DayBucket is a business day of a facility
+type DayBucket struct {
+	// Local date of the business day, YYYY-MM-DD
+	Day string `json:"day"`
+	// Millisecond epoch the business day starts at
+	Start int64 `json:"start"`
+	// Millisecond epoch the next business day starts at
+	End int64 `json:"end"`
+}
+
+// locations caches the time zones of the facilities by name, since loading one reads the time zone database
+var locations sync.Map
+
+// Location returns the time zone of the facility, UTC if it has none
+func (facility Facility) Location() *time.Location {
+	if facility.Timezone == "" {
+		return time.UTC
+	}
+	if location, found := locations.Load(facility.Timezone); found {
+		return location.(*time.Location)
+	}
+	location, err := time.LoadLocation(facility.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	locations.Store(facility.Timezone, location)
+	return location
+}
+
+// BusinessDay returns the local date of the business day of the millisecond epoch.
+// Business days start at midnight, or at the closing time of facilities closing after midnight.
+func (facility Facility) BusinessDay(millis int64) string {
+	return facility.businessDate(millis).Format(businessDayFormat)
+}
+
+// BusinessDaysAgoStart returns the millisecond epoch the business day the given number of days before the
+// business day of now starts at
+func (facility Facility) BusinessDaysAgoStart(now int64, days int) int64 {
+	return facility.dayStart(facility.businessDate(now).AddDate(0, 0, -days))
+}
+
+// DayBuckets returns the business days from the one of the from millisecond epoch to the one of the to
+// millisecond epoch, both included
+func (facility Facility) DayBuckets(from int64, to int64) []DayBucket {
+	var buckets []DayBucket
+	last := facility.businessDate(to)
+	for day := facility.businessDate(from); !day.After(last); day = day.AddDate(0, 0, 1) {
+		buckets = append(buckets, DayBucket{
+			Day:   day.Format(businessDayFormat),
+			Start: facility.dayStart(day),
+			End:   facility.dayStart(day.AddDate(0, 0, 1)),
+		})
+	}
+	return buckets
+}
+
+// DaysBetween returns the number of days between the millisecond epochs on the local clock of the facility,
+// so that a day with a daylight saving time change still counts as one day
+func (facility Facility) DaysBetween(from int64, to int64) float64 {
+	location := facility.Location()
+	return wallClock(to, location).Sub(wallClock(from, location)).Hours() / 24
+}
+
+// businessDate returns the local date of the business day of the millisecond epoch, at midnight UTC
+func (facility Facility) businessDate(millis int64) time.Time {
+	local := wallClock(millis, facility.Location()).Add(-facility.dayBoundary())
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dayStart returns the millisecond epoch the business day of the local date starts at
+func (facility Facility) dayStart(date time.Time) int64 {
+	boundary := facility.dayBoundary()
+	start := time.Date(date.Year(), date.Month(), date.Day(),
+		int(boundary/time.Hour), int(boundary%time.Hour/time.Minute), 0, 0, facility.Location())
+	return start.UnixNano() / int64(time.Millisecond)
+}
+
+// dayBoundary returns the local time of day business days start at: the closing time of facilities
+// closing after midnight, otherwise midnight
+func (facility Facility) dayBoundary() time.Duration {
+	if facility.BusinessHours == nil {
+		return 0
+	}
+	open, closing, err := facility.BusinessHours.parse()
+	if err != nil || open < closing {
+		return 0
+	}
+	return closing
+}
+
+// parse returns the opening and closing times as durations since midnight
+func (hours BusinessHours) parse() (time.Duration, time.Duration, error) {
+	open, err := time.Parse(hoursFormat, hours.Open)
+	if err != nil {
+		return 0, 0, errors.Wrapf(web.ErrValidation, "invalid opening time %s", hours.Open)
+	}
+	closing, err := time.Parse(hoursFormat, hours.Close)
+	if err != nil {
+		return 0, 0, errors.Wrapf(web.ErrValidation, "invalid closing time %s", hours.Close)
+	}
+	return open.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)), closing.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)), nil
+}
+
+// wallClock returns the local time of the millisecond epoch as if it were UTC, so that durations between
+// wall clock times ignore daylight saving time changes
+func wallClock(millis int64, location *time.Location) time.Time {
+	local := time.Unix(0, millis*int64(time.Millisecond)).In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(),
+		local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,95 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package facility
+
+import (
+	"testing"
+	"time"
+)
+
+func millis(t *testing.T, value string) int64 {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("Unable to parse time %s: %s", value, err)
+	}
+	return parsed.UnixNano() / int64(time.Millisecond)
+}
+
+func TestBusinessDay(t *testing.T) {
+	losAngeles := Facility{Name: "store1", Timezone: "America/Los_Angeles"}
+	lateNight := Facility{Name: "store2", Timezone: "America/New_York", BusinessHours: &BusinessHours{Open: "10:00", Close: "02:00"}}
+
+	tests := []struct {
+		name     string
+		facility Facility
+		time     string
+		day      string
+		start    string
+	}{
+		{"utc", Facility{}, "2019-11-05T03:00:00Z", "2019-11-05", "2019-11-05T00:00:00Z"},
+		{"evening in los angeles", losAngeles, "2019-11-05T03:00:00Z", "2019-11-04", "2019-11-04T08:00:00Z"},
+		{"after midnight before closing", lateNight, "2019-11-05T06:30:00Z", "2019-11-04", "2019-11-04T07:00:00Z"},
+		{"after closing", lateNight, "2019-11-05T07:30:00Z", "2019-11-05", "2019-11-05T07:00:00Z"},
+	}
+
+	for _, test := range tests {
+		if day := test.facility.BusinessDay(millis(t, test.time)); day != test.day {
+			t.Errorf("%s: expected business day %s, got %s", test.name, test.day, day)
+		}
+		if start := test.facility.DayBuckets(millis(t, test.time), millis(t, test.time))[0].Start; start != millis(t, test.start) {
+			t.Errorf("%s: expected business day to start at %s, got %s", test.name, test.start,
+				time.Unix(0, start*int64(time.Millisecond)).UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+func TestDaysBetween(t *testing.T) {
+	losAngeles := Facility{Name: "store1", Timezone: "America/Los_Angeles"}
+
+	// daylight saving time ends on November 3rd, so the local day is 25 hours long
+	from, to := millis(t, "2019-11-03T07:00:00Z"), millis(t, "2019-11-04T08:00:00Z")
+	if days := losAngeles.DaysBetween(from, to); days != 1 {
+		t.Errorf("expected 1 day on the local clock, got %v", days)
+	}
+	if days := (Facility{}).DaysBetween(from, to); days != 25.0/24 {
+		t.Errorf("expected 25 hours in UTC, got %v days", days)
+	}
+}
+
+func TestBusinessDaysAgoStart(t *testing.T) {
+	losAngeles := Facility{Name: "store1", Timezone: "America/Los_Angeles"}
+
+	start := losAngeles.BusinessDaysAgoStart(millis(t, "2019-11-05T03:00:00Z"), 2)
+	if start != millis(t, "2019-11-02T07:00:00Z") {
+		t.Errorf("expected the business day of November 2nd, got %s", time.Unix(0, start*int64(time.Millisecond)).UTC())
+	}
+}
+
+func TestDayBuckets(t *testing.T) {
+	losAngeles := Facility{Name: "store1", Timezone: "America/Los_Angeles"}
+
+	buckets := losAngeles.DayBuckets(millis(t, "2019-11-02T12:00:00Z"), millis(t, "2019-11-04T12:00:00Z"))
+	if len(buckets) != 3 {
+		t.Fatalf("expected 3 business days, got %+v", buckets)
+	}
+	if buckets[1].Day != "2019-11-03" || buckets[1].End-buckets[1].Start != int64(25*time.Hour/time.Millisecond) {
+		t.Errorf("expected the 25 hour business day of November 3rd, got %+v", buckets[1])
+	}
+	if buckets[0].End != buckets[1].Start || buckets[1].End != buckets[2].Start {
+		t.Errorf("expected contiguous business days, got %+v", buckets)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	losAngeles := Facility{Name: "store1", Timezone: "America/Los_Angeles"}
+	if location := losAngeles.Location(); location.String() != "America/Los_Angeles" || location != losAngeles.Location() {
+		t.Errorf("expected the cached time zone of the facility, got %v", location)
+	}
+	if location := (Facility{Timezone: "Nowhere/Special"}).Location(); location != time.UTC {
+		t.Errorf("expected UTC for an unknown time zone, got %v", location)
+	}
+}
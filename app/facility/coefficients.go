@@ -0,0 +1,271 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package facility
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	coefficientsHistoryTable  = "coefficients_history"
+	facilityIDColumn          = "facility_id"
+	versionColumn             = "version"
+	coefficientsVersionColumn = "coefficients_version"
+)
+
+// ChangeCoefficients replaces the coefficients of the facility of the change and records the change as the next
+// version in the coefficients history. The recorded change is returned.
+func ChangeCoefficients(dbs *sql.DB, change CoefficientsVersion) (CoefficientsVersion, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Update-Facility.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Update-Facility.Success`, nil)
+	mUpdateErr := metrics.GetOrRegisterGauge(`Inventory.Update-Facility.Update-Error`, nil)
+	mErrNotFound := metrics.GetOrRegisterGauge(`Inventory.Update-Facility.NotFound-Error`, nil)
+	mUpdateLatency := metrics.GetOrRegisterTimer(`Inventory.Update-Facility.Update-Latency`, nil)
+
+	updateTimer := time.Now()
+	transaction, err := dbs.Begin()
+	if err != nil {
+		mUpdateErr.Update(1)
+		return change, errors.Wrap(err, "unable to begin transaction")
+	}
+
+	change, err = changeCoefficientsInTransaction(transaction, change)
+	if err != nil {
+		if err == web.ErrNotFound {
+			mErrNotFound.Update(1)
+		} else {
+			mUpdateErr.Update(1)
+		}
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return change, errors.Wrap(rollbackErr, err.Error())
+		}
+		return change, err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		mUpdateErr.Update(1)
+		return change, errors.Wrap(err, "unable to commit coefficients")
+	}
+	mUpdateLatency.Update(time.Since(updateTimer))
+
+	mSuccess.Update(1)
+	return change, nil
+}
+
+// RollbackCoefficients sets the coefficients of the facility back to the ones of the given version. The rollback
+// is recorded as a new version, so the history is never rewritten.
+func RollbackCoefficients(dbs *sql.DB, facilityID string, version int64, changedBy string, reason string) (CoefficientsVersion, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.RollbackCoefficients.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.RollbackCoefficients.Success`, nil)
+	mRollbackErr := metrics.GetOrRegisterGauge(`Inventory.RollbackCoefficients.Rollback-Error`, nil)
+
+	previous, err := FindCoefficientsVersion(dbs, facilityID, version)
+	if err != nil {
+		mRollbackErr.Update(1)
+		return CoefficientsVersion{}, err
+	}
+
+	change, err := ChangeCoefficients(dbs, CoefficientsVersion{
+		FacilityID:   facilityID,
+		Coefficients: previous.Coefficients,
+		ChangedBy:    changedBy,
+		Reason:       reason,
+		RolledBackTo: version,
+	})
+	if err != nil {
+		mRollbackErr.Update(1)
+		return change, err
+	}
+
+	mSuccess.Update(1)
+	return change, nil
+}
+
+// FindCoefficientsVersion returns the given version of the coefficients of the facility.
+// The error is web.ErrNotFound if the facility has no such version.
+func FindCoefficientsVersion(dbs *sql.DB, facilityID string, version int64) (CoefficientsVersion, error) {
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s WHERE %s ->> %s = %s AND (%s ->> %s)::BIGINT = %d;`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(coefficientsHistoryTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(facilityIDColumn),
+		pq.QuoteLiteral(facilityID),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(versionColumn),
+		version,
+	)
+
+	var found CoefficientsVersion
+	if err := dbs.QueryRow(selectQuery).Scan(&found); err != nil {
+		if err == sql.ErrNoRows {
+			return found, web.ErrNotFound
+		}
+		return found, errors.Wrap(err, "error in finding coefficients version")
+	}
+	return found, nil
+}
+
+// RetrieveCoefficientsHistory retrieves the changes of the coefficients of the facility, most recent first
+func RetrieveCoefficientsHistory(dbs *sql.DB, facilityID string, maxSize int) ([]CoefficientsVersion, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.RetrieveCoefficientsHistory.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.RetrieveCoefficientsHistory.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge(`Inventory.RetrieveCoefficientsHistory.Find-Error`, nil)
+
+	if facilityID == "" {
+		return nil, errors.Wrap(web.ErrValidation, "facility_id is required")
+	}
+
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s WHERE %s ->> %s = %s ORDER BY (%s ->> %s)::BIGINT DESC LIMIT %d;`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(coefficientsHistoryTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(facilityIDColumn),
+		pq.QuoteLiteral(facilityID),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(versionColumn),
+		maxSize,
+	)
+
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mFindErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving coefficients history")
+	}
+	defer rows.Close()
+
+	history := make([]CoefficientsVersion, 0)
+	for rows.Next() {
+		var version CoefficientsVersion
+		if err := rows.Scan(&version); err != nil {
+			mFindErr.Update(1)
+			return nil, err
+		}
+		history = append(history, version)
+	}
+	if err = rows.Err(); err != nil {
+		mFindErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return history, nil
+}
+
+// changeCoefficientsInTransaction locks the facility, so that concurrent changes get consecutive versions,
+// replaces its coefficients and records the change
+func changeCoefficientsInTransaction(transaction *sql.Tx, change CoefficientsVersion) (CoefficientsVersion, error) {
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s WHERE %s ->> %s = %s FOR UPDATE;`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(facilitiesTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(nameColumn),
+		pq.QuoteLiteral(change.FacilityID),
+	)
+
+	var current Facility
+	if err := transaction.QueryRow(selectQuery).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return change, web.ErrNotFound
+		}
+		return change, errors.Wrap(err, "error in finding facility")
+	}
+
+	change.Version = current.CoefficientsVersion + 1
+	change.Timestamp = helper.UnixMilliNow()
+
+	coefficients, err := json.Marshal(change.Coefficients)
+	if err != nil {
+		return change, err
+	}
+
+	updateStmt := fmt.Sprintf(`UPDATE %s SET %s = %s || jsonb_build_object(%s, %s::JSONB, %s, %d)
+					WHERE %s ->> %s = %s;`,
+		pq.QuoteIdentifier(facilitiesTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(coefficientsColumn),
+		pq.QuoteLiteral(string(coefficients)),
+		pq.QuoteLiteral(coefficientsVersionColumn),
+		change.Version,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(nameColumn),
+		pq.QuoteLiteral(change.FacilityID),
+	)
+
+	if _, err := transaction.Exec(updateStmt); err != nil {
+		return change, errors.Wrap(err, "error in updating coefficients")
+	}
+	return change, insertCoefficientsVersion(transaction, change)
+}
+
+// nextCoefficientsVersion returns the version following the last one recorded for the facility. The history
+// outlives the facility, so that a facility deleted and created again keeps numbering its versions.
+func nextCoefficientsVersion(transaction *sql.Tx, facilityID string) (int64, error) {
+	selectQuery := fmt.Sprintf(`SELECT COALESCE(MAX((%s ->> %s)::BIGINT), 0) + 1 FROM %s WHERE %s ->> %s = %s;`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(versionColumn),
+		pq.QuoteIdentifier(coefficientsHistoryTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(facilityIDColumn),
+		pq.QuoteLiteral(facilityID),
+	)
+
+	var version int64
+	if err := transaction.QueryRow(selectQuery).Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "error in finding the last coefficients version")
+	}
+	return version, nil
+}
+
+func insertCoefficientsVersion(transaction *sql.Tx, version CoefficientsVersion) error {
+	obj, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+
+	insertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s);`,
+		pq.QuoteIdentifier(coefficientsHistoryTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(string(obj)),
+	)
+
+	if _, err := transaction.Exec(insertStmt); err != nil {
+		return errors.Wrap(err, "error in inserting coefficients history")
+	}
+	return nil
+}
+
+// Value implements driver.Valuer interfaces
+func (version CoefficientsVersion) Value() (driver.Value, error) {
+	return json.Marshal(version)
+}
+
+// Scan implements sql.Scanner interfaces
+func (version *CoefficientsVersion) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, version)
+}
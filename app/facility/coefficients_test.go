@@ -0,0 +1,148 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package facility
+
+import (
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+)
+
+func TestCoefficientsHistory(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	initial := Coefficients{DailyInventoryPercentage: 0.01, ProbUnreadToRead: 0.2, ProbInStoreRead: 0.75, ProbExitError: 0.1}
+	if err := Create(testDB.DB, Facility{Name: "store1"}, initial, "admin"); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+
+	changed := Coefficients{DailyInventoryPercentage: 0.5, ProbUnreadToRead: 0.5, ProbInStoreRead: 0.5, ProbExitError: 0.5}
+	change, err := ChangeCoefficients(testDB.DB, CoefficientsVersion{FacilityID: "store1", Coefficients: changed, ChangedBy: "jdoe", Reason: "test"})
+	if err != nil {
+		t.Fatalf("Unable to change coefficients: %+v", err)
+	}
+	if change.Version != 2 || change.Timestamp == 0 {
+		t.Errorf("expected the change to be version 2 with a timestamp, got %+v", change)
+	}
+
+	rollback, err := RollbackCoefficients(testDB.DB, "store1", 1, "jdoe", "undo")
+	if err != nil {
+		t.Fatalf("Unable to roll back coefficients: %+v", err)
+	}
+	if rollback.Version != 3 || rollback.RolledBackTo != 1 || rollback.Coefficients != initial {
+		t.Errorf("expected version 3 with the initial coefficients, got %+v", rollback)
+	}
+
+	facilities, err := CreateFacilityMap(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to find facilities: %+v", err)
+	}
+	if current := facilities["store1"]; current.CoefficientsVersion != 3 || current.Coefficients != initial {
+		t.Errorf("expected the facility at version 3 with the initial coefficients, got %+v", current)
+	}
+
+	history, err := RetrieveCoefficientsHistory(testDB.DB, "store1", 10)
+	if err != nil {
+		t.Fatalf("Unable to retrieve coefficients history: %+v", err)
+	}
+	if len(history) != 3 || history[0].Version != 3 || history[2].Version != 1 || history[2].ChangedBy != "admin" {
+		t.Errorf("expected versions 3 to 1, most recent first, got %+v", history)
+	}
+
+	if _, err := RollbackCoefficients(testDB.DB, "store1", 7, "jdoe", ""); err != web.ErrNotFound {
+		t.Errorf("expected rolling back to an unknown version to fail with not found, got %v", err)
+	}
+	if _, err := ChangeCoefficients(testDB.DB, CoefficientsVersion{FacilityID: "store2", Coefficients: changed}); err != web.ErrNotFound {
+		t.Errorf("expected changing the coefficients of an unknown facility to fail with not found, got %v", err)
+	}
+}
+
+func TestCoefficientsHistoryOfRecreatedFacility(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	initial := Coefficients{DailyInventoryPercentage: 0.01, ProbUnreadToRead: 0.2, ProbInStoreRead: 0.75, ProbExitError: 0.1}
+	if err := Create(testDB.DB, Facility{Name: "store1"}, initial, "admin"); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+	changed := Coefficients{DailyInventoryPercentage: 0.5, ProbUnreadToRead: 0.5, ProbInStoreRead: 0.5, ProbExitError: 0.5}
+	if _, err := ChangeCoefficients(testDB.DB, CoefficientsVersion{FacilityID: "store1", Coefficients: changed}); err != nil {
+		t.Fatalf("Unable to change coefficients: %+v", err)
+	}
+	if err := Delete(testDB.DB, "store1"); err != nil {
+		t.Fatalf("Unable to delete facility: %+v", err)
+	}
+
+	// the facility created again continues the history it left behind
+	if err := Create(testDB.DB, Facility{Name: "store1"}, initial, "admin"); err != nil {
+		t.Fatalf("Unable to create the facility again: %+v", err)
+	}
+	change, err := ChangeCoefficients(testDB.DB, CoefficientsVersion{FacilityID: "store1", Coefficients: changed})
+	if err != nil {
+		t.Fatalf("Unable to change coefficients: %+v", err)
+	}
+	if change.Version != 4 {
+		t.Errorf("expected the change to be version 4, got %+v", change)
+	}
+
+	history, err := RetrieveCoefficientsHistory(testDB.DB, "store1", 10)
+	if err != nil {
+		t.Fatalf("Unable to retrieve coefficients history: %+v", err)
+	}
+	if len(history) != 4 || history[1].Version != 3 || history[1].Reason != "facility created" {
+		t.Errorf("expected versions 4 to 1, the facility created again at version 3, got %+v", history)
+	}
+
+	if err := Delete(testDB.DB, "store1"); err != nil {
+		t.Fatalf("Unable to delete facility: %+v", err)
+	}
+	if err := Register(testDB.DB, "store1"); err != nil {
+		t.Fatalf("Unable to register the facility again: %+v", err)
+	}
+	facilities, err := CreateFacilityMap(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to find facilities: %+v", err)
+	}
+	if registered := facilities["store1"]; registered.CoefficientsVersion != 5 {
+		t.Errorf("expected the registered facility at version 5, got %+v", registered)
+	}
+}
+
+func TestCoefficientsHistoryAfterMetadataUpdate(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	initial := Coefficients{DailyInventoryPercentage: 0.01, ProbUnreadToRead: 0.2, ProbInStoreRead: 0.75, ProbExitError: 0.1}
+	if err := Create(testDB.DB, Facility{Name: "store1"}, initial, "admin"); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+	changed := Coefficients{DailyInventoryPercentage: 0.5, ProbUnreadToRead: 0.5, ProbInStoreRead: 0.5, ProbExitError: 0.5}
+	if _, err := ChangeCoefficients(testDB.DB, CoefficientsVersion{FacilityID: "store1", Coefficients: changed}); err != nil {
+		t.Fatalf("Unable to change coefficients: %+v", err)
+	}
+
+	// the metadata update carries no version and must keep the stored one
+	if err := Update(testDB.DB, Facility{Name: "store1", DisplayName: "Store 1", Timezone: "UTC"}); err != nil {
+		t.Fatalf("Unable to update facility: %+v", err)
+	}
+	facilities, err := CreateFacilityMap(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to find facilities: %+v", err)
+	}
+	if updated := facilities["store1"]; updated.CoefficientsVersion != 2 || updated.Coefficients != changed || updated.DisplayName != "Store 1" {
+		t.Errorf("expected the updated facility at version 2 with the changed coefficients, got %+v", updated)
+	}
+
+	change, err := ChangeCoefficients(testDB.DB, CoefficientsVersion{FacilityID: "store1", Coefficients: initial})
+	if err != nil {
+		t.Fatalf("Unable to change coefficients after the metadata update: %+v", err)
+	}
+	if change.Version != 3 {
+		t.Errorf("expected the change to be version 3, got %+v", change)
+	}
+}
@@ -18,10 +18,16 @@ import (
 
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"github.com/pkg/errors"
 )
 
-const facilitiesTable = "facilities"
+const (
+	facilitiesTable = "facilities"
+	// sensorsTable and tagsTable are checked for what is still in a facility before deleting it
+	sensorsTable = "rspconfig"
+	tagsTable    = "tags"
+)
 const jsonb = "data"
 const nameColumn = "name"
 const coefficientsColumn = "coefficients"
@@ -31,54 +37,11 @@ type facilityDataWrapper struct {
 	Data Facility `db:"data" json:"data"`
 }
 
-// Update receives a facility_id(name) and body to be updated in the facility collection
+// UpdateCoefficients replaces the coefficients of the facility with the name of the given facility. The change
+// is recorded in the coefficients history, without who made it or why.
 func UpdateCoefficients(dbs *sql.DB, facility Facility) error {
-
-	// Metrics
-	metrics.GetOrRegisterGauge(`Inventory.Update-Facility.Attempt`, nil).Update(1)
-	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Update-Facility.Success`, nil)
-	mUpdateErr := metrics.GetOrRegisterGauge(`Inventory.Update-Facility.Update-Error`, nil)
-	mErrNotFound := metrics.GetOrRegisterGauge(`Inventory.Update-Facility.NotFound-Error`, nil)
-	mUpdateLatency := metrics.GetOrRegisterTimer(`Inventory.Update-Facility.Update-Latency`, nil)
-
-	coefficients, err := json.Marshal(facility.Coefficients)
-	if err != nil {
-		return err
-	}
-
-	upsertClause := fmt.Sprintf(`UPDATE %s SET %s = jsonb_set(%s, '{%s}', %s)
-					WHERE %s ->> %s = %s`,
-		pq.QuoteIdentifier(facilitiesTable),
-		pq.QuoteIdentifier(jsonb),
-		pq.QuoteIdentifier(jsonb),
-		pq.QuoteIdentifier(coefficientsColumn),
-		pq.QuoteLiteral(string(coefficients)),
-		pq.QuoteIdentifier(jsonb),
-		pq.QuoteLiteral(nameColumn),
-		pq.QuoteLiteral(facility.Name),
-	)
-
-	updateTimer := time.Now()
-	result, err := dbs.Exec(upsertClause)
-	if err != nil {
-		mUpdateErr.Update(1)
-		return err
-	}
-	updatedRow, err := result.RowsAffected()
-
-	if err != nil {
-		mUpdateErr.Update(1)
-		return err
-	}
-	if updatedRow == 0 {
-		mErrNotFound.Update(1)
-		return web.ErrNotFound
-	}
-
-	mUpdateLatency.Update(time.Since(updateTimer))
-
-	mSuccess.Update(1)
-	return nil
+	_, err := ChangeCoefficients(dbs, CoefficientsVersion{FacilityID: facility.Name, Coefficients: facility.Coefficients})
+	return err
 }
 
 // Retrieve retrieves All facilities from database
@@ -211,6 +174,137 @@ func Insert(dbs *sql.DB, facilities *[]Facility, coefficients Coefficients) erro
 	return nil
 }
 
+// Create inserts a new facility. A facility without coefficients gets the default coefficients.
+// The error is caused by web.ErrConflict if a facility with the same name exists.
+func Create(dbs *sql.DB, facility Facility, coefficients Coefficients, changedBy string) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Create-Facility.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Create-Facility.Success`, nil)
+	mValidationErr := metrics.GetOrRegisterGauge(`Inventory.Create-Facility.Validation-Error`, nil)
+	mConflictErr := metrics.GetOrRegisterGauge(`Inventory.Create-Facility.Conflict-Error`, nil)
+	mInsertErr := metrics.GetOrRegisterGauge(`Inventory.Create-Facility.Insert-Error`, nil)
+	mInsertLatency := metrics.GetOrRegisterTimer(`Inventory.Create-Facility.Insert-Latency`, nil)
+
+	if err := validateMetadata(facility); err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if facility.Coefficients == (Coefficients{}) {
+		facility.Coefficients = coefficients
+	}
+
+	insertTimer := time.Now()
+	inserted, err := insertWithHistory(dbs, facility, changedBy, "facility created")
+	if err != nil {
+		mInsertErr.Update(1)
+		return errors.Wrap(err, "error in creating facility")
+	}
+	if !inserted {
+		mConflictErr.Update(1)
+		return errors.Wrapf(web.ErrConflict, "facility %s already exists", facility.Name)
+	}
+	mInsertLatency.Update(time.Since(insertTimer))
+
+	markRegistered(facility.Name)
+	mSuccess.Update(1)
+	return nil
+}
+
+// Update replaces the metadata of a facility, i.e. everything but its coefficients
+func Update(dbs *sql.DB, facility Facility) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Update-Facility-Metadata.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Update-Facility-Metadata.Success`, nil)
+	mValidationErr := metrics.GetOrRegisterGauge(`Inventory.Update-Facility-Metadata.Validation-Error`, nil)
+	mUpdateErr := metrics.GetOrRegisterGauge(`Inventory.Update-Facility-Metadata.Update-Error`, nil)
+	mErrNotFound := metrics.GetOrRegisterGauge(`Inventory.Update-Facility-Metadata.NotFound-Error`, nil)
+	mUpdateLatency := metrics.GetOrRegisterTimer(`Inventory.Update-Facility-Metadata.Update-Latency`, nil)
+
+	if err := validateMetadata(facility); err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+
+	// the coefficients and their version in the database are kept by removing them from the new data
+	obj, err := json.Marshal(facility)
+	if err != nil {
+		return err
+	}
+
+	updateStmt := fmt.Sprintf(`UPDATE %s SET %s = %s || (%s::JSONB - %s - %s)
+					WHERE %s ->> %s = %s;`,
+		pq.QuoteIdentifier(facilitiesTable),
+		pq.QuoteIdentifier(jsonb),
+		metadataRemoved(pq.QuoteIdentifier(jsonb)),
+		pq.QuoteLiteral(string(obj)),
+		pq.QuoteLiteral(coefficientsColumn),
+		pq.QuoteLiteral(coefficientsVersionColumn),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(nameColumn),
+		pq.QuoteLiteral(facility.Name),
+	)
+
+	updateTimer := time.Now()
+	result, err := dbs.Exec(updateStmt)
+	if err != nil {
+		mUpdateErr.Update(1)
+		return errors.Wrap(err, "error in updating facility")
+	}
+	updatedRow, err := result.RowsAffected()
+	if err != nil {
+		mUpdateErr.Update(1)
+		return err
+	}
+	if updatedRow == 0 {
+		mErrNotFound.Update(1)
+		return web.ErrNotFound
+	}
+	mUpdateLatency.Update(time.Since(updateTimer))
+
+	mSuccess.Update(1)
+	return nil
+}
+
+// metadataRemoved is the JSONB expression of the column without the optional metadata, so that metadata
+// left out of an update is cleared
+func metadataRemoved(column string) string {
+	return fmt.Sprintf("(%s - %s - %s - %s - %s - %s - %s)",
+		column,
+		pq.QuoteLiteral("display_name"),
+		pq.QuoteLiteral("timezone"),
+		pq.QuoteLiteral("address"),
+		pq.QuoteLiteral("type"),
+		pq.QuoteLiteral("business_hours"),
+		pq.QuoteLiteral("probably_missing_threshold"),
+	)
+}
+
+// validateMetadata returns an error caused by web.ErrValidation if the metadata of the facility is invalid
+func validateMetadata(facility Facility) error {
+	if facility.Name == "" {
+		return errors.Wrap(web.ErrValidation, "facility name is required")
+	}
+	if facility.Timezone != "" {
+		if _, err := time.LoadLocation(facility.Timezone); err != nil {
+			return errors.Wrapf(web.ErrValidation, "unknown timezone %s", facility.Timezone)
+		}
+	}
+	if facility.BusinessHours != nil {
+		if _, _, err := facility.BusinessHours.parse(); err != nil {
+			return err
+		}
+	}
+	if facility.Type != "" && facility.Type != TypeStore && facility.Type != TypeDC {
+		return errors.Wrapf(web.ErrValidation, "facility type must be %s or %s", TypeStore, TypeDC)
+	}
+	if threshold := facility.ProbablyMissingThreshold; threshold != nil && (*threshold < 0 || *threshold > 1) {
+		return errors.Wrapf(web.ErrValidation, "probably missing threshold %v must be between 0 and 1", *threshold)
+	}
+	return nil
+}
+
 // CreateFacilityMap builds a map[string] based of array of facilities for search efficiency
 func CreateFacilityMap(dbs *sql.DB) (map[string]Facility, error) {
 
@@ -275,27 +369,75 @@ func findAll(dbs *sql.DB) ([]Facility, error) {
 }
 
 func insert(dbs *sql.DB, facility Facility) error {
+	if _, err := insertWithHistory(dbs, facility, "", "facility inserted"); err != nil {
+		return errors.Wrap(err, "error in inserting facility")
+	}
+	return nil
+}
 
-	obj, err := json.Marshal(facility)
+// insertWithHistory inserts the facility unless one with the same name exists, and records its coefficients
+// as their next version: the first one, unless a deleted facility of the same name left its history behind.
+// It returns whether the facility was inserted.
+func insertWithHistory(dbs *sql.DB, facility Facility, changedBy string, reason string) (bool, error) {
+	transaction, err := dbs.Begin()
 	if err != nil {
-		return err
+		return false, errors.Wrap(err, "unable to begin transaction")
 	}
 
-	insertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s); `,
-		pq.QuoteIdentifier(facilitiesTable),
-		pq.QuoteIdentifier(jsonb),
-		pq.QuoteLiteral(string(obj)),
-	)
+	inserted, err := func() (bool, error) {
+		version, err := nextCoefficientsVersion(transaction, facility.Name)
+		if err != nil {
+			return false, err
+		}
+		facility.CoefficientsVersion = version
 
-	_, err = dbs.Exec(insertStmt)
+		obj, err := json.Marshal(facility)
+		if err != nil {
+			return false, err
+		}
+
+		insertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)
+					ON CONFLICT (( %s ->> %s )) DO NOTHING;`,
+			pq.QuoteIdentifier(facilitiesTable),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(string(obj)),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(nameColumn),
+		)
+
+		result, err := transaction.Exec(insertStmt)
+		if err != nil {
+			return false, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return false, err
+		}
+		return true, insertCoefficientsVersion(transaction, CoefficientsVersion{
+			FacilityID:   facility.Name,
+			Version:      facility.CoefficientsVersion,
+			Coefficients: facility.Coefficients,
+			ChangedBy:    changedBy,
+			Reason:       reason,
+			Timestamp:    helper.UnixMilliNow(),
+		})
+	}()
 	if err != nil {
-		return errors.Wrap(err, "error in inserting facility")
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return false, errors.Wrap(rollbackErr, err.Error())
+		}
+		return false, err
 	}
 
-	return nil
+	if err := transaction.Commit(); err != nil {
+		return false, errors.Wrap(err, "unable to commit facility")
+	}
+	return inserted, nil
 }
 
-// Delete removes facility based on name
+// Delete removes facility based on name.
+// The error is caused by web.ErrConflict if sensors or tags are still in the facility: their messages would
+// register it again right away.
 // nolint :dupl
 func Delete(dbs *sql.DB, name string) error {
 
@@ -304,27 +446,89 @@ func Delete(dbs *sql.DB, name string) error {
 	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Delete-Facility.Success`, nil)
 	mDeleteErr := metrics.GetOrRegisterGauge(`Inventory.Delete-Facility.Delete-Error`, nil)
 	mErrNotFound := metrics.GetOrRegisterGauge(`Inventory.Delete-Facility.NotFound-Error`, nil)
+	mConflictErr := metrics.GetOrRegisterGauge(`Inventory.Delete-Facility.Conflict-Error`, nil)
 	mDeleteLatency := metrics.GetOrRegisterTimer(`Inventory.Delete-Facility.Delete-Latency`, nil)
 
-	selectQuery := fmt.Sprintf(`DELETE FROM %s WHERE %s ->> %s = %s;`,
+	deleteTimer := time.Now()
+	transaction, err := dbs.Begin()
+	if err != nil {
+		mDeleteErr.Update(1)
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+
+	if err := deleteInTransaction(transaction, name); err != nil {
+		switch errors.Cause(err) {
+		case web.ErrNotFound:
+			mErrNotFound.Update(1)
+		case web.ErrConflict:
+			mConflictErr.Update(1)
+		default:
+			mDeleteErr.Update(1)
+		}
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return errors.Wrap(rollbackErr, err.Error())
+		}
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		mDeleteErr.Update(1)
+		return errors.Wrap(err, "unable to commit facility deletion")
+	}
+	mDeleteLatency.Update(time.Since(deleteTimer))
+
+	forgetRegistered(name)
+
+	mSuccess.Update(1)
+	return nil
+}
+
+// deleteInTransaction deletes the facility unless sensors or tags are still in it
+func deleteInTransaction(transaction *sql.Tx, name string) error {
+	// the facility is locked first, so that it is not changed while checking what is in it
+	lockQuery := fmt.Sprintf(`SELECT %s FROM %s WHERE %s ->> %s = %s FOR UPDATE;`,
+		pq.QuoteIdentifier(jsonb),
 		pq.QuoteIdentifier(facilitiesTable),
 		pq.QuoteIdentifier(jsonb),
 		pq.QuoteLiteral(nameColumn),
 		pq.QuoteLiteral(name),
 	)
-
-	deleteTimer := time.Now()
-	if _, err := dbs.Exec(selectQuery); err != nil {
+	var current Facility
+	if err := transaction.QueryRow(lockQuery).Scan(&current); err != nil {
 		if err == sql.ErrNoRows {
-			mErrNotFound.Update(1)
 			return web.ErrNotFound
 		}
-		mDeleteErr.Update(1)
-		return errors.Wrap(err, "error in deleting facility")
+		return errors.Wrap(err, "error in finding facility")
 	}
-	mDeleteLatency.Update(time.Since(deleteTimer))
 
-	mSuccess.Update(1)
+	inUseQuery := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE %s ->> %s = %s)
+					OR EXISTS (SELECT 1 FROM %s WHERE %s ->> %s = %s);`,
+		pq.QuoteIdentifier(sensorsTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(facilityIDColumn),
+		pq.QuoteLiteral(name),
+		pq.QuoteIdentifier(tagsTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(facilityIDColumn),
+		pq.QuoteLiteral(name),
+	)
+	var inUse bool
+	if err := transaction.QueryRow(inUseQuery).Scan(&inUse); err != nil {
+		return errors.Wrap(err, "error in finding what is in the facility")
+	}
+	if inUse {
+		return errors.Wrapf(web.ErrConflict, "facility %s still has sensors or tags", name)
+	}
+
+	deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE %s ->> %s = %s;`,
+		pq.QuoteIdentifier(facilitiesTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(nameColumn),
+		pq.QuoteLiteral(name),
+	)
+	if _, err := transaction.Exec(deleteStmt); err != nil {
+		return errors.Wrap(err, "error in deleting facility")
+	}
 	return nil
 }
 
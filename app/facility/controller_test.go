@@ -17,6 +17,7 @@ import (
 	"testing"
 
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
 )
 
 var dbHost integrationtest.DBHost
@@ -233,6 +234,42 @@ func TestDelete_nonExistItem(t *testing.T) {
 	}
 }
 
+func TestDeleteInUse(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	for _, name := range []string{"store1", "store2", "store3"} {
+		if err := Create(testDB.DB, Facility{Name: name}, Coefficients{}, ""); err != nil {
+			t.Fatalf("Unable to create facility: %+v", err)
+		}
+	}
+	for _, insertStmt := range []string{
+		`INSERT INTO rspconfig (data) VALUES ('{"device_id": "RSP-1", "facility_id": "store1"}');`,
+		`INSERT INTO tags (data) VALUES ('{"epc": "3014", "facility_id": "store2"}');`,
+	} {
+		if _, err := testDB.DB.Exec(insertStmt); err != nil {
+			t.Fatalf("Unable to insert: %+v", err)
+		}
+	}
+
+	for _, name := range []string{"store1", "store2"} {
+		if err := Delete(testDB.DB, name); errors.Cause(err) != web.ErrConflict {
+			t.Errorf("expected a conflict deleting %s, which is still in use, got %v", name, err)
+		}
+	}
+	if err := Delete(testDB.DB, "store3"); err != nil {
+		t.Errorf("Unable to delete facility: %+v", err)
+	}
+
+	facilities, err := CreateFacilityMap(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to find facilities: %+v", err)
+	}
+	if len(facilities) != 2 {
+		t.Errorf("expected the facilities in use to be kept, got %v", facilities)
+	}
+}
+
 func TestUpdate_nonExistItem(t *testing.T) {
 	testDB := dbHost.CreateDB(t)
 	defer testDB.Close()
@@ -254,3 +291,81 @@ func TestUpdate_nonExistItem(t *testing.T) {
 		}
 	}
 }
+
+func TestCreate(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	defaults := Coefficients{DailyInventoryPercentage: 0.01, ProbUnreadToRead: 0.2, ProbInStoreRead: 0.75, ProbExitError: 0.1}
+	store := Facility{Name: "store1", DisplayName: "Store 1", Timezone: "America/Los_Angeles", Type: TypeStore}
+	if err := Create(testDB.DB, store, defaults, ""); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+	if err := Create(testDB.DB, store, defaults, ""); errors.Cause(err) != web.ErrConflict {
+		t.Errorf("expected a conflict creating the facility again, got %v", err)
+	}
+	if err := Create(testDB.DB, Facility{Name: "store2", Timezone: "Mars/Olympus_Mons"}, defaults, ""); errors.Cause(err) != web.ErrValidation {
+		t.Errorf("expected a validation error for an unknown timezone, got %v", err)
+	}
+
+	facilities, err := CreateFacilityMap(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to find facilities: %+v", err)
+	}
+	if created := facilities["store1"]; created.DisplayName != "Store 1" || created.Coefficients != defaults {
+		t.Errorf("expected the facility with the default coefficients, got %+v", created)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	coefficients := Coefficients{DailyInventoryPercentage: 0.5, ProbUnreadToRead: 0.5, ProbInStoreRead: 0.5, ProbExitError: 0.5}
+	if err := Create(testDB.DB, Facility{Name: "dc1", Address: "Somewhere", Coefficients: coefficients}, Coefficients{}, ""); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+	if err := Update(testDB.DB, Facility{Name: "dc1", Type: TypeDC, ASNReceiving: true}); err != nil {
+		t.Fatalf("Unable to update facility: %+v", err)
+	}
+	if err := Update(testDB.DB, Facility{Name: "dc2"}); err != web.ErrNotFound {
+		t.Errorf("expected updating an unknown facility to fail with not found, got %v", err)
+	}
+
+	facilities, err := CreateFacilityMap(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to find facilities: %+v", err)
+	}
+	updated := facilities["dc1"]
+	if updated.Type != TypeDC || !updated.ASNReceiving || updated.Address != "" {
+		t.Errorf("expected the metadata to be replaced, got %+v", updated)
+	}
+	if updated.Coefficients != coefficients {
+		t.Errorf("expected the coefficients to be kept, got %+v", updated.Coefficients)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	if err := Create(testDB.DB, Facility{Name: "store1", DisplayName: "Store 1"}, Coefficients{}, ""); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+	for _, name := range []string{"store1", "store2", "store2", ""} {
+		if err := Register(testDB.DB, name); err != nil {
+			t.Fatalf("Unable to register facility %s: %+v", name, err)
+		}
+	}
+
+	facilities, err := CreateFacilityMap(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to find facilities: %+v", err)
+	}
+	if len(facilities) != 2 {
+		t.Errorf("expected 2 facilities, got %v", facilities)
+	}
+	if facilities["store1"].DisplayName != "Store 1" {
+		t.Errorf("expected the existing facility to be kept, got %+v", facilities["store1"])
+	}
+}
@@ -11,8 +11,24 @@ package facility
 type Facility struct {
 	// Facility name
 	Name string `json:"name"  db:"name"`
+	// Name of the facility to display
+	DisplayName string `json:"display_name,omitempty"  db:"display_name"`
+	// IANA time zone of the facility, e.g. America/Los_Angeles
+	Timezone string `json:"timezone,omitempty"  db:"timezone"`
+	// Postal address of the facility
+	Address string `json:"address,omitempty"  db:"address"`
+	// Type of the facility, store or dc (distribution center)
+	Type string `json:"type,omitempty"  db:"type"`
+	// Local opening and closing times of the facility
+	BusinessHours *BusinessHours `json:"business_hours,omitempty"  db:"business_hours"`
+	// Whether the facility receives advanced shipping notices
+	ASNReceiving bool `json:"asn_receiving"  db:"asn_receiving"`
+	// Confidence below which present tags are reported probably missing, the configured one if not set
+	ProbablyMissingThreshold *float64 `json:"probably_missing_threshold,omitempty"  db:"probably_missing_threshold"`
 	// The coefficients used in the probabilistic inventory algorithm
 	Coefficients Coefficients `json:"coefficients"  db:"coefficients"`
+	// Version of the coefficients in the coefficients history
+	CoefficientsVersion int64 `json:"coefficients_version"  db:"coefficients_version"`
 }
 
 // CountType represents a wrapper for count and inlinecount
@@ -29,6 +45,12 @@ type Response struct {
 	Count *int `json:"count,omitempty"`
 }
 
+// Facility types
+const (
+	TypeStore = "store"
+	TypeDC    = "dc"
+)
+
 // Coefficients represents a set of attributes to calculate confidence
 //swagger:model Coefficients
 type Coefficients struct {
@@ -42,6 +64,25 @@ type Coefficients struct {
 	ProbExitError float64 `json:"probexiterror" db:"probexiterror"`
 }
 
+// CoefficientsVersion is a recorded change of the coefficients of a facility
+//swagger:model CoefficientsVersion
+type CoefficientsVersion struct {
+	// Facility name
+	FacilityID string `json:"facility_id"`
+	// Version of the coefficients, incremented by every change
+	Version int64 `json:"version"`
+	// The coefficients from this version on
+	Coefficients Coefficients `json:"coefficients"`
+	// Who requested the change
+	ChangedBy string `json:"changed_by"`
+	// Why the coefficients changed
+	Reason string `json:"reason"`
+	// Time of the change in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+	// Version the coefficients were rolled back to, if the change is a rollback
+	RolledBackTo int64 `json:"rolled_back_to,omitempty"`
+}
+
 // RequestBody represents a struct for the requestBody to Update facility collection
 //swagger:ignore
 type RequestBody struct {
@@ -50,4 +91,21 @@ type RequestBody struct {
 	ProbUnreadToRead         float64 `json:"probunreadtoread"`
 	ProbInStoreRead          float64 `json:"probinstoreread"`
 	ProbExitError            float64 `json:"probexiterror"`
+	ChangedBy                string  `json:"changed_by"`
+	Reason                   string  `json:"reason"`
+}
+
+// RollbackRequestBody represents a struct for the requestBody to roll the coefficients of a facility back
+//swagger:ignore
+type RollbackRequestBody struct {
+	FacilityID string `json:"facility_id"`
+	Version    int64  `json:"version"`
+	ChangedBy  string `json:"changed_by"`
+	Reason     string `json:"reason"`
+}
+
+// DeleteRequestBody represents a struct for the requestBody to delete a facility
+//swagger:ignore
+type DeleteRequestBody struct {
+	Name string `json:"name"`
 }
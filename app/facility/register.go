@@ -0,0 +1,83 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package facility
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/pkg/errors"
+)
+
+// registered holds the names of the facilities known to be in the database, so that the facilities of the
+// frequent sensor messages are only inserted once
+var registered = struct {
+	sync.RWMutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// DefaultCoefficients returns the configured coefficients of facilities which have none of their own
+func DefaultCoefficients() Coefficients {
+	return Coefficients{
+		DailyInventoryPercentage: config.AppConfig.DailyInventoryPercentage,
+		ProbUnreadToRead:         config.AppConfig.ProbUnreadToRead,
+		ProbInStoreRead:          config.AppConfig.ProbInStoreRead,
+		ProbExitError:            config.AppConfig.ProbExitError,
+	}
+}
+
+// Register inserts the facility with the default coefficients, unless it already exists.
+// Facilities seen in sensor messages are registered, so they can be managed like the ones created through the API.
+func Register(dbs *sql.DB, name string) error {
+	if name == "" || isRegistered(name) {
+		return nil
+	}
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Register-Facility.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Register-Facility.Success`, nil)
+	mInsertErr := metrics.GetOrRegisterGauge(`Inventory.Register-Facility.Insert-Error`, nil)
+	mRegistered := metrics.GetOrRegisterGauge(`Inventory.Register-Facility.Registered`, nil)
+	mInsertLatency := metrics.GetOrRegisterTimer(`Inventory.Register-Facility.Insert-Latency`, nil)
+
+	insertTimer := time.Now()
+	inserted, err := insertWithHistory(dbs, Facility{Name: name, Coefficients: DefaultCoefficients()},
+		"", "facility registered from sensor data")
+	if err != nil {
+		mInsertErr.Update(1)
+		return errors.Wrapf(err, "unable to register facility %s", name)
+	}
+	mInsertLatency.Update(time.Since(insertTimer))
+
+	if inserted {
+		mRegistered.Update(1)
+	}
+	markRegistered(name)
+	mSuccess.Update(1)
+	return nil
+}
+
+func isRegistered(name string) bool {
+	registered.RLock()
+	defer registered.RUnlock()
+	return registered.names[name]
+}
+
+func markRegistered(name string) {
+	registered.Lock()
+	defer registered.Unlock()
+	registered.names[name] = true
+}
+
+func forgetRegistered(name string) {
+	registered.Lock()
+	defer registered.Unlock()
+	delete(registered.names, name)
+}
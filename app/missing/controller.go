@@ -0,0 +1,160 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package missing
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	probablyMissingTable = "probably_missing"
+	jsonb                = "data"
+	epcColumn            = "epc"
+)
+
+// RetrieveFlags returns the flags of the tags reported probably missing, by epc
+func RetrieveFlags(dbs *sql.DB) (map[string]Flag, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.RetrieveProbablyMissing.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.RetrieveProbablyMissing.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge(`Inventory.RetrieveProbablyMissing.Find-Error`, nil)
+
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s;`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(probablyMissingTable),
+	)
+
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mFindErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving probably missing tags")
+	}
+	defer rows.Close()
+
+	flags := make(map[string]Flag)
+	for rows.Next() {
+		var flag Flag
+		if err := rows.Scan(&flag); err != nil {
+			mFindErr.Update(1)
+			return nil, err
+		}
+		flags[flag.Epc] = flag
+	}
+	if err = rows.Err(); err != nil {
+		mFindErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return flags, nil
+}
+
+// Save records the tags reported probably missing by the evaluation, and forgets the ones which recovered
+// or are no longer present
+func Save(dbs *sql.DB, evaluation *Evaluation) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.SaveProbablyMissing.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.SaveProbablyMissing.Success`, nil)
+	mSaveErr := metrics.GetOrRegisterGauge(`Inventory.SaveProbablyMissing.Save-Error`, nil)
+	mSaveLatency := metrics.GetOrRegisterTimer(`Inventory.SaveProbablyMissing.Save-Latency`, nil)
+
+	evaluation.finish()
+	if len(evaluation.flagged) == 0 && len(evaluation.cleared) == 0 {
+		mSuccess.Update(1)
+		return nil
+	}
+
+	saveTimer := time.Now()
+	transaction, err := dbs.Begin()
+	if err != nil {
+		mSaveErr.Update(1)
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+
+	if err := saveInTransaction(transaction, evaluation.flagged, evaluation.cleared); err != nil {
+		mSaveErr.Update(1)
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return errors.Wrap(rollbackErr, err.Error())
+		}
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		mSaveErr.Update(1)
+		return errors.Wrap(err, "unable to commit probably missing tags")
+	}
+	mSaveLatency.Update(time.Since(saveTimer))
+
+	mSuccess.Update(1)
+	return nil
+}
+
+func saveInTransaction(transaction *sql.Tx, flagged []Flag, cleared []string) error {
+	if len(cleared) > 0 {
+		epcs := make([]string, len(cleared))
+		for i, epc := range cleared {
+			epcs[i] = pq.QuoteLiteral(epc)
+		}
+		deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE %s ->> %s IN (%s);`,
+			pq.QuoteIdentifier(probablyMissingTable),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(epcColumn),
+			strings.Join(epcs, ", "),
+		)
+		if _, err := transaction.Exec(deleteStmt); err != nil {
+			return errors.Wrap(err, "error in clearing probably missing tags")
+		}
+	}
+
+	if len(flagged) > 0 {
+		values := make([]string, len(flagged))
+		for i, flag := range flagged {
+			obj, err := json.Marshal(flag)
+			if err != nil {
+				return err
+			}
+			values[i] = fmt.Sprintf("(%s)", pq.QuoteLiteral(string(obj)))
+		}
+		insertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES %s ON CONFLICT (( %s ->> %s )) DO NOTHING;`,
+			pq.QuoteIdentifier(probablyMissingTable),
+			pq.QuoteIdentifier(jsonb),
+			strings.Join(values, ", "),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(epcColumn),
+		)
+		if _, err := transaction.Exec(insertStmt); err != nil {
+			return errors.Wrap(err, "error in flagging probably missing tags")
+		}
+	}
+	return nil
+}
+
+// Value implements driver.Valuer interfaces
+func (flag Flag) Value() (driver.Value, error) {
+	return json.Marshal(flag)
+}
+
+// Scan implements sql.Scanner interfaces
+func (flag *Flag) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, flag)
+}
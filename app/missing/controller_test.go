@@ -0,0 +1,69 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package missing
+
+import (
+	"os"
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/integrationtest"
+)
+
+var dbHost integrationtest.DBHost
+
+func TestMain(m *testing.M) {
+	dbHost = integrationtest.InitHost("missing_test")
+	exitCode := m.Run()
+	dbHost.Close()
+	os.Exit(exitCode)
+}
+
+func TestSaveAndRetrieveFlags(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	facilities := map[string]facility.Facility{"store": {Name: "store", ProbablyMissingThreshold: threshold(0.5)}}
+	evaluation := NewEvaluation(facilities, nil, 5000)
+	evaluation.Add([]tag.Tag{
+		{Epc: "303401D6A415B5C000000001", FacilityID: "store", Confidence: 0.1, LastRead: 1000},
+		{Epc: "303401D6A415B5C000000002", FacilityID: "store", Confidence: 0.2, LastRead: 1000},
+		{Epc: "303401D6A415B5C000000003", FacilityID: "store", Confidence: 0.9, LastRead: 1000},
+	})
+	if err := Save(testDB.DB, evaluation); err != nil {
+		t.Fatalf("Unable to save probably missing tags: %+v", err)
+	}
+
+	flags, err := RetrieveFlags(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to retrieve probably missing tags: %+v", err)
+	}
+	if len(flags) != 2 || flags["303401D6A415B5C000000001"].Confidence != 0.1 {
+		t.Fatalf("Expected 2 probably missing tags, got %+v", flags)
+	}
+
+	// the first tag was read again, the second one is no longer present
+	evaluation = NewEvaluation(facilities, flags, 9000)
+	evaluation.Add([]tag.Tag{
+		{Epc: "303401D6A415B5C000000001", FacilityID: "store", Confidence: 0.9, LastRead: 8000},
+	})
+	if err := Save(testDB.DB, evaluation); err != nil {
+		t.Fatalf("Unable to save probably missing tags: %+v", err)
+	}
+	if len(evaluation.Recovered) != 1 {
+		t.Errorf("Expected 1 recovered tag, got %d", len(evaluation.Recovered))
+	}
+
+	flags, err = RetrieveFlags(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to retrieve probably missing tags: %+v", err)
+	}
+	if len(flags) != 0 {
+		t.Errorf("Expected no probably missing tags, got %+v", flags)
+	}
+}
@@ -0,0 +1,106 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package missing
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/statemodel"
+)
+
+// Evaluation compares the confidence of the present tags, given in batches, to the thresholds of their
+// facilities. Tags falling below the threshold are reported probably missing, and probably missing tags
+// read again are reported recovered.
+type Evaluation struct {
+	// Tags which fell below the threshold, with the probably missing event
+	Missing []tag.Tag
+	// Probably missing tags read again, with the recovered event
+	Recovered []tag.Tag
+	// State changes of the missing and recovered tags, for the rules
+	StateChanges []tag.TagStateChange
+
+	facilities map[string]facility.Facility
+	flags      map[string]Flag
+	seen       map[string]bool
+	flagged    []Flag
+	cleared    []string
+	now        int64
+}
+
+// NewEvaluation starts an evaluation at the millisecond epoch now, of the tags of the facilities which were
+// flagged probably missing before
+func NewEvaluation(facilities map[string]facility.Facility, flags map[string]Flag, now int64) *Evaluation {
+	return &Evaluation{
+		facilities: facilities,
+		flags:      flags,
+		seen:       make(map[string]bool),
+		now:        now,
+	}
+}
+
+// Threshold returns the confidence below which present tags of the facility are probably missing.
+// A threshold of 0 disables the reports.
+func Threshold(site facility.Facility) float64 {
+	if site.ProbablyMissingThreshold != nil {
+		return *site.ProbablyMissingThreshold
+	}
+	return config.AppConfig.ProbablyMissingThreshold
+}
+
+// Events returns the probably missing and recovered events
+func (evaluation *Evaluation) Events() []tag.Tag {
+	events := make([]tag.Tag, 0, len(evaluation.Missing)+len(evaluation.Recovered))
+	events = append(events, evaluation.Missing...)
+	return append(events, evaluation.Recovered...)
+}
+
+// Add evaluates a batch of present tags, whose confidence is calculated
+func (evaluation *Evaluation) Add(tags []tag.Tag) {
+	for _, present := range tags {
+		evaluation.seen[present.Epc] = true
+		flag, flagged := evaluation.flags[present.Epc]
+
+		if flagged {
+			if present.LastRead > flag.LastRead {
+				previous := present
+				previous.Event, previous.Confidence, previous.LastRead = statemodel.ProbablyMissingEvent, flag.Confidence, flag.LastRead
+				recovered := present
+				recovered.Event = statemodel.RecoveredEvent
+				evaluation.Recovered = append(evaluation.Recovered, recovered)
+				evaluation.StateChanges = append(evaluation.StateChanges, tag.TagStateChange{PreviousState: previous, CurrentState: recovered})
+				evaluation.cleared = append(evaluation.cleared, present.Epc)
+			}
+			continue
+		}
+
+		threshold := Threshold(evaluation.facilities[present.FacilityID])
+		if present.Confidence >= threshold {
+			continue
+		}
+		missing := present
+		missing.Event = statemodel.ProbablyMissingEvent
+		evaluation.Missing = append(evaluation.Missing, missing)
+		evaluation.StateChanges = append(evaluation.StateChanges, tag.TagStateChange{PreviousState: present, CurrentState: missing})
+		evaluation.flagged = append(evaluation.flagged, Flag{
+			Epc:        present.Epc,
+			FacilityID: present.FacilityID,
+			Confidence: present.Confidence,
+			LastRead:   present.LastRead,
+			Timestamp:  evaluation.now,
+		})
+	}
+}
+
+// finish clears the flags of the tags which are no longer present, e.g. departed or deleted tags
+func (evaluation *Evaluation) finish() {
+	for epc := range evaluation.flags {
+		if !evaluation.seen[epc] {
+			evaluation.cleared = append(evaluation.cleared, epc)
+		}
+	}
+}
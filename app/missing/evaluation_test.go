@@ -0,0 +1,101 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package missing
+
+import (
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/statemodel"
+)
+
+func threshold(value float64) *float64 {
+	return &value
+}
+
+func TestEvaluation(t *testing.T) {
+	defaultThreshold := config.AppConfig.ProbablyMissingThreshold
+	defer func() { config.AppConfig.ProbablyMissingThreshold = defaultThreshold }()
+	config.AppConfig.ProbablyMissingThreshold = 0.1
+
+	facilities := map[string]facility.Facility{
+		"strict":   {Name: "strict", ProbablyMissingThreshold: threshold(0.5)},
+		"disabled": {Name: "disabled", ProbablyMissingThreshold: threshold(0)},
+	}
+	flags := map[string]Flag{
+		"read again":  {Epc: "read again", FacilityID: "store", LastRead: 1000},
+		"still gone":  {Epc: "still gone", FacilityID: "store", LastRead: 1000},
+		"not present": {Epc: "not present", FacilityID: "store", LastRead: 1000},
+	}
+
+	evaluation := NewEvaluation(facilities, flags, 5000)
+	evaluation.Add([]tag.Tag{
+		{Epc: "low", FacilityID: "store", Confidence: 0.05, LastRead: 1000},
+		{Epc: "high", FacilityID: "store", Confidence: 0.2, LastRead: 1000},
+		{Epc: "low for strict", FacilityID: "strict", Confidence: 0.2, LastRead: 1000},
+	})
+	evaluation.Add([]tag.Tag{
+		{Epc: "low but disabled", FacilityID: "disabled", Confidence: 0.01, LastRead: 1000},
+		{Epc: "read again", FacilityID: "store", Confidence: 0.9, LastRead: 4000},
+		{Epc: "still gone", FacilityID: "store", Confidence: 0.01, LastRead: 1000},
+	})
+	evaluation.finish()
+
+	if epcs := epcsOf(evaluation.Missing, statemodel.ProbablyMissingEvent, t); !equal(epcs, []string{"low", "low for strict"}) {
+		t.Errorf("Expected the tags below the threshold of their facility to be missing, got %v", epcs)
+	}
+	if epcs := epcsOf(evaluation.Recovered, statemodel.RecoveredEvent, t); !equal(epcs, []string{"read again"}) {
+		t.Errorf("Expected the missing tags read again to recover, got %v", epcs)
+	}
+	if len(evaluation.Events()) != 3 || len(evaluation.StateChanges) != 3 {
+		t.Errorf("Expected 3 events and state changes, got %d and %d", len(evaluation.Events()), len(evaluation.StateChanges))
+	}
+	for _, change := range evaluation.StateChanges {
+		if change.CurrentState.Event == statemodel.RecoveredEvent && change.PreviousState.Event != statemodel.ProbablyMissingEvent {
+			t.Errorf("Expected recovered tags to have been probably missing, got %s", change.PreviousState.Event)
+		}
+	}
+
+	var flagged []string
+	for _, flag := range evaluation.flagged {
+		if flag.Timestamp != 5000 {
+			t.Errorf("Expected flag %s at 5000, got %d", flag.Epc, flag.Timestamp)
+		}
+		flagged = append(flagged, flag.Epc)
+	}
+	if !equal(flagged, []string{"low", "low for strict"}) {
+		t.Errorf("Expected the missing tags to be flagged, got %v", flagged)
+	}
+	if !equal(evaluation.cleared, []string{"read again", "not present"}) {
+		t.Errorf("Expected the recovered and no longer present tags to be cleared, got %v", evaluation.cleared)
+	}
+}
+
+func epcsOf(tags []tag.Tag, event string, t *testing.T) []string {
+	var epcs []string
+	for _, eventTag := range tags {
+		if eventTag.Event != event {
+			t.Errorf("Expected event %s for tag %s, got %s", event, eventTag.Epc, eventTag.Event)
+		}
+		epcs = append(epcs, eventTag.Epc)
+	}
+	return epcs
+}
+
+func equal(actual []string, expected []string) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
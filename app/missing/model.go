@@ -0,0 +1,22 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package missing
+
+// Flag records that a present tag was reported probably missing, so that it is reported once and recovers
+// when it is read again
+type Flag struct {
+	// SGTIN EPC code
+	Epc string `json:"epc"`
+	// Facility of the tag
+	FacilityID string `json:"facility_id"`
+	// Confidence of the tag when it was reported
+	Confidence float64 `json:"confidence"`
+	// Millisecond epoch the tag was last read at when it was reported
+	LastRead int64 `json:"last_read"`
+	// Millisecond epoch the tag was reported at
+	Timestamp int64 `json:"timestamp"`
+}
@@ -0,0 +1,296 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package qualifiedstate
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	definitionsTable = "qualified_state_definitions"
+	historyTable     = "qualified_state_history"
+	jsonb            = "data"
+	facilityColumn   = "facility_id"
+	epcColumn        = "epc"
+	qualifiedState   = "qualified_state"
+)
+
+// UpsertDefinition creates or replaces the qualified-state definition of the facility
+func UpsertDefinition(dbs *sql.DB, definition Definition) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.QualifiedState.UpsertDefinition.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.UpsertDefinition.Success`, nil)
+	mUpsertErr := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.UpsertDefinition.Upsert-Error`, nil)
+
+	if err := definition.Validate(); err != nil {
+		return err
+	}
+
+	obj, err := json.Marshal(definition)
+	if err != nil {
+		return err
+	}
+
+	upsertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)
+		ON CONFLICT ((%s ->> %s)) DO UPDATE SET %s = EXCLUDED.%s;`,
+		pq.QuoteIdentifier(definitionsTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(string(obj)),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(facilityColumn),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+	)
+
+	if _, err := dbs.Exec(upsertStmt); err != nil {
+		mUpsertErr.Update(1)
+		return errors.Wrap(err, "error in upserting qualified state definition")
+	}
+
+	mSuccess.Update(1)
+	return nil
+}
+
+// RetrieveDefinitions retrieves the qualified-state definitions, of all the facilities when facilityID is empty
+func RetrieveDefinitions(dbs *sql.DB, facilityID string) ([]Definition, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.QualifiedState.RetrieveDefinitions.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.RetrieveDefinitions.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.RetrieveDefinitions.Find-Error`, nil)
+
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(definitionsTable),
+	)
+	if facilityID != "" {
+		selectQuery += fmt.Sprintf(` WHERE %s ->> %s = %s`,
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(facilityColumn),
+			pq.QuoteLiteral(facilityID),
+		)
+	}
+
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mFindErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving qualified state definitions")
+	}
+	defer rows.Close()
+
+	definitions := make([]Definition, 0)
+	for rows.Next() {
+		var definition Definition
+		if err := rows.Scan(&definition); err != nil {
+			mFindErr.Update(1)
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+	}
+	if err = rows.Err(); err != nil {
+		mFindErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return definitions, nil
+}
+
+// CreateDefinitionMap builds the definitions of all the facilities which have one, by facility ID
+func CreateDefinitionMap(dbs *sql.DB) (Definitions, error) {
+	definitions, err := RetrieveDefinitions(dbs, "")
+	if err != nil {
+		return nil, err
+	}
+
+	definitionMap := make(Definitions, len(definitions))
+	for _, definition := range definitions {
+		definitionMap[definition.FacilityID] = definition
+	}
+	return definitionMap, nil
+}
+
+// Change sets the qualified state of the selected tags in one transaction, enforcing the definition of the
+// facility of each tag if it has one, and records the change of each tag whose state changed in the history
+func Change(dbs *sql.DB, selection tag.BulkUpdateBody) (tag.BulkUpdateResult, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.QualifiedState.Change.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.Change.Success`, nil)
+	mTransitionErr := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.Change.Transition-Error`, nil)
+	mChangeLatency := metrics.GetOrRegisterTimer(`Inventory.QualifiedState.Change.Change-Latency`, nil)
+
+	// a list of EPCs may select tags of several facilities
+	definitions, err := CreateDefinitionMap(dbs)
+	if err != nil {
+		return tag.BulkUpdateResult{}, err
+	}
+
+	changeTimer := time.Now()
+	timestamp := helper.UnixMilliNow()
+	to := selection.QualifiedState
+
+	result, err := tag.BulkUpdate(dbs, selection, map[string]string{qualifiedState: to},
+		func(transaction *sql.Tx, selected []tag.Tag) error {
+			var invalid []string
+			var cause error
+			for _, selectedTag := range selected {
+				definition, found := definitions[selectedTag.FacilityID]
+				if !found {
+					continue
+				}
+				if err := definition.ValidateTransition(selectedTag.QualifiedState, to); err != nil {
+					invalid = append(invalid, selectedTag.Epc)
+					cause = err
+				}
+			}
+			if len(invalid) > 0 {
+				mTransitionErr.Update(1)
+				return transitionError(invalid, cause)
+			}
+
+			var entries []HistoryEntry
+			for _, selectedTag := range selected {
+				if selectedTag.QualifiedState == to {
+					continue
+				}
+				entries = append(entries, HistoryEntry{
+					Epc:            selectedTag.Epc,
+					FacilityID:     selectedTag.FacilityID,
+					PreviousState:  selectedTag.QualifiedState,
+					QualifiedState: to,
+					ChangedBy:      selection.ChangedBy,
+					Reason:         selection.Reason,
+					Timestamp:      timestamp,
+				})
+			}
+			return insertHistory(transaction, entries)
+		})
+	if err != nil {
+		return result, err
+	}
+	mChangeLatency.Update(time.Since(changeTimer))
+
+	mSuccess.Update(1)
+	return result, nil
+}
+
+func insertHistory(transaction *sql.Tx, entries []HistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(entries))
+	for i, entry := range entries {
+		obj, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		values[i] = fmt.Sprintf("(%s)", pq.QuoteLiteral(string(obj)))
+	}
+
+	insertStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES %s;`,
+		pq.QuoteIdentifier(historyTable),
+		pq.QuoteIdentifier(jsonb),
+		strings.Join(values, ", "),
+	)
+
+	if _, err := transaction.Exec(insertStmt); err != nil {
+		return errors.Wrap(err, "error in inserting qualified state history")
+	}
+	return nil
+}
+
+// RetrieveHistory retrieves the changes of qualified state of the EPC, most recent first
+func RetrieveHistory(dbs *sql.DB, epc string, maxSize int) ([]HistoryEntry, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.QualifiedState.RetrieveHistory.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.RetrieveHistory.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge(`Inventory.QualifiedState.RetrieveHistory.Find-Error`, nil)
+
+	if epc == "" {
+		return nil, errors.Wrap(web.ErrValidation, "epc is required")
+	}
+
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s WHERE %s ->> %s = %s ORDER BY (%s ->> 'timestamp')::BIGINT DESC LIMIT %d;`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(historyTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(epcColumn),
+		pq.QuoteLiteral(epc),
+		pq.QuoteIdentifier(jsonb),
+		maxSize,
+	)
+
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mFindErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving qualified state history")
+	}
+	defer rows.Close()
+
+	entries := make([]HistoryEntry, 0)
+	for rows.Next() {
+		var entry HistoryEntry
+		if err := rows.Scan(&entry); err != nil {
+			mFindErr.Update(1)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		mFindErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return entries, nil
+}
+
+// Value implements driver.Valuer interfaces
+func (definition Definition) Value() (driver.Value, error) {
+	return json.Marshal(definition)
+}
+
+// Scan implements sql.Scanner interfaces
+func (definition *Definition) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, definition)
+}
+
+// Value implements driver.Valuer interfaces
+func (entry HistoryEntry) Value() (driver.Value, error) {
+	return json.Marshal(entry)
+}
+
+// Scan implements sql.Scanner interfaces
+func (entry *HistoryEntry) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, entry)
+}
@@ -0,0 +1,105 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package qualifiedstate
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/integrationtest"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+var dbHost integrationtest.DBHost
+
+func TestMain(m *testing.M) {
+	dbHost = integrationtest.InitHost("qualifiedState_test")
+	exitCode := m.Run()
+	dbHost.Close()
+	os.Exit(exitCode)
+}
+
+func TestUpsertAndRetrieveDefinitions(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	definition := getTestDefinition()
+	if err := UpsertDefinition(testDB.DB, definition); err != nil {
+		t.Fatalf("error upserting definition: %+v", err)
+	}
+
+	definition.InitialStates = nil
+	if err := UpsertDefinition(testDB.DB, definition); err != nil {
+		t.Fatalf("error replacing definition: %+v", err)
+	}
+
+	definitions, err := RetrieveDefinitions(testDB.DB, "store1")
+	if err != nil {
+		t.Fatalf("error retrieving definitions: %+v", err)
+	}
+	if len(definitions) != 1 || len(definitions[0].InitialStates) != 0 {
+		t.Errorf("expected the replaced definition only, got %+v", definitions)
+	}
+}
+
+func TestChange(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []tag.Tag{
+		{Epc: "3014AA01", FacilityID: "store1"},
+		{Epc: "3014AA02", FacilityID: "store1", QualifiedState: "sold"},
+	}
+	if err := tag.Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+	if err := UpsertDefinition(testDB.DB, getTestDefinition()); err != nil {
+		t.Fatalf("error upserting definition: %+v", err)
+	}
+
+	// sold cannot become damaged, so neither tag changes
+	_, err := Change(testDB.DB, tag.BulkUpdateBody{FacilityID: "store1", Epcs: []string{"3014AA01", "3014AA02"},
+		QualifiedState: "damaged"})
+	if errors.Cause(err) != web.ErrValidation {
+		t.Fatalf("expected a validation error, got %+v", err)
+	}
+	unchanged, err := tag.FindByEpc(testDB.DB, "3014AA01")
+	if err != nil {
+		t.Fatalf("Unable to find tag: %+v", err)
+	}
+	if unchanged.QualifiedState != "" {
+		t.Errorf("expected the rejected change to be rolled back, got %+v", unchanged)
+	}
+
+	result, err := Change(testDB.DB, tag.BulkUpdateBody{FacilityID: "store1", Epcs: []string{"3014AA01"},
+		QualifiedState: "available", ChangedBy: "clerk", Reason: "received"})
+	if err != nil || result.Updated != 1 {
+		t.Fatalf("expected one tag changed, got %+v: %+v", result, err)
+	}
+	// history is ordered by millisecond timestamp
+	time.Sleep(2 * time.Millisecond)
+	if _, err = Change(testDB.DB, tag.BulkUpdateBody{FacilityID: "store1", Epcs: []string{"3014AA01"},
+		QualifiedState: "damaged", ChangedBy: "clerk", Reason: "torn"}); err != nil {
+		t.Fatalf("error changing qualified state: %+v", err)
+	}
+
+	history, err := RetrieveHistory(testDB.DB, "3014AA01", 100)
+	if err != nil {
+		t.Fatalf("error retrieving history: %+v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 history entries, got %+v", history)
+	}
+	latest := history[0]
+	if latest.PreviousState != "available" || latest.QualifiedState != "damaged" ||
+		latest.ChangedBy != "clerk" || latest.Reason != "torn" {
+		t.Errorf("unexpected latest history entry %+v", latest)
+	}
+}
@@ -0,0 +1,177 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package qualifiedstate
+
+import (
+	"fmt"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+// Definition is the qualified-state workflow of a facility
+//swagger:model QualifiedStateDefinition
+type Definition struct {
+	// Facility the definition applies to
+	FacilityID string `json:"facility_id"`
+	// Allowed qualified states
+	States []State `json:"states"`
+	// States a tag without qualified state may be given, any state when empty
+	InitialStates []string `json:"initial_states,omitempty"`
+	// Allowed transitions, from a state to the states it may change to
+	Transitions map[string][]string `json:"transitions"`
+}
+
+// State is an allowed qualified state
+type State struct {
+	// Name of the state
+	Name string `json:"name"`
+	// Tags in this state have a confidence of 0
+	BlocksConfidence bool `json:"blocks_confidence"`
+	// State changes of tags in this state are not sent to the rules service
+	BlocksAlerts bool `json:"blocks_alerts"`
+}
+
+// HistoryEntry is the audit record of a change of qualified state
+//swagger:model QualifiedStateHistoryEntry
+type HistoryEntry struct {
+	// SGTIN EPC code
+	Epc string `json:"epc"`
+	// Facility ID
+	FacilityID string `json:"facility_id"`
+	// Qualified state before the change, empty if there was none
+	PreviousState string `json:"previous_state"`
+	// Qualified state after the change
+	QualifiedState string `json:"qualified_state"`
+	// Who requested the change
+	ChangedBy string `json:"changed_by"`
+	// Why the state changed
+	Reason string `json:"reason"`
+	// Time of the change in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+}
+
+// UpdateBody is the model for request body to change the qualified state of one tag
+type UpdateBody struct {
+	// SGTIN EPC code
+	Epc string `json:"epc"`
+	// Facility ID
+	FacilityID string `json:"facility_id"`
+	// New qualified state
+	QualifiedState string `json:"qualified_state"`
+	// Who requested the change
+	ChangedBy string `json:"changed_by"`
+	// Why the state changed
+	Reason string `json:"reason"`
+}
+
+// Response is the model used to return the query response
+type Response struct {
+	Results interface{} `json:"results"`
+}
+
+// Definitions are the definitions of the facilities which have one, by facility ID
+type Definitions map[string]Definition
+
+// Validate checks that the states are unique and that the initial states and transitions only refer to them
+func (definition Definition) Validate() error {
+	if definition.FacilityID == "" {
+		return errors.Wrap(web.ErrValidation, "facility_id is required")
+	}
+	if len(definition.States) == 0 {
+		return errors.Wrap(web.ErrValidation, "at least one state must be defined")
+	}
+
+	names := make(map[string]bool, len(definition.States))
+	for _, state := range definition.States {
+		if names[state.Name] {
+			return errors.Wrapf(web.ErrValidation, "state %s is defined more than once", state.Name)
+		}
+		names[state.Name] = true
+	}
+
+	for _, initial := range definition.InitialStates {
+		if !names[initial] {
+			return errors.Wrapf(web.ErrValidation, "initial state %s is not defined", initial)
+		}
+	}
+	for from, targets := range definition.Transitions {
+		if !names[from] {
+			return errors.Wrapf(web.ErrValidation, "transition from undefined state %s", from)
+		}
+		for _, to := range targets {
+			if !names[to] {
+				return errors.Wrapf(web.ErrValidation, "transition from %s to undefined state %s", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateTransition checks that a tag may change from one qualified state to another. Tags without a
+// qualified state, or with one set before the definition which it does not define, may only be given
+// an initial state.
+func (definition Definition) ValidateTransition(from string, to string) error {
+	if _, defined := definition.state(to); !defined {
+		return errors.Wrapf(web.ErrValidation, "%s is not a qualified state of facility %s", to, definition.FacilityID)
+	}
+	if from == to {
+		return nil
+	}
+
+	if _, defined := definition.state(from); !defined {
+		if len(definition.InitialStates) == 0 || contains(definition.InitialStates, to) {
+			return nil
+		}
+		return errors.Wrapf(web.ErrValidation, "%s is not an initial qualified state", to)
+	}
+
+	if !contains(definition.Transitions[from], to) {
+		return errors.Wrapf(web.ErrValidation, "qualified state cannot change from %s to %s", from, to)
+	}
+	return nil
+}
+
+func (definition Definition) state(name string) (State, bool) {
+	for _, state := range definition.States {
+		if state.Name == name {
+			return state, true
+		}
+	}
+	return State{}, false
+}
+
+// BlocksConfidence reports whether tags of the facility in this qualified state have a confidence of 0
+func (definitions Definitions) BlocksConfidence(facilityID string, qualifiedState string) bool {
+	state, defined := definitions[facilityID].state(qualifiedState)
+	return defined && state.BlocksConfidence
+}
+
+// BlocksAlerts reports whether state changes of tags of the facility in this qualified state are not alerted on
+func (definitions Definitions) BlocksAlerts(facilityID string, qualifiedState string) bool {
+	state, defined := definitions[facilityID].state(qualifiedState)
+	return defined && state.BlocksAlerts
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionError summarizes the tags which cannot change state, listing at most a few of their EPCs
+func transitionError(invalid []string, cause error) error {
+	const maxListed = 10
+	listed := invalid
+	if len(listed) > maxListed {
+		listed = listed[:maxListed]
+	}
+	return errors.Wrap(cause, fmt.Sprintf("%d tags cannot change qualified state, including %v", len(invalid), listed))
+}
@@ -0,0 +1,105 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package qualifiedstate
+
+import (
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+func getTestDefinition() Definition {
+	return Definition{
+		FacilityID: "store1",
+		States: []State{
+			{Name: "available"},
+			{Name: "damaged", BlocksConfidence: true},
+			{Name: "sold", BlocksConfidence: true, BlocksAlerts: true},
+		},
+		InitialStates: []string{"available"},
+		Transitions: map[string][]string{
+			"available": {"damaged", "sold"},
+			"damaged":   {"available"},
+		},
+	}
+}
+
+func TestValidateTransition(t *testing.T) {
+	definition := getTestDefinition()
+
+	tests := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{"", "available", true},
+		{"", "damaged", false},
+		{"legacy", "available", true},
+		{"available", "damaged", true},
+		{"damaged", "available", true},
+		{"damaged", "sold", false},
+		{"sold", "available", false},
+		{"sold", "sold", true},
+		{"available", "unknown", false},
+	}
+
+	for _, test := range tests {
+		err := definition.ValidateTransition(test.from, test.to)
+		if test.allowed && err != nil {
+			t.Errorf("expected %q to %q to be allowed: %v", test.from, test.to, err)
+		}
+		if !test.allowed && errors.Cause(err) != web.ErrValidation {
+			t.Errorf("expected %q to %q to be rejected with a validation error, got %v", test.from, test.to, err)
+		}
+	}
+
+	definition.InitialStates = nil
+	if err := definition.ValidateTransition("", "damaged"); err != nil {
+		t.Errorf("expected any initial state without initial_states: %v", err)
+	}
+}
+
+func TestValidateDefinition(t *testing.T) {
+	if err := getTestDefinition().Validate(); err != nil {
+		t.Errorf("expected a valid definition: %v", err)
+	}
+
+	duplicated := getTestDefinition()
+	duplicated.States = append(duplicated.States, State{Name: "sold"})
+
+	undefinedInitial := getTestDefinition()
+	undefinedInitial.InitialStates = []string{"new"}
+
+	undefinedTarget := getTestDefinition()
+	undefinedTarget.Transitions["sold"] = []string{"returned"}
+
+	for name, definition := range map[string]Definition{
+		"duplicated":       duplicated,
+		"undefinedInitial": undefinedInitial,
+		"undefinedTarget":  undefinedTarget,
+		"empty":            {FacilityID: "store1"},
+	} {
+		if err := definition.Validate(); errors.Cause(err) != web.ErrValidation {
+			t.Errorf("expected %s definition to be invalid, got %v", name, err)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	definitions := Definitions{"store1": getTestDefinition()}
+
+	if !definitions.BlocksConfidence("store1", "damaged") || definitions.BlocksAlerts("store1", "damaged") {
+		t.Error("expected damaged to block confidence only")
+	}
+	if !definitions.BlocksAlerts("store1", "sold") {
+		t.Error("expected sold to block alerts")
+	}
+	if definitions.BlocksConfidence("store2", "damaged") || definitions.BlocksConfidence("store1", "legacy") {
+		t.Error("expected states without definition not to block confidence")
+	}
+}
@@ -0,0 +1,295 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-go-odata/parser"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/routes/schemas"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	csvContentType    = "text/csv"
+	// exportBatchSize is the number of tags confidence is computed for and flushed to the client at once
+	exportBatchSize = 500
+	// csvErrorMarker is the first field of the CSV row ending an interrupted export
+	csvErrorMarker = "#error"
+)
+
+// exportColumns are the CSV columns of the export, in order
+var exportColumns = []string{
+	"epc", "product_id", "uri", "facility_id", "epc_state", "qualified_state", "event", "source",
+	"arrived", "last_read", "location", "confidence", "epc_context",
+}
+
+// exportWriter writes exported tags in one of the supported formats
+type exportWriter interface {
+	write(tag.Tag) error
+	// fail ends the export with an error record, since the status has already been sent
+	fail(message string) error
+	flush() error
+}
+
+// ExportTags streams all tags matching the OData query parameters, or the filters of the optional request body,
+// as NDJSON or CSV depending on the Accept header, with their confidence and decoded product ID
+// 200 OK, 400 Bad Request, 406 Not Acceptable, 500 Internal Error
+func (inve *Inventory) ExportTags(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.ExportTags.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.ExportTags.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.ExportTags.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.ExportTags.Validation-Error", nil)
+	mStreamErr := metrics.GetOrRegisterGauge("Inventory.ExportTags.Stream-Error", nil)
+	mExported := metrics.GetOrRegisterGauge("Inventory.ExportTags.Exported-Tags", nil)
+
+	contentType, err := negotiateExportFormat(request.Header.Get("Accept"))
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+
+	query := request.URL.Query()
+	var minConfidence float64
+	if request.ContentLength > 0 {
+		var mapping tag.RequestBody
+
+		validationErrors, err := readAndValidateRequest(request, schemas.ExportSchema, &mapping)
+		if err != nil {
+			mValidationErr.Update(1)
+			return err
+		}
+		if validationErrors != nil {
+			mValidationErr.Update(1)
+			web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+			return nil
+		}
+		if len(query) > 0 {
+			mValidationErr.Update(1)
+			return errors.Wrap(web.ErrValidation, "filters must be provided either as OData query parameters or in the request body")
+		}
+
+		// confidence is not stored, so it is filtered on after being computed
+		minConfidence = mapping.Confidence
+		mapping.Confidence = 0
+		query = mapRequestToOdata(make(map[string][]string), &mapping)
+		if query.Get(parser.Filter) == "" {
+			delete(query, parser.Filter)
+		}
+	} else if err := validateExportQuery(query); err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+
+	inputs, err := loadConfidenceInputs(inve.MasterDB, inve.Url)
+	if err != nil {
+		return err
+	}
+
+	writer.Header().Set("Content-Type", contentType)
+	var export exportWriter
+	if contentType == csvContentType {
+		export, err = newCSVExportWriter(writer)
+	} else {
+		export = newNDJSONExportWriter(writer)
+	}
+	if err != nil {
+		return err
+	}
+
+	exported := 0
+	streaming := false
+	batch := make([]tag.Tag, 0, exportBatchSize)
+	writeBatch := func() error {
+		streaming = true
+		for i := range batch {
+			if batch[i].ProductID == "" {
+				batch[i].ProductID, batch[i].URI, _ = tag.DecodeTagData(batch[i].Epc)
+			}
+		}
+		inputs.apply(inve.MasterDB, batch)
+		for _, exportTag := range batch {
+			if exportTag.Confidence < minConfidence {
+				continue
+			}
+			if err := export.write(exportTag); err != nil {
+				return err
+			}
+			exported++
+		}
+		batch = batch[:0]
+		return export.flush()
+	}
+
+	err = tag.StreamOdata(inve.MasterDB, query, func(exportTag tag.Tag) error {
+		batch = append(batch, exportTag)
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		return writeBatch()
+	})
+	if err == nil {
+		err = writeBatch()
+	}
+	mExported.Update(int64(exported))
+
+	if err != nil {
+		mStreamErr.Update(1)
+		// nothing was sent before the first batch, so the error can still be returned
+		if !streaming {
+			return err
+		}
+		// otherwise the status has already been sent, so the client is told in band that the export is incomplete
+		log.WithFields(log.Fields{
+			"Method":   "ExportTags",
+			"Action":   "Stream tags",
+			"Exported": exported,
+			"Error":    err.Error(),
+		}).Error("export interrupted")
+		if err := export.fail(fmt.Sprintf("export interrupted after %d tags, the results are incomplete", exported)); err == nil {
+			export.flush()
+		}
+		return nil
+	}
+
+	mSuccess.Update(1)
+	return nil
+}
+
+// negotiateExportFormat returns the content type of the export matching the Accept header, NDJSON by default
+func negotiateExportFormat(accept string) (string, error) {
+	if strings.TrimSpace(accept) == "" {
+		return ndjsonContentType, nil
+	}
+
+	for _, acceptedType := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(acceptedType))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case ndjsonContentType, "application/ndjson", "application/jsonl", "application/*", "*/*":
+			return ndjsonContentType, nil
+		case csvContentType, "text/*":
+			return csvContentType, nil
+		}
+	}
+
+	return "", errors.Wrapf(web.ErrNotAcceptable, "supported formats are %s and %s", ndjsonContentType, csvContentType)
+}
+
+// validateExportQuery only allows the OData options that make sense for a full export
+func validateExportQuery(query url.Values) error {
+	for key := range query {
+		if key != parser.Filter && key != parser.OrderBy && key != tag.ArchivedParam {
+			return errors.Wrapf(web.ErrValidation, "%s is not supported by the export, only $filter, $orderby and %s",
+				key, tag.ArchivedParam)
+		}
+	}
+	return nil
+}
+
+type ndjsonExportWriter struct {
+	writer  io.Writer
+	encoder *json.Encoder
+}
+
+func newNDJSONExportWriter(writer io.Writer) *ndjsonExportWriter {
+	return &ndjsonExportWriter{writer: writer, encoder: json.NewEncoder(writer)}
+}
+
+func (export *ndjsonExportWriter) write(exportTag tag.Tag) error {
+	// Encode terminates each tag with a newline
+	return export.encoder.Encode(exportTag)
+}
+
+func (export *ndjsonExportWriter) fail(message string) error {
+	return export.encoder.Encode(map[string]string{"error": message})
+}
+
+func (export *ndjsonExportWriter) flush() error {
+	flushResponse(export.writer)
+	return nil
+}
+
+type csvExportWriter struct {
+	writer    io.Writer
+	csvWriter *csv.Writer
+}
+
+func newCSVExportWriter(writer io.Writer) (*csvExportWriter, error) {
+	export := &csvExportWriter{writer: writer, csvWriter: csv.NewWriter(writer)}
+	if err := export.csvWriter.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+func (export *csvExportWriter) write(exportTag tag.Tag) error {
+	location := ""
+	if len(exportTag.LocationHistory) > 0 {
+		location = exportTag.LocationHistory[0].Location
+	}
+
+	return export.csvWriter.Write([]string{
+		exportTag.Epc,
+		exportTag.ProductID,
+		exportTag.URI,
+		exportTag.FacilityID,
+		exportTag.EpcState,
+		exportTag.QualifiedState,
+		exportTag.Event,
+		exportTag.Source,
+		strconv.FormatInt(exportTag.Arrived, 10),
+		strconv.FormatInt(exportTag.LastRead, 10),
+		location,
+		strconv.FormatFloat(exportTag.Confidence, 'f', -1, 64),
+		exportTag.EpcContext,
+	})
+}
+
+func (export *csvExportWriter) fail(message string) error {
+	return export.csvWriter.Write([]string{csvErrorMarker, message})
+}
+
+func (export *csvExportWriter) flush() error {
+	export.csvWriter.Flush()
+	if err := export.csvWriter.Error(); err != nil {
+		return err
+	}
+	flushResponse(export.writer)
+	return nil
+}
+
+// flushResponse sends what has been written so far to the client
+func flushResponse(writer io.Writer) {
+	if flusher, ok := writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
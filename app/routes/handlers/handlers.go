@@ -12,17 +12,27 @@ import (
 	"encoding/json"
 	"github.com/intel/rsp-sw-toolkit-im-suite-go-odata/parser"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/alert"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/dailyturn"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/epccontext"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/estimation"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/handheldevent"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/qualifiedstate"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/routes/schemas"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagprocessor"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/productdata"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"net/http"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -79,7 +89,7 @@ func (inve *Inventory) GetTags(ctx context.Context, writer http.ResponseWriter,
 		isConfidence = true
 	}
 
-	tags, count, err := tag.Retrieve(inve.MasterDB, url, inve.MaxSize)
+	tags, count, paging, err := tag.Retrieve(inve.MasterDB, url, inve.MaxSize)
 	if err != nil {
 		mRetrieveErr.Update(1)
 		return errors.Wrap(err, "Error retrieving Tag")
@@ -135,7 +145,7 @@ func (inve *Inventory) GetTags(ctx context.Context, writer http.ResponseWriter,
 
 	if count != nil && resultSlice != nil {
 		mSuccess.Update(1)
-		web.Respond(ctx, writer, tag.Response{Results: resultSlice, Count: count.Count}, http.StatusOK)
+		web.Respond(ctx, writer, tag.Response{Results: resultSlice, Count: count.Count, PagingType: paging}, http.StatusOK)
 		return nil
 	}
 
@@ -147,7 +157,7 @@ func (inve *Inventory) GetTags(ctx context.Context, writer http.ResponseWriter,
 	}
 
 	mSuccess.Update(1)
-	web.Respond(ctx, writer, tag.Response{Results: resultSlice}, http.StatusOK)
+	web.Respond(ctx, writer, tag.Response{Results: resultSlice, PagingType: paging}, http.StatusOK)
 	return nil
 }
 
@@ -164,7 +174,7 @@ func (inve *Inventory) GetSearchByProductID(ctx context.Context, writer http.Res
 	return processGetRequest(ctx, schemas.SearchByProductIdSchema, inve.MasterDB, request, writer, inve.Url)
 }
 
-// UpdateQualifiedState is for uploading inventory events such as those from a handheld RFID reader
+// UpdateQualifiedState changes the qualified state of a tag, enforcing the qualified-state workflow of its facility
 //nolint[: lll[, dupl, ...]]
 func (inve *Inventory) UpdateQualifiedState(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
 	metrics.GetOrRegisterGauge("Inventory.UpdateQualifiedState.Attempt", nil).Update(1)
@@ -172,7 +182,7 @@ func (inve *Inventory) UpdateQualifiedState(ctx context.Context, writer http.Res
 	mValidateRequestErr := metrics.GetOrRegisterGauge("Inventory.UpdateQualifiedState.ValidateRequest-Error", nil)
 	mSuccess := metrics.GetOrRegisterGauge("Inventory.UpdateQualifiedState.Success", nil)
 
-	var mapping tag.RequestBody
+	var mapping qualifiedstate.UpdateBody
 
 	validationErrors, err := readAndValidateRequest(request, schemas.UpdateQualifiedStateSchema, &mapping)
 
@@ -186,16 +196,37 @@ func (inve *Inventory) UpdateQualifiedState(ctx context.Context, writer http.Res
 		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
 		return errors.New("could not validate request invalid schema")
 	}
-	objectMap := make(map[string]string)
-	objectMap["qualified_state"] = mapping.QualifiedState
+	if mapping.ChangedBy == "" {
+		mapping.ChangedBy = request.RemoteAddr
+	}
+	version, err := ifMatchVersion(request)
+	if err != nil {
+		mValidateRequestErr.Update(1)
+		return err
+	}
+
+	result, err := qualifiedstate.Change(inve.MasterDB, tag.BulkUpdateBody{
+		Epcs:           []string{mapping.Epc},
+		FacilityID:     mapping.FacilityID,
+		QualifiedState: mapping.QualifiedState,
+		ChangedBy:      mapping.ChangedBy,
+		Reason:         mapping.Reason,
+		Version:        version,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Error updating Tag")
+	}
+	if len(result.NotFound) > 0 {
+		return errors.Wrap(web.ErrNotFound, "Error updating Tag")
+	}
 
+	web.Respond(ctx, writer, nil, http.StatusOK)
 	mSuccess.Update(1)
-	return processUpdateRequest(ctx, inve.MasterDB, writer, mapping.Epc, mapping.FacilityID, objectMap)
 	return nil
 }
 
 // SetEpcContext updates the tag's epc context with the value in the request
-// 200 OK, 400 Bad Request, 500 Internal
+// 200 OK, 400 Bad Request, 404 Not Found, 412 Precondition Failed, 500 Internal
 // nolint[: lll[, dupl, ...]]
 func (inve *Inventory) SetEpcContext(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
 	metrics.GetOrRegisterGauge("Inventory.SetEpcContext.Attempt", nil).Update(1)
@@ -217,14 +248,59 @@ func (inve *Inventory) SetEpcContext(ctx context.Context, writer http.ResponseWr
 		return errors.New("could not validate request invalid schema")
 	}
 
-	objectMap := make(map[string]string)
-	objectMap["epc_context"] = mapping.EpcContext
+	epcContext, err := validateEpcContext(inve.MasterDB, mapping.EpcContext)
+	if err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidateRequestErr.Update(1)
+		} else {
+			mProcessRequestErr.Update(1)
+		}
+		return err
+	}
+
+	version, err := ifMatchVersion(request)
+	if err != nil {
+		mValidateRequestErr.Update(1)
+		return err
+	}
 
+	result, err := tag.BulkUpdate(inve.MasterDB, tag.BulkUpdateBody{
+		Epcs:       []string{mapping.Epc},
+		FacilityID: mapping.FacilityID,
+		Version:    version,
+	}, map[string]string{"epc_context": epcContext}, nil)
+	if err != nil {
+		return errors.Wrap(err, "Error updating Tag")
+	}
+	if len(result.NotFound) > 0 {
+		return errors.Wrap(web.ErrNotFound, "Error updating Tag")
+	}
+
+	web.Respond(ctx, writer, nil, http.StatusOK)
 	mSuccess.Update(1)
-	return processUpdateRequest(ctx, inve.MasterDB, writer, mapping.Epc, mapping.FacilityID, objectMap)
 	return nil
 }
 
+// BulkUpdateQualifiedState sets the qualified state of all the tags selected by a list of EPCs or a filter
+// 200 OK, 400 Bad Request, 500 Internal
+func (inve *Inventory) BulkUpdateQualifiedState(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	return processBulkUpdateRequest(ctx, "BulkUpdateQualifiedState", schemas.BulkUpdateQualifiedStateSchema, inve.MasterDB,
+		request, writer, qualifiedstate.Change)
+}
+
+// BulkSetEpcContext sets the epc context of all the tags selected by a list of EPCs or a filter
+// 200 OK, 400 Bad Request, 500 Internal
+func (inve *Inventory) BulkSetEpcContext(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+	return processBulkUpdateRequest(ctx, "BulkSetEpcContext", schemas.BulkSetEpcContextSchema, inve.MasterDB,
+		request, writer, func(masterDB *sql.DB, mapping tag.BulkUpdateBody) (tag.BulkUpdateResult, error) {
+			epcContext, err := validateEpcContext(masterDB, mapping.EpcContext)
+			if err != nil {
+				return tag.BulkUpdateResult{}, err
+			}
+			return tag.BulkUpdate(masterDB, mapping, map[string]string{"epc_context": epcContext}, nil)
+		})
+}
+
 // DeleteEpcContext removes the tag's epc context value
 // 200 OK, 400 Bad Request, 500 Internal
 func (inve *Inventory) DeleteEpcContext(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
@@ -270,8 +346,9 @@ func (inve *Inventory) DeleteEpcContext(ctx context.Context, writer http.Respons
 	return nil
 }
 
-// DeleteAllTags removes the tag's epc context value
-// 204 StatusNoContent, 400 Bad Request, 500 Internal
+// DeleteAllTags removes the tags of a facility, optionally only those of some sensors, or all the tags
+// if there is no request body
+// 200 OK, 204 StatusNoContent, 400 Bad Request, 500 Internal
 func (inve *Inventory) DeleteAllTags(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
 	log.Debugf("DeleteAllTags request received- content length = %d", request.ContentLength)
 	var err error
@@ -282,19 +359,49 @@ func (inve *Inventory) DeleteAllTags(ctx context.Context, writer http.ResponseWr
 	startTime := time.Now()
 	defer metrics.GetOrRegisterTimer("Inventory.DeleteAllTags.Latency", nil).Update(time.Since(startTime))
 	mSuccess := metrics.GetOrRegisterGauge("Inventory.DeleteAllTags.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.DeleteAllTags.Validation-Error", nil)
 	mDeleteErr := metrics.GetOrRegisterGauge("Inventory.DeleteAllTags.Delete-Error", nil)
 	mDeleteLatency := metrics.GetOrRegisterTimer("Inventory.DeleteAllTags.Delete-Latency", nil)
 	mSendDelCompleteErr := metrics.GetOrRegisterGauge("Inventory.DeleteAllTags.SendDelComplete-Error", nil)
 
 	deleteAllTagsTimer := time.Now()
-	if err = tag.DeleteTagCollection(inve.MasterDB); err != nil {
-		mDeleteErr.Update(1)
-		return errors.Wrap(err, "Error deleting tag collection")
-	}
-	mDeleteLatency.Update(time.Since(deleteAllTagsTimer))
+	if request.ContentLength > 0 {
+		var mapping tag.ResetRequest
 
-	mSuccess.Update(1)
-	web.Respond(ctx, writer, nil, http.StatusNoContent)
+		validationErrors, err := readAndValidateRequest(request, schemas.ResetSchema, &mapping)
+		if err != nil {
+			mValidationErr.Update(1)
+			return err
+		}
+		if validationErrors != nil {
+			mValidationErr.Update(1)
+			web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+			return nil
+		}
+
+		result, err := ResetInventory(inve.MasterDB, mapping)
+		if err != nil {
+			mDeleteErr.Update(1)
+			return errors.Wrap(err, "Error resetting tags")
+		}
+		mDeleteLatency.Update(time.Since(deleteAllTagsTimer))
+
+		mSuccess.Update(1)
+		web.Respond(ctx, writer, result, http.StatusOK)
+		if mapping.DryRun {
+			return nil
+		}
+	} else {
+		if err = tag.DeleteTagCollection(inve.MasterDB); err != nil {
+			mDeleteErr.Update(1)
+			return errors.Wrap(err, "Error deleting tag collection")
+		}
+		tagprocessor.ResetInventory("", nil, false)
+		mDeleteLatency.Update(time.Since(deleteAllTagsTimer))
+
+		mSuccess.Update(1)
+		web.Respond(ctx, writer, nil, http.StatusNoContent)
+	}
 
 	log.Debugf("DeleteAllTags completes at %v", time.Now())
 
@@ -306,7 +413,6 @@ func (inve *Inventory) DeleteAllTags(ctx context.Context, writer http.ResponseWr
 		}
 	}()
 
-	return err
 	return nil
 }
 
@@ -410,6 +516,232 @@ func (inve *Inventory) GetHandheldEvents(ctx context.Context, writer http.Respon
 	return nil
 }
 
+// aggregateBucketMillis is the precision of the last read time expected counts are computed with
+const aggregateBucketMillis = 60000
+
+// aggregateDay is the group key of the business day the tags were last read in, when bucketed by day
+const aggregateDay = "day"
+
+// GetAggregate counts tags grouped by any combination of product_id, facility_id, epc_state, qualified_state
+// and location, optionally by the business day they were last read in and with their confidence-weighted
+// expected count
+// 200 OK, 400 Bad Request, 500 Internal Error
+func (inve *Inventory) GetAggregate(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.GetAggregate.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.GetAggregate.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.GetAggregate.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.GetAggregate.Validation-Error", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.GetAggregate.Retrieve-Error", nil)
+	mConfidenceErr := metrics.GetOrRegisterGauge("Inventory.GetAggregate.Confidence-Error", nil)
+
+	values := request.URL.Query()
+	query := tag.AggregateQuery{
+		ProductID:      values.Get(tag.AggregateProductID),
+		FacilityID:     values.Get(tag.AggregateFacilityID),
+		EpcState:       values.Get(tag.AggregateEpcState),
+		QualifiedState: values.Get(tag.AggregateQualifiedState),
+		Location:       values.Get(tag.AggregateLocation),
+	}
+	if groupBy := values.Get("group_by"); groupBy != "" {
+		for _, field := range strings.Split(groupBy, ",") {
+			query.GroupBy = append(query.GroupBy, strings.TrimSpace(field))
+		}
+	}
+	if err := tag.ValidateGroupBy(query.GroupBy); err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+
+	withExpected := false
+	if expected := values.Get("expected"); expected != "" {
+		var err error
+		if withExpected, err = strconv.ParseBool(expected); err != nil {
+			mValidationErr.Update(1)
+			return errors.Wrap(web.ErrValidation, "expected must be true or false")
+		}
+	}
+
+	byDay := false
+	switch bucket := values.Get("bucket"); bucket {
+	case "":
+	case aggregateDay:
+		byDay = true
+	default:
+		mValidationErr.Update(1)
+		return errors.Wrapf(web.ErrValidation, "cannot bucket by %s", bucket)
+	}
+
+	var groups []tag.AggregateGroup
+	if !withExpected && !byDay {
+		var err error
+		if groups, err = tag.Aggregate(inve.MasterDB, query); err != nil {
+			mRetrieveErr.Update(1)
+			return errors.Wrap(err, "error aggregating tags")
+		}
+		web.Respond(ctx, writer, tag.Response{Results: groups}, http.StatusOK)
+		mSuccess.Update(1)
+		return nil
+	}
+
+	buckets, err := tag.AggregateBuckets(inve.MasterDB, query, aggregateBucketMillis)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error aggregating tags")
+	}
+
+	groupBy := query.GroupBy
+	if byDay {
+		// buckets are a minute long, so each is in a single business day of its facility
+		facilities, err := facility.CreateFacilityMap(inve.MasterDB)
+		if err != nil {
+			mRetrieveErr.Update(1)
+			return errors.Wrap(err, "unable to find the business days of the facilities")
+		}
+		for _, bucket := range buckets {
+			bucket.Group[aggregateDay] = facilities[bucket.FacilityID].BusinessDay(bucket.LastRead)
+		}
+		groupBy = append(append([]string{}, groupBy...), aggregateDay)
+	}
+
+	var representatives []tag.Tag
+	if withExpected {
+		// one representative tag per bucket, since its tags share the same confidence
+		representatives = make([]tag.Tag, len(buckets))
+		for i, bucket := range buckets {
+			representatives[i] = tag.Tag{FacilityID: bucket.FacilityID, ProductID: bucket.ProductID, LastRead: bucket.LastRead}
+		}
+		if err := ApplyConfidence(inve.MasterDB, representatives, inve.Url); err != nil {
+			mConfidenceErr.Update(1)
+			return err
+		}
+	}
+
+	groups = sumAggregateBuckets(groupBy, buckets, representatives)
+
+	web.Respond(ctx, writer, tag.Response{Results: groups}, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+// sumAggregateBuckets sums the counts of the buckets of each group, and their expected counts if the
+// representatives of the buckets are given. Groups are ordered by the values of the grouped fields.
+func sumAggregateBuckets(groupBy []string, buckets []tag.AggregateBucket, representatives []tag.Tag) []tag.AggregateGroup {
+	groups := make([]tag.AggregateGroup, 0)
+	positions := make(map[string]int)
+	for i, bucket := range buckets {
+		key := groupKey(groupBy, bucket.Group)
+		position, found := positions[key]
+		if !found {
+			position = len(groups)
+			positions[key] = position
+			group := tag.AggregateGroup{Group: bucket.Group}
+			if representatives != nil {
+				expected := 0.0
+				group.ExpectedCount = &expected
+			}
+			groups = append(groups, group)
+		}
+		groups[position].Count += bucket.Count
+		if representatives != nil {
+			*groups[position].ExpectedCount += representatives[i].Confidence * float64(bucket.Count)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		for _, field := range groupBy {
+			if groups[i].Group[field] != groups[j].Group[field] {
+				return groups[i].Group[field] < groups[j].Group[field]
+			}
+		}
+		return false
+	})
+	return groups
+}
+
+// groupKey returns the values of the grouped fields of the group, as a single key
+func groupKey(groupBy []string, group map[string]string) string {
+	values := make([]string, len(groupBy))
+	for i, field := range groupBy {
+		values[i] = group[field]
+	}
+	return strings.Join(values, "\x00")
+}
+
+// GetTagEvents retrieves the journal of tag events filtered by epc, product, facility, event type and time range
+// 200 OK, 400 Bad Request, 500 Internal Error
+func (inve *Inventory) GetTagEvents(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	metrics.GetOrRegisterGauge(`Inventory.GetTagEvents.Attempt`, nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.GetTagEvents.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.GetTagEvents.Validation-Error", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.GetTagEvents.Retrieve-Error", nil)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.GetTagEvents.Success`, nil)
+	mRetrieveLatency := metrics.GetOrRegisterTimer("Inventory.GetTagEvents.Retrieve-Latency", nil)
+
+	query, err := parseTagEventQuery(request)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+
+	retrieveTimer := time.Now()
+	events, err := tagevent.Retrieve(inve.MasterDB, query, inve.MaxSize)
+	if err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+			return web.ErrValidation
+		}
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving tag events")
+	}
+	mRetrieveLatency.Update(time.Since(retrieveTimer))
+
+	web.Respond(ctx, writer, tagevent.Response{Results: events}, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+func parseTagEventQuery(request *http.Request) (tagevent.Query, error) {
+	values := request.URL.Query()
+	query := tagevent.Query{
+		Epc:        values.Get("epc"),
+		ProductID:  values.Get("product_id"),
+		FacilityID: values.Get("facility_id"),
+		EventType:  values.Get("event_type"),
+	}
+
+	var err error
+	if value := values.Get("starttime"); value != "" {
+		if query.StartTime, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return query, web.ErrValidation
+		}
+	}
+	if value := values.Get("endtime"); value != "" {
+		if query.EndTime, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return query, web.ErrValidation
+		}
+	}
+	if value := values.Get("size"); value != "" {
+		if query.Size, err = strconv.Atoi(value); err != nil || query.Size < 1 {
+			return query, web.ErrValidation
+		}
+	}
+
+	return query, nil
+}
+
 // UpdateCoefficients updates the coefficients by facility_id (name) in the facility collection
 // 200 successful, 404 NotFound, 500 internal error
 // nolint[: dupl[, lll, ...]]
@@ -442,23 +774,605 @@ func (inve *Inventory) UpdateCoefficients(ctx context.Context, writer http.Respo
 		return nil
 	}
 
-	// build attributes to be updated
-	var updateFacility facility.Facility
-	updateFacility.Name = requestBody.FacilityID
-	updateFacility.Coefficients.DailyInventoryPercentage = requestBody.DailyInventoryPercentage
-	updateFacility.Coefficients.ProbUnreadToRead = requestBody.ProbUnreadToRead
-	updateFacility.Coefficients.ProbInStoreRead = requestBody.ProbInStoreRead
-	updateFacility.Coefficients.ProbExitError = requestBody.ProbExitError
+	if requestBody.ChangedBy == "" {
+		requestBody.ChangedBy = request.RemoteAddr
+	}
+
+	// build the change to be recorded
+	change := facility.CoefficientsVersion{
+		FacilityID: requestBody.FacilityID,
+		Coefficients: facility.Coefficients{
+			DailyInventoryPercentage: requestBody.DailyInventoryPercentage,
+			ProbUnreadToRead:         requestBody.ProbUnreadToRead,
+			ProbInStoreRead:          requestBody.ProbInStoreRead,
+			ProbExitError:            requestBody.ProbExitError,
+		},
+		ChangedBy: requestBody.ChangedBy,
+		Reason:    requestBody.Reason,
+	}
 
 	// Update by facility_id(name)
 	updateTimer := time.Now()
-	if err := facility.UpdateCoefficients(inve.MasterDB, updateFacility); err != nil {
+	change, err = facility.ChangeCoefficients(inve.MasterDB, change)
+	if err != nil {
 		mUpdateErr.Update(1)
 		return errors.Wrapf(err, "Update %s", requestBody.FacilityID)
 	}
 	mUpdateLatency.Update(time.Since(updateTimer))
 
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, change, http.StatusOK)
+	return nil
+}
+
+// RollbackCoefficients sets the coefficients of a facility back to the ones of a previous version
+// 200 OK, 400 Bad Request, 404 Not Found, 500 Internal Error
+func (inve *Inventory) RollbackCoefficients(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.RollbackCoefficients.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.RollbackCoefficients.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.RollbackCoefficients.Success", nil)
+	mRollbackErr := metrics.GetOrRegisterGauge("Inventory.RollbackCoefficients.Rollback-Error", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.RollbackCoefficients.Validation-Error", nil)
+
+	var requestBody facility.RollbackRequestBody
+
+	validationErrors, err := readAndValidateRequest(request, schemas.RollbackCoefficientsSchema, &requestBody)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	if requestBody.ChangedBy == "" {
+		requestBody.ChangedBy = request.RemoteAddr
+	}
+
+	change, err := facility.RollbackCoefficients(inve.MasterDB, requestBody.FacilityID, requestBody.Version,
+		requestBody.ChangedBy, requestBody.Reason)
+	if err != nil {
+		mRollbackErr.Update(1)
+		return errors.Wrapf(err, "Rollback %s to version %d", requestBody.FacilityID, requestBody.Version)
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, change, http.StatusOK)
+	return nil
+}
+
+// GetCoefficientsHistory retrieves the changes of the coefficients of a facility, most recent first
+// 200 OK, 400 Bad Request, 500 Internal Error
+func (inve *Inventory) GetCoefficientsHistory(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.GetCoefficientsHistory.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.GetCoefficientsHistory.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.GetCoefficientsHistory.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.GetCoefficientsHistory.Validation-Error", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.GetCoefficientsHistory.Retrieve-Error", nil)
+
+	history, err := facility.RetrieveCoefficientsHistory(inve.MasterDB, request.URL.Query().Get("facility_id"), inve.MaxSize)
+	if err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+			return err
+		}
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving coefficients history")
+	}
+
+	web.Respond(ctx, writer, facility.Response{Results: history}, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+// GetCoefficientEstimates retrieves the read probabilities estimated from the reads, with the ones currently used
+// 200 OK, 500 Internal Error
+func (inve *Inventory) GetCoefficientEstimates(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.GetCoefficientEstimates.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.GetCoefficientEstimates.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.GetCoefficientEstimates.Success", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.GetCoefficientEstimates.Retrieve-Error", nil)
+
+	estimates, err := estimation.Retrieve(inve.MasterDB, request.URL.Query().Get("facility_id"))
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving coefficient estimates")
+	}
+
+	inputs, err := loadConfidenceInputs(inve.MasterDB, inve.Url)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving confidence inputs")
+	}
+
+	web.Respond(ctx, writer, estimation.Response{Results: inputs.compare(estimates)}, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+// ApplyCoefficientEstimate replaces the read probabilities of the coefficients of a facility by their estimates
+// 200 OK, 400 Bad Request, 404 Not Found, 500 Internal Error
+func (inve *Inventory) ApplyCoefficientEstimate(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.ApplyCoefficientEstimate.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.ApplyCoefficientEstimate.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.ApplyCoefficientEstimate.Success", nil)
+	mApplyErr := metrics.GetOrRegisterGauge("Inventory.ApplyCoefficientEstimate.Apply-Error", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.ApplyCoefficientEstimate.Validation-Error", nil)
+
+	var requestBody estimation.ApplyRequestBody
+
+	validationErrors, err := readAndValidateRequest(request, schemas.ApplyEstimateSchema, &requestBody)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	if requestBody.ChangedBy == "" {
+		requestBody.ChangedBy = request.RemoteAddr
+	}
+
+	change, err := estimation.Apply(inve.MasterDB, requestBody.FacilityID, requestBody.ChangedBy, requestBody.Reason)
+	if err != nil {
+		mApplyErr.Update(1)
+		return errors.Wrapf(err, "Apply the estimate of %s", requestBody.FacilityID)
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, change, http.StatusOK)
+	return nil
+}
+
+// ExplainConfidence returns the confidence of a tag, with the inputs it is calculated from and their sources
+// 200 OK, 400 Bad Request, 404 Not Found, 500 Internal Error
+func (inve *Inventory) ExplainConfidence(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.ExplainConfidence.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.ExplainConfidence.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.ExplainConfidence.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.ExplainConfidence.Validation-Error", nil)
+	mNotFoundErr := metrics.GetOrRegisterGauge("Inventory.ExplainConfidence.NotFound-Error", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.ExplainConfidence.Retrieve-Error", nil)
+
+	epc := request.URL.Query().Get("epc")
+	if epc == "" {
+		mValidationErr.Update(1)
+		return errors.Wrap(web.ErrValidation, "epc is required")
+	}
+
+	tagData, err := tag.FindByEpc(inve.MasterDB, epc)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving tag")
+	}
+	if tagData.IsEmpty() {
+		mNotFoundErr.Update(1)
+		return errors.Wrapf(web.ErrNotFound, "tag %s not found", epc)
+	}
+
+	inputs, err := loadConfidenceInputs(inve.MasterDB, inve.Url)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving confidence inputs")
+	}
+
+	var computedDailyTurnMap map[string]dailyturn.History
+	if config.AppConfig.UseComputedDailyTurnInConfidence {
+		computedDailyTurnMap = dailyturn.CreateHistoryMap(inve.MasterDB, []tag.Tag{tagData})
+	}
+
+	web.Respond(ctx, writer, inputs.explain(tagData, computedDailyTurnMap, helper.UnixMilliNow()), http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+// CreateFacility creates a facility with its metadata, and the default coefficients unless it has its own
+// 201 Created, 400 Bad Request, 409 Conflict, 500 Internal Error
+func (inve *Inventory) CreateFacility(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.CreateFacility.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.CreateFacility.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.CreateFacility.Success", nil)
+	mCreateErr := metrics.GetOrRegisterGauge("Inventory.CreateFacility.Create-Error", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.CreateFacility.Validation-Error", nil)
+
+	var newFacility facility.Facility
+
+	validationErrors, err := readAndValidateRequest(request, schemas.CreateFacilitySchema, &newFacility)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	if err := facility.Create(inve.MasterDB, newFacility, facility.DefaultCoefficients(), request.RemoteAddr); err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+		} else {
+			mCreateErr.Update(1)
+		}
+		return errors.Wrapf(err, "Create %s", newFacility.Name)
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, nil, http.StatusCreated)
+	return nil
+}
+
+// UpdateFacility replaces the metadata of a facility, keeping its coefficients
+// 200 OK, 400 Bad Request, 404 Not Found, 500 Internal Error
+func (inve *Inventory) UpdateFacility(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.UpdateFacility.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.UpdateFacility.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.UpdateFacility.Success", nil)
+	mUpdateErr := metrics.GetOrRegisterGauge("Inventory.UpdateFacility.Update-Error", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.UpdateFacility.Validation-Error", nil)
+
+	var updateFacility facility.Facility
+
+	validationErrors, err := readAndValidateRequest(request, schemas.UpdateFacilitySchema, &updateFacility)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	if err := facility.Update(inve.MasterDB, updateFacility); err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+		} else {
+			mUpdateErr.Update(1)
+		}
+		return errors.Wrapf(err, "Update %s", updateFacility.Name)
+	}
+
 	mSuccess.Update(1)
 	web.Respond(ctx, writer, nil, http.StatusOK)
 	return nil
 }
+
+// DeleteFacility deletes a facility which has no sensors or tags left
+// 204 No Content, 400 Bad Request, 404 Not Found, 409 Conflict, 500 Internal Error
+func (inve *Inventory) DeleteFacility(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.DeleteFacility.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.DeleteFacility.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.DeleteFacility.Success", nil)
+	mDeleteErr := metrics.GetOrRegisterGauge("Inventory.DeleteFacility.Delete-Error", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.DeleteFacility.Validation-Error", nil)
+
+	var requestBody facility.DeleteRequestBody
+
+	validationErrors, err := readAndValidateRequest(request, schemas.DeleteFacilitySchema, &requestBody)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	if err := facility.Delete(inve.MasterDB, requestBody.Name); err != nil {
+		mDeleteErr.Update(1)
+		return errors.Wrapf(err, "Delete %s", requestBody.Name)
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, nil, http.StatusNoContent)
+	return nil
+}
+
+// InvalidateProductDataCache expires the cached product data, so that it is fetched again on its next use
+// 204 No Content
+func (inve *Inventory) InvalidateProductDataCache(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.InvalidateProductDataCache.Attempt", nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.InvalidateProductDataCache.Success", nil)
+
+	productdata.InvalidateCache()
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, nil, http.StatusNoContent)
+	return nil
+}
+
+// UpsertQualifiedStateDefinition creates or replaces the qualified-state workflow of a facility
+// 200 OK, 400 Bad Request, 500 Internal
+func (inve *Inventory) UpsertQualifiedStateDefinition(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.UpsertQualifiedStateDefinition.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.UpsertQualifiedStateDefinition.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.UpsertQualifiedStateDefinition.Success", nil)
+	mUpsertErr := metrics.GetOrRegisterGauge("Inventory.UpsertQualifiedStateDefinition.Upsert-Error", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.UpsertQualifiedStateDefinition.Validation-Error", nil)
+
+	var definition qualifiedstate.Definition
+
+	validationErrors, err := readAndValidateRequest(request, schemas.QualifiedStateDefinitionSchema, &definition)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	if err := qualifiedstate.UpsertDefinition(inve.MasterDB, definition); err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+		} else {
+			mUpsertErr.Update(1)
+		}
+		return errors.Wrapf(err, "Upsert qualified states of %s", definition.FacilityID)
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, nil, http.StatusOK)
+	return nil
+}
+
+// GetQualifiedStateDefinitions retrieves the qualified-state definitions, optionally of one facility
+// 200 OK, 500 Internal Error
+func (inve *Inventory) GetQualifiedStateDefinitions(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.GetQualifiedStateDefinitions.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.GetQualifiedStateDefinitions.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.GetQualifiedStateDefinitions.Success", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.GetQualifiedStateDefinitions.Retrieve-Error", nil)
+
+	definitions, err := qualifiedstate.RetrieveDefinitions(inve.MasterDB, request.URL.Query().Get("facility_id"))
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving qualified state definitions")
+	}
+
+	web.Respond(ctx, writer, qualifiedstate.Response{Results: definitions}, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+// GetQualifiedStateHistory retrieves the changes of qualified state of a tag, most recent first
+// 200 OK, 400 Bad Request, 500 Internal Error
+func (inve *Inventory) GetQualifiedStateHistory(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.GetQualifiedStateHistory.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.GetQualifiedStateHistory.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.GetQualifiedStateHistory.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.GetQualifiedStateHistory.Validation-Error", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.GetQualifiedStateHistory.Retrieve-Error", nil)
+
+	history, err := qualifiedstate.RetrieveHistory(inve.MasterDB, request.URL.Query().Get("epc"), inve.MaxSize)
+	if err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+			return err
+		}
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "error retrieving qualified state history")
+	}
+
+	web.Respond(ctx, writer, qualifiedstate.Response{Results: history}, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+// RegisterEpcContextSchema registers the JSON schema structured epc contexts must be valid against
+// 200 OK, 400 Bad Request, 500 Internal
+func (inve *Inventory) RegisterEpcContextSchema(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.RegisterEpcContextSchema.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.RegisterEpcContextSchema.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.RegisterEpcContextSchema.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.RegisterEpcContextSchema.Validation-Error", nil)
+	mRegisterErr := metrics.GetOrRegisterGauge("Inventory.RegisterEpcContextSchema.Register-Error", nil)
+
+	var contextSchema json.RawMessage
+	if err := json.NewDecoder(request.Body).Decode(&contextSchema); err != nil {
+		mValidationErr.Update(1)
+		return errors.Wrap(web.ErrValidation, err.Error())
+	}
+
+	if err := epccontext.RegisterSchema(inve.MasterDB, contextSchema); err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+		} else {
+			mRegisterErr.Update(1)
+		}
+		return err
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, nil, http.StatusOK)
+	return nil
+}
+
+// GetEpcContextSchema returns the registered epc context schema
+// 200 OK, 404 Not Found, 500 Internal
+func (inve *Inventory) GetEpcContextSchema(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.GetEpcContextSchema.Attempt", nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.GetEpcContextSchema.Success", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.GetEpcContextSchema.Retrieve-Error", nil)
+
+	contextSchema, err := epccontext.FindSchema(inve.MasterDB)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return err
+	}
+	if contextSchema == nil {
+		return web.ErrNotFound
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, contextSchema, http.StatusOK)
+	return nil
+}
+
+// DeleteEpcContextSchema unregisters the epc context schema
+// 200 OK, 500 Internal
+func (inve *Inventory) DeleteEpcContextSchema(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.DeleteEpcContextSchema.Attempt", nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.DeleteEpcContextSchema.Success", nil)
+	mDeleteErr := metrics.GetOrRegisterGauge("Inventory.DeleteEpcContextSchema.Delete-Error", nil)
+
+	if err := epccontext.DeleteSchema(inve.MasterDB); err != nil {
+		mDeleteErr.Update(1)
+		return err
+	}
+
+	mSuccess.Update(1)
+	web.Respond(ctx, writer, nil, http.StatusOK)
+	return nil
+}
+
+// PurgeTags deletes the departed tags last read more than the requested number of days ago, or counts them in a dry run
+// 200 OK, 400 Bad Request, 500 Internal
+func (inve *Inventory) PurgeTags(ctx context.Context, writer http.ResponseWriter, request *http.Request) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory.PurgeTags.Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory.PurgeTags.Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.PurgeTags.Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.PurgeTags.Validation-Error", nil)
+	mPurgeErr := metrics.GetOrRegisterGauge("Inventory.PurgeTags.Purge-Error", nil)
+
+	var mapping tag.PurgingRequest
+
+	validationErrors, err := readAndValidateRequest(request, schemas.PurgingSchema, &mapping)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	cutoffs, err := PurgeCutoffs(inve.MasterDB, helper.UnixMilliNow(), mapping.Days)
+	if err != nil {
+		mPurgeErr.Update(1)
+		return err
+	}
+	result := tag.PurgeResult{DryRun: mapping.DryRun}
+	if mapping.DryRun {
+		result.Count, err = tag.CountPurgeable(inve.MasterDB, cutoffs)
+	} else {
+		var journal tag.Journal
+		if journal, err = tagevent.RemovalJournal(inve.MasterDB, tagevent.PurgeSource); err == nil {
+			result.Count, err = tag.Purge(inve.MasterDB, cutoffs, config.AppConfig.PurgingBatchSize, journal)
+		}
+	}
+	if err != nil {
+		mPurgeErr.Update(1)
+		return err
+	}
+
+	web.Respond(ctx, writer, result, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
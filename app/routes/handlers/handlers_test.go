@@ -9,9 +9,11 @@ package handlers
 import (
 	"bytes"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/epccontext"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/integrationtest"
@@ -818,6 +820,81 @@ func TestUpdateQualifiedState(t *testing.T) {
 
 }
 
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		ifMatch  string
+		expected string
+		valid    bool
+	}{
+		{"", "<nil>", true},
+		{"*", "<nil>", true},
+		{"3", "3", true},
+		{`"3"`, "3", true},
+		{"three", "<nil>", false},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest("PUT", "/inventory/update/qualifiedstate", nil)
+		if test.ifMatch != "" {
+			request.Header.Set("If-Match", test.ifMatch)
+		}
+		version, err := ifMatchVersion(request)
+		if !test.valid {
+			if errors.Cause(err) != web.ErrValidation {
+				t.Errorf("If-Match %s: expected a validation error, got %v", test.ifMatch, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("If-Match %s: unexpected error %v", test.ifMatch, err)
+			continue
+		}
+		got := "<nil>"
+		if version != nil {
+			got = fmt.Sprint(*version)
+		}
+		if got != test.expected {
+			t.Errorf("If-Match %s: expected version %s, got %s", test.ifMatch, test.expected, got)
+		}
+	}
+}
+
+func TestUpdateQualifiedStateIfMatch(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	epc := "100683590000000000001106"
+	if err := tag.Replace(testDB.DB, []tag.Tag{{Epc: epc, FacilityID: "test-facility", QualifiedState: "unknown"}}); err != nil {
+		t.Fatalf("Unable to insert tag: %+v", err)
+	}
+
+	inventory := Inventory{testDB.DB, config.AppConfig.ResponseLimit, ""}
+	handler := web.Handler(inventory.UpdateQualifiedState)
+	body := fmt.Sprintf(`{"epc": "%s", "facility_id": "test-facility", "qualified_state": "sold"}`, epc)
+
+	// the tag is at version 1, so a stale version fails and the current one succeeds
+	for _, test := range []struct {
+		ifMatch string
+		code    int
+	}{
+		{`"2"`, http.StatusPreconditionFailed},
+		{`"1"`, http.StatusOK},
+		{`"1"`, http.StatusPreconditionFailed},
+	} {
+		request := httptest.NewRequest("PUT", "/inventory/update/qualifiedstate", strings.NewReader(body))
+		request.Header.Set("If-Match", test.ifMatch)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.code {
+			t.Errorf("If-Match %s: expected status %d, got %d", test.ifMatch, test.code, recorder.Code)
+		}
+	}
+
+	if err := validateQualifiedStateUpdate(epc, "sold")(testDB.DB, nil, t); err != nil {
+		t.Error(err)
+	}
+}
+
 // nolint :dupl
 func TestGetFacilities(t *testing.T) {
 	testDB := dbHost.CreateDB(t)
@@ -883,7 +960,7 @@ func TestMapRequestToOdata(t *testing.T) {
 
 	actualMap := make(map[string][]string)
 	filterArray := []string{"facility_id eq 'store001' and qualified_state eq 'sold' and epc_state eq 'sold'" +
-		" and confidence ge 0.75 and productId eq '12345678978945' and last_read ge 1482624000000" +
+		" and confidence ge 0.75 and product_id eq '12345678978945' and last_read ge 1482624000000" +
 		" and last_read le 1483228800000 and last_read le 1483228800000"}
 	expectedMap := map[string][]string{
 		"$filter": filterArray,
@@ -921,6 +998,15 @@ func TestMapEpcRequestToOdata(t *testing.T) {
 		&requestBody)
 }
 
+func TestMapEpcContextRequestToOdata(t *testing.T) {
+	var requestBody = tag.RequestBody{
+		FacilityID: "store001",
+		EpcContext: map[string]string{"carton.id": "C7", "asnId": "123"},
+	}
+	compareMaps(t, []string{"facility_id eq 'store001' and epc_context.asnId eq '123' and epc_context.carton.id eq 'C7'"},
+		&requestBody)
+}
+
 func compareMaps(t *testing.T, filterStrings []string, requestBody *tag.RequestBody) {
 	expectedMap := map[string][]string{
 		"$filter": filterStrings,
@@ -1143,6 +1229,118 @@ func TestUpdateCoefficientsNegative(t *testing.T) {
 	testHandlerHelper(searchGtinTests, "PUT", handler, testDB.DB, t)
 
 }
+func TestRollbackCoefficients(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	if err := facility.Create(testDB.DB, facility.Facility{Name: "Tavern"}, facility.DefaultCoefficients(), ""); err != nil {
+		t.Fatalf("Unable to create facility: %+v", err)
+	}
+
+	inventory := Inventory{testDB.DB, config.AppConfig.ResponseLimit, ""}
+
+	testHandlerHelper([]inputTest{
+		{
+			title: "Change",
+			input: []byte(`{"facility_id":"Tavern","dailyinventorypercentage":0.3,"probunreadtoread":0.3,"probinstoreread":0.3,"probexiterror":0.3,"reason":"test"}`),
+			code:  []int{200},
+		},
+	}, "PUT", web.Handler(inventory.UpdateCoefficients), testDB.DB, t)
+
+	testHandlerHelper([]inputTest{
+		{
+			title: "Rollback",
+			input: []byte(`{"facility_id":"Tavern","version":1}`),
+			code:  []int{200},
+			validate: func(db *sql.DB, recorder *httptest.ResponseRecorder, t *testing.T) error {
+				var change facility.CoefficientsVersion
+				if err := json.Unmarshal(recorder.Body.Bytes(), &change); err != nil {
+					return err
+				}
+				if change.Version != 3 || change.RolledBackTo != 1 {
+					return fmt.Errorf("expected version 3 rolled back to 1, got %+v", change)
+				}
+				return nil
+			},
+		},
+		{
+			title: "Rollback unknown version",
+			input: []byte(`{"facility_id":"Tavern","version":9}`),
+			code:  []int{404},
+		},
+		{
+			title: "Rollback without version",
+			input: []byte(`{"facility_id":"Tavern"}`),
+			code:  []int{400},
+		},
+	}, "PUT", web.Handler(inventory.RollbackCoefficients), testDB.DB, t)
+}
+
+func TestManageFacility(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	inventory := Inventory{testDB.DB, config.AppConfig.ResponseLimit, ""}
+
+	testHandlerHelper([]inputTest{
+		{
+			title: "Create",
+			input: []byte(`{"name":"store555","display_name":"Store 555","timezone":"America/Los_Angeles","type":"store"}`),
+			code:  []int{201},
+		},
+		{
+			title: "Create existing",
+			input: []byte(`{"name":"store555"}`),
+			code:  []int{409},
+		},
+		{
+			title: "Create unknown timezone",
+			input: []byte(`{"name":"store556","timezone":"Mars/Olympus_Mons"}`),
+			code:  []int{400},
+		},
+	}, "POST", web.Handler(inventory.CreateFacility), testDB.DB, t)
+
+	testHandlerHelper([]inputTest{
+		{
+			title: "Update",
+			input: []byte(`{"name":"store555","display_name":"Store 555 Downtown","asn_receiving":true}`),
+			code:  []int{200},
+			validate: func(db *sql.DB, recorder *httptest.ResponseRecorder, t *testing.T) error {
+				facilities, err := facility.CreateFacilityMap(db)
+				if err != nil {
+					return err
+				}
+				updated := facilities["store555"]
+				if updated.DisplayName != "Store 555 Downtown" || !updated.ASNReceiving || updated.Timezone != "" {
+					return fmt.Errorf("expected the metadata to be replaced, got %+v", updated)
+				}
+				if updated.Coefficients != facility.DefaultCoefficients() {
+					return fmt.Errorf("expected the coefficients to be kept, got %+v", updated.Coefficients)
+				}
+				return nil
+			},
+		},
+		{
+			title: "Update unknown",
+			input: []byte(`{"name":"store556"}`),
+			code:  []int{404},
+		},
+	}, "PUT", web.Handler(inventory.UpdateFacility), testDB.DB, t)
+
+	testHandlerHelper([]inputTest{
+		{
+			title: "Delete",
+			input: []byte(`{"name":"store555"}`),
+			code:  []int{204},
+		},
+		{
+			title: "Delete unknown",
+			input: []byte(`{"name":"store555"}`),
+			code:  []int{404},
+		},
+	}, "DELETE", web.Handler(inventory.DeleteFacility), testDB.DB, t)
+}
+
 func TestSetEpcContext(t *testing.T) {
 	testDB := dbHost.CreateDB(t)
 	defer testDB.Close()
@@ -1189,6 +1387,59 @@ func TestSetEpcContext(t *testing.T) {
 
 }
 
+func TestBulkSetEpcContext(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	epc := "100683590000000000001106"
+	facility := "test-facility"
+	if err := epccontext.RegisterSchema(testDB.DB, json.RawMessage(`{
+		"type": "object",
+		"required": ["asnId"],
+		"properties": {"asnId": {"type": "string"}}
+	}`)); err != nil {
+		t.Fatalf("Unable to register epc context schema: %+v", err)
+	}
+
+	// nolint :dupl
+	var epcContextTests = []inputTest{
+		{
+			title: "Set structured context success",
+			setup: insertTag(tag.Tag{
+				Epc:        epc,
+				FacilityID: facility,
+				EpcContext: "old-text",
+			}),
+			input: []byte(fmt.Sprintf(`{"epcs": ["%s"], "epc_context": {"asnId": "123"}}`, epc)),
+			code:  []int{200},
+			validate: validateAll([]validateFunc{
+				validateEpcContextSet(epc, `{"asnId":"123"}`),
+			}),
+			destroy: deleteTag(epc),
+		},
+		{
+			title: "Structured context rejected by the registered schema",
+			setup: insertTag(tag.Tag{
+				Epc:        epc,
+				FacilityID: facility,
+				EpcContext: "old-text",
+			}),
+			input: []byte(fmt.Sprintf(`{"epcs": ["%s"], "epc_context": {"asnId": 123}}`, epc)),
+			code:  []int{400},
+			validate: validateAll([]validateFunc{
+				validateEpcContextSet(epc, "old-text"),
+			}),
+			destroy: deleteTag(epc),
+		},
+	}
+
+	inventory := Inventory{testDB.DB, config.AppConfig.ResponseLimit, ""}
+
+	handler := web.Handler(inventory.BulkSetEpcContext)
+
+	testHandlerHelper(epcContextTests, "PUT", handler, testDB.DB, t)
+}
+
 func TestDeleteEpcContext(t *testing.T) {
 	testDB := dbHost.CreateDB(t)
 	defer testDB.Close()
@@ -1255,3 +1506,159 @@ func TestDeleteAllTags(t *testing.T) {
 
 	testHandlerHelper(deleteAllTagTests, "DELETE", handler, testDB.DB, t)
 }
+
+func TestExportTags(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		result := buildProductData(0.2, 0.75, 0.2, 0.1, "00111111")
+		jsonData, _ := json.Marshal(result)
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(jsonData)
+	}))
+	defer testServer.Close()
+
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	numOfTags := exportBatchSize + 10
+	tags := make([]tag.Tag, numOfTags)
+	for i := range tags {
+		tags[i] = tag.Tag{Epc: fmt.Sprintf("30143639F84191AD2290%04d", i), FacilityID: "store001", ProductID: "00111111"}
+	}
+	tags[0].FacilityID = "store002"
+	if err := tag.Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	inventory := Inventory{testDB.DB, config.AppConfig.ResponseLimit, testServer.URL + "/skus"}
+	handler := web.Handler(inventory.ExportTags)
+
+	// NDJSON with OData filter
+	request := httptest.NewRequest("GET", "/inventory/export?$filter=facility_id%20eq%20'store001'", nil)
+	request.Header.Set("Accept", "application/x-ndjson")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Success expected: %d", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != ndjsonContentType {
+		t.Errorf("expected content type %s, got %s", ndjsonContentType, contentType)
+	}
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if len(lines) != numOfTags-1 {
+		t.Fatalf("expected %d NDJSON lines, got %d", numOfTags-1, len(lines))
+	}
+	var exported tag.Tag
+	if err := json.Unmarshal([]byte(lines[0]), &exported); err != nil {
+		t.Fatalf("invalid NDJSON line %s: %+v", lines[0], err)
+	}
+	if exported.ProductID != "00111111" || exported.FacilityID != "store001" {
+		t.Errorf("unexpected exported tag %+v", exported)
+	}
+
+	// CSV with request body filter
+	body := []byte(`{"facility_id":"store002"}`)
+	request = httptest.NewRequest("GET", "/inventory/export", bytes.NewBuffer(body))
+	request.Header.Set("Accept", "text/csv")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Success expected: %d", recorder.Code)
+	}
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %+v", err)
+	}
+	if len(records) != 2 || !reflect.DeepEqual(records[0], exportColumns) || records[1][0] != tags[0].Epc {
+		t.Errorf("expected the header and one tag, got %v", records)
+	}
+
+	// unsupported format and options
+	request = httptest.NewRequest("GET", "/inventory/export", nil)
+	request.Header.Set("Accept", "application/xml")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotAcceptable {
+		t.Errorf("expected %d, got %d", http.StatusNotAcceptable, recorder.Code)
+	}
+
+	request = httptest.NewRequest("GET", "/inventory/export?$top=10", nil)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestNegotiateExportFormat(t *testing.T) {
+	testCases := map[string]string{
+		"":                                ndjsonContentType,
+		"*/*":                             ndjsonContentType,
+		"application/x-ndjson":            ndjsonContentType,
+		"text/csv":                        csvContentType,
+		"text/csv; charset=utf-8":         csvContentType,
+		"application/xml, text/csv;q=0.5": csvContentType,
+	}
+	for accept, expected := range testCases {
+		contentType, err := negotiateExportFormat(accept)
+		if err != nil || contentType != expected {
+			t.Errorf("expected %s for %q, got %s (%v)", expected, accept, contentType, err)
+		}
+	}
+
+	if _, err := negotiateExportFormat("application/xml"); errors.Cause(err) != web.ErrNotAcceptable {
+		t.Errorf("expected not acceptable error, got %v", err)
+	}
+}
+
+func TestExportWritersFail(t *testing.T) {
+	var ndjson bytes.Buffer
+	export := newNDJSONExportWriter(&ndjson)
+	if err := export.write(tag.Tag{Epc: "3014AA01"}); err != nil {
+		t.Fatalf("Unable to write tag: %s", err)
+	}
+	if err := export.fail("export interrupted"); err != nil {
+		t.Fatalf("Unable to write error record: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(ndjson.String()), "\n")
+	if len(lines) != 2 || lines[1] != `{"error":"export interrupted"}` {
+		t.Errorf("expected the tag then the error record, got %q", lines)
+	}
+
+	var csvBuffer bytes.Buffer
+	csvExport, err := newCSVExportWriter(&csvBuffer)
+	if err != nil {
+		t.Fatalf("Unable to write header: %s", err)
+	}
+	if err := csvExport.fail("export interrupted"); err != nil {
+		t.Fatalf("Unable to write error record: %s", err)
+	}
+	if err := csvExport.flush(); err != nil {
+		t.Fatalf("Unable to flush: %s", err)
+	}
+	lines = strings.Split(strings.TrimSpace(csvBuffer.String()), "\n")
+	if len(lines) != 2 || lines[1] != csvErrorMarker+",export interrupted" {
+		t.Errorf("expected the header then the error row, got %q", lines)
+	}
+}
+
+func TestSumAggregateBucketsByDay(t *testing.T) {
+	groupBy := []string{tag.AggregateProductID, aggregateDay}
+	buckets := []tag.AggregateBucket{
+		{Group: map[string]string{tag.AggregateProductID: "p1", aggregateDay: "2019-11-05"}, Count: 2},
+		{Group: map[string]string{tag.AggregateProductID: "p1", aggregateDay: "2019-11-04"}, Count: 1},
+		{Group: map[string]string{tag.AggregateProductID: "p1", aggregateDay: "2019-11-05"}, Count: 3},
+	}
+
+	groups := sumAggregateBuckets(groupBy, buckets, nil)
+	if len(groups) != 2 {
+		t.Fatalf("expected a group per day, got %+v", groups)
+	}
+	if groups[0].Group[aggregateDay] != "2019-11-04" || groups[0].Count != 1 || groups[0].ExpectedCount != nil {
+		t.Errorf("expected the earlier day first without expected count, got %+v", groups[0])
+	}
+	if groups[1].Group[aggregateDay] != "2019-11-05" || groups[1].Count != 5 {
+		t.Errorf("expected the buckets of the same day summed, got %+v", groups[1])
+	}
+}
@@ -10,12 +10,14 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/cloudconnector/event"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"io"
 	"net/http"
-	"plugin"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -25,10 +27,15 @@ import (
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/dailyturn"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/statemodel"
 
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/confidence"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/epccontext"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/estimation"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/qualifiedstate"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/routes/schemas"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/productdata"
 	"github.com/pkg/errors"
@@ -43,109 +50,250 @@ func ApplyConfidence(session *sql.DB, tags []tag.Tag, url string) error {
 		return nil
 	}
 
-	// Getting coefficients from database by facilityID
-	facilities, err := facility.CreateFacilityMap(session)
+	inputs, err := loadConfidenceInputs(session, url)
 	if err != nil {
 		return err
 	}
 
+	inputs.apply(session, tags)
+	return nil
+}
+
+// confidenceInputs are the coefficients confidence is computed from, loaded once so that
+// they can be applied to many batches of tags
+type confidenceInputs struct {
+	facilities     map[string]facility.Facility
+	productDataMap map[string]productdata.ProductMetadata
+	definitions    qualifiedstate.Definitions
+}
+
+func loadConfidenceInputs(session *sql.DB, url string) (confidenceInputs, error) {
+	var inputs confidenceInputs
+	var err error
+
+	// Getting coefficients from database by facilityID
+	inputs.facilities, err = facility.CreateFacilityMap(session)
+	if err != nil {
+		return inputs, err
+	}
+
 	// Getting coefficients for gtin from sku-mapping service
-	productDataMap, err := productdata.CreateProductDataMap(url)
+	inputs.productDataMap, err = productdata.CachedProductDataMap(url)
 	if err != nil {
-		return err
+		return inputs, err
+	}
+
+	// Qualified states which zero the confidence
+	inputs.definitions, err = qualifiedstate.CreateDefinitionMap(session)
+	if err != nil {
+		return inputs, err
 	}
 
+	return inputs, nil
+}
+
+func (inputs confidenceInputs) apply(session *sql.DB, tags []tag.Tag) {
 	// Create lookup map for computed daily turn values
 	var computedDailyTurnMap map[string]dailyturn.History
 	if config.AppConfig.UseComputedDailyTurnInConfidence {
 		computedDailyTurnMap = dailyturn.CreateHistoryMap(session, tags)
 	}
 
-	var dailyInvPerc float64
-	var probUnreadToRead float64
-	var probInStore float64
-	var probExitError float64
-
+	now := helper.UnixMilliNow()
+	explanations := make([]confidence.Explanation, len(tags))
 	for i := 0; i < len(tags); i++ {
-		// Get coefficients
-		facilityID := tags[i].FacilityID
-		tagFacility, foundFacility := facilities[facilityID]
-		lastRead := tags[i].LastRead
-		if foundFacility {
-			dailyInvPerc = tagFacility.Coefficients.DailyInventoryPercentage
-			probUnreadToRead = tagFacility.Coefficients.ProbUnreadToRead
-			probInStore = tagFacility.Coefficients.ProbInStoreRead
-			probExitError = tagFacility.Coefficients.ProbExitError
-		} else {
-			dailyInvPerc = config.AppConfig.DailyInventoryPercentage
-			probUnreadToRead = config.AppConfig.ProbUnreadToRead
-			probInStore = config.AppConfig.ProbInStoreRead
-			probExitError = config.AppConfig.ProbExitError
-		}
-		gtin := tags[i].ProductID
+		explanations[i] = inputs.describe(tags[i], computedDailyTurnMap, now)
+	}
+	calculateConfidence(tags, explanations)
+	for i := 0; i < len(tags); i++ {
+		tags[i].Confidence = explanations[i].Confidence
+	}
+}
 
-		product, foundProduct := productDataMap[gtin]
-		if foundProduct {
-			log.Debugf("Found product: %s", product.ProductID)
+// explain calculates the confidence of the tag, and tells which source each coefficient came from
+func (inputs confidenceInputs) explain(tagData tag.Tag, computedDailyTurnMap map[string]dailyturn.History, now int64) confidence.Explanation {
+	explanations := []confidence.Explanation{inputs.describe(tagData, computedDailyTurnMap, now)}
+	calculateConfidence([]tag.Tag{tagData}, explanations)
+	return explanations[0]
+}
+
+// describe tells which coefficients the confidence of the tag is calculated from, and where they came from
+func (inputs confidenceInputs) describe(tagData tag.Tag, computedDailyTurnMap map[string]dailyturn.History, now int64) confidence.Explanation {
+	explanation := confidence.Explanation{
+		Epc:            tagData.Epc,
+		FacilityID:     tagData.FacilityID,
+		LastRead:       tagData.LastRead,
+		ElapsedMillis:  now - tagData.LastRead,
+		QualifiedState: tagData.QualifiedState,
+	}
+
+	// Get coefficients
+	tagFacility, foundFacility := inputs.facilities[tagData.FacilityID]
+	if foundFacility {
+		explanation.DailyInventoryPercentage = confidence.Input{Value: tagFacility.Coefficients.DailyInventoryPercentage, Source: confidence.SourceFacility}
+		explanation.ProbUnreadToRead = confidence.Input{Value: tagFacility.Coefficients.ProbUnreadToRead, Source: confidence.SourceFacility}
+		explanation.ProbInStoreRead = confidence.Input{Value: tagFacility.Coefficients.ProbInStoreRead, Source: confidence.SourceFacility}
+		explanation.ProbExitError = confidence.Input{Value: tagFacility.Coefficients.ProbExitError, Source: confidence.SourceFacility}
+	} else {
+		explanation.DailyInventoryPercentage = confidence.Input{Value: config.AppConfig.DailyInventoryPercentage, Source: confidence.SourceDefault}
+		explanation.ProbUnreadToRead = confidence.Input{Value: config.AppConfig.ProbUnreadToRead, Source: confidence.SourceDefault}
+		explanation.ProbInStoreRead = confidence.Input{Value: config.AppConfig.ProbInStoreRead, Source: confidence.SourceDefault}
+		explanation.ProbExitError = confidence.Input{Value: config.AppConfig.ProbExitError, Source: confidence.SourceDefault}
+	}
+	gtin := tagData.ProductID
+
+	product, foundProduct := inputs.productDataMap[gtin]
+	if foundProduct {
+		log.Debugf("Found product: %s", product.ProductID)
+		// Only override if value isn't 0
+		if product.BecomingReadable != 0 {
+			explanation.ProbUnreadToRead = confidence.Input{Value: product.BecomingReadable, Source: confidence.SourceProduct}
+		}
+		if product.BeingRead != 0 {
 			// Only override if value isn't 0
-			if product.BecomingReadable != 0 {
-				probUnreadToRead = product.BecomingReadable
-			}
-			if product.BeingRead != 0 {
-				// Only override if value isn't 0
-				probInStore = product.BeingRead
-			}
-			if product.ExitError != 0 {
-				// Only override if value isn't 0
-				probExitError = product.ExitError
-			}
-			if product.DailyTurn != 0 {
-				// Only override if value isn't 0
-				dailyInvPerc = product.DailyTurn
-			}
+			explanation.ProbInStoreRead = confidence.Input{Value: product.BeingRead, Source: confidence.SourceProduct}
+		}
+		if product.ExitError != 0 {
+			// Only override if value isn't 0
+			explanation.ProbExitError = confidence.Input{Value: product.ExitError, Source: confidence.SourceProduct}
 		}
+		if product.DailyTurn != 0 {
+			// Only override if value isn't 0
+			explanation.DailyInventoryPercentage = confidence.Input{Value: product.DailyTurn, Source: confidence.SourceProduct}
+		}
+	}
 
-		// Only override if enabled in config
-		if config.AppConfig.UseComputedDailyTurnInConfidence {
-			history, foundHistory := computedDailyTurnMap[gtin]
-			if foundHistory && history.DailyTurn != 0 {
-				// Only override if value isn't 0
-				dailyInvPerc = history.DailyTurn
-			}
+	// Only override if enabled in config
+	if config.AppConfig.UseComputedDailyTurnInConfidence {
+		history, foundHistory := computedDailyTurnMap[gtin]
+		if foundHistory && history.DailyTurn != 0 {
+			// Only override if value isn't 0
+			explanation.DailyInventoryPercentage = confidence.Input{Value: history.DailyTurn, Source: confidence.SourceComputedDailyTurn}
 		}
+	}
 
-		log.Tracef("DailyInvPerc = %f, probUnreadToRead = %f, probInStore = %f, probExitError = %f",
-			dailyInvPerc, probUnreadToRead, probInStore, probExitError)
-		tags[i].Confidence = confidenceCalc(dailyInvPerc, probUnreadToRead, probInStore, probExitError, lastRead)
+	for _, input := range []confidence.Input{explanation.DailyInventoryPercentage, explanation.ProbUnreadToRead,
+		explanation.ProbInStoreRead, explanation.ProbExitError} {
+		if input.Source == confidence.SourceFacility {
+			explanation.CoefficientsVersion = tagFacility.CoefficientsVersion
+		}
 	}
-	return nil
+
+	explanation.BlockedByQualifiedState = inputs.definitions.BlocksConfidence(tagData.FacilityID, tagData.QualifiedState)
+
+	log.Tracef("DailyInvPerc = %f, probUnreadToRead = %f, probInStore = %f, probExitError = %f",
+		explanation.DailyInventoryPercentage.Value, explanation.ProbUnreadToRead.Value,
+		explanation.ProbInStoreRead.Value, explanation.ProbExitError.Value)
+	return explanation
 }
 
-type confidenceFunc func(float64, float64, float64, float64, int64) float64
+// calculateConfidence sets the confidence of the explanations of the tags, calculated by the selected provider
+// in one call. The confidence of the tags blocked by their qualified state is 0. If the provider fails, the
+// built-in model is used instead.
+func calculateConfidence(tags []tag.Tag, explanations []confidence.Explanation) {
+	mFallback := metrics.GetOrRegisterGauge(`Inventory.CalculateConfidence.Fallback`, nil)
 
-var confidenceCalc = confidenceFunc(zeroConfidence)
+	requests := make([]confidence.Request, 0, len(explanations))
+	for i, explanation := range explanations {
+		if explanation.BlockedByQualifiedState {
+			continue
+		}
+		requests = append(requests, confidence.Request{
+			Epc:                      tags[i].Epc,
+			FacilityID:               tags[i].FacilityID,
+			ProductID:                tags[i].ProductID,
+			DailyInventoryPercentage: explanation.DailyInventoryPercentage.Value,
+			ProbUnreadToRead:         explanation.ProbUnreadToRead.Value,
+			ProbInStoreRead:          explanation.ProbInStoreRead.Value,
+			ProbExitError:            explanation.ProbExitError.Value,
+			LastRead:                 tags[i].LastRead,
+		})
+	}
+
+	provider := confidenceProvider
+	model := provider.Model()
+	confidences, err := provider.Calculate(requests)
+	if err != nil {
+		mFallback.Update(1)
+		log.WithFields(log.Fields{
+			"Method": "calculateConfidence",
+			"Action": "Calculate confidence with the " + model + " model",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Warn("Using the built-in confidence model")
+		confidences, _ = confidence.Builtin{}.Calculate(requests)
+		model = confidence.ModelBuiltin
+	}
+
+	next := 0
+	for i := range explanations {
+		explanations[i].Model = model
+		if explanations[i].BlockedByQualifiedState {
+			explanations[i].Confidence = 0
+			continue
+		}
+		explanations[i].Confidence = confidences[next]
+		next++
+	}
+}
 
-func zeroConfidence(_, _, _, _ float64, _ int64) float64 {
-	return 0.0
+// compare returns the estimates with the read probabilities currently used for the tags of their facility and product
+func (inputs confidenceInputs) compare(estimates []estimation.Estimate) []estimation.Comparison {
+	comparisons := make([]estimation.Comparison, len(estimates))
+	for i, estimate := range estimates {
+		explanation := inputs.describe(tag.Tag{FacilityID: estimate.FacilityID, ProductID: estimate.ProductID}, nil, 0)
+		comparisons[i] = estimation.Comparison{
+			Estimate: estimate,
+			Configured: estimation.Configured{
+				ProbInStoreRead:     explanation.ProbInStoreRead,
+				ProbUnreadToRead:    explanation.ProbUnreadToRead,
+				CoefficientsVersion: explanation.CoefficientsVersion,
+			},
+		}
+	}
+	return comparisons
 }
 
-func loadConfidencePlugin() error {
-	confidencePlugin, err := plugin.Open("/plugin/inventory-probabilistic-algo")
-	if err != nil {
-		return errors.New("Intel Probabilistic Algorithm plugin not found; all Confidence values will be set to 0.")
+// confidenceProvider calculates the confidence of the tags, as selected by SelectConfidenceModel
+var confidenceProvider confidence.Provider = confidence.None{}
+
+// SelectConfidenceModel sets the model confidence is calculated with, one of the config.ConfidenceModel values.
+// The error of the plugin model tells why the plugin cannot be used, confidence is then 0.
+func SelectConfidenceModel(model string) error {
+	switch model {
+	case config.ConfidenceModelBuiltin:
+		confidenceProvider = confidence.Builtin{}
+	case config.ConfidenceModelPlugin:
+		if err := loadConfidencePlugin(); err != nil {
+			confidenceProvider = confidence.None{}
+			return err
+		}
+	case config.ConfidenceModelAuto:
+		if err := loadConfidencePlugin(); err != nil {
+			log.Infof("%s Using the built-in confidence model.", err.Error())
+			confidenceProvider = confidence.Builtin{}
+		}
+	case config.ConfidenceModelRemote:
+		timeout := time.Duration(config.AppConfig.EndpointConnectionTimedOutSeconds) * time.Second
+		confidenceProvider = confidence.NewRemote(config.AppConfig.ConfidenceProviderUrl, timeout)
+	default:
+		return errors.Errorf("unknown confidence model %s", model)
 	}
-	calculateConfidence, err := confidencePlugin.Lookup("CalculateConfidence")
+	return nil
+}
+
+func loadConfidencePlugin() error {
+	provider, err := confidence.LoadPlugin(confidence.PluginPath)
 	if err != nil {
-		return errors.New("Unable to find CalculateConfidence function in plugin")
+		return err
 	}
-	// panics if this plugin & function exists but signature doesn't match
-	confidenceCalc = calculateConfidence.(func(float64, float64, float64, float64, int64) float64)
+	confidenceProvider = provider
 	return nil
 }
 
+// init uses the plugin until the configured model is selected
 func init() {
 	if err := loadConfidencePlugin(); err != nil {
-		log.Error(err)
+		log.Debug(err)
 	}
 }
 
@@ -185,7 +333,7 @@ func processGetRequest(ctx context.Context, schema string, masterDB *sql.DB, req
 
 	odataMap := make(map[string][]string)
 	odataMap = mapRequestToOdata(odataMap, &mapping)
-	tags, count, err := tag.Retrieve(masterDB, odataMap, 250) // Per RRS documentation, size limit of 250
+	tags, count, paging, err := tag.Retrieve(masterDB, odataMap, 250) // Per RRS documentation, size limit of 250
 	if err != nil {
 		mRetrieveErr.Update(1)
 		return errors.Wrap(err, "Error retrieving Tag")
@@ -218,10 +366,7 @@ func processGetRequest(ctx context.Context, schema string, masterDB *sql.DB, req
 		results.Count = count.Count
 	}
 
-	// For the upcoming release cursor is not a priority
-	/*if cursor != nil && cursor.Cursor != "" {
-		results.PagingType = cursor
-	}*/
+	results.PagingType = paging
 
 	web.Respond(ctx, writer, results, http.StatusOK)
 	mSuccess.Update(1)
@@ -254,7 +399,13 @@ func processPostRequest(ctx context.Context, schema string, masterDB *sql.DB, re
 			web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
 			return nil
 		}
+		if mapping.AsOf != 0 {
+			return respondInventoryAsOf(ctx, masterDB, writer, &mapping)
+		}
 		odataMap = mapRequestToOdata(odataMap, &mapping)
+		if mapping.Cursor != "" || mapping.Size > 0 {
+			return postCurrentInventoryPage(ctx, masterDB, writer, odataMap, url)
+		}
 	}
 	tags, err = tag.RetrieveOdataAll(masterDB, odataMap)
 	if err != nil {
@@ -276,6 +427,68 @@ func processPostRequest(ctx context.Context, schema string, masterDB *sql.DB, re
 	return nil
 }
 
+// postCurrentInventoryPage posts one page of the current inventory to the cloud connector and responds
+// with the posted tags and the cursor of the next page, so that large stores can be posted incrementally
+func postCurrentInventoryPage(ctx context.Context, masterDB *sql.DB, writer http.ResponseWriter, odataMap map[string][]string, url string) error {
+
+	// Metrics
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.PostCurrentInventory.Page-Success", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.PostCurrentInventory.Page-Retrieve-Error", nil)
+
+	tags, _, paging, err := tag.Retrieve(masterDB, odataMap, config.AppConfig.ResponseLimit)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return errors.Wrap(err, "Error retrieving Tag")
+	}
+
+	tagSlice, err := unmarshalTagsInterface(tags)
+	if err != nil {
+		return err
+	}
+
+	if len(tagSlice) > 0 {
+		if err := ApplyConfidence(masterDB, tagSlice, url); err != nil {
+			return err
+		}
+		if err := postToCloudInBatches(tagSlice); err != nil {
+			return err
+		}
+	} else {
+		tagSlice = []tag.Tag{} // Set empty array
+	}
+
+	web.Respond(ctx, writer, tag.Response{Results: tagSlice, PagingType: paging}, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
+// respondInventoryAsOf responds with the EPCs that were present at the request's as_of time, counted by
+// facility and product. The inventory is rebuilt from the tag event journal rather than the current tag state,
+// so only facility_id may be combined with as_of.
+func respondInventoryAsOf(ctx context.Context, masterDB *sql.DB, writer http.ResponseWriter, request *tag.RequestBody) error {
+
+	// Metrics
+	mSuccess := metrics.GetOrRegisterGauge("Inventory.PostCurrentInventory.AsOf-Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory.PostCurrentInventory.AsOf-Validation-Error", nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge("Inventory.PostCurrentInventory.AsOf-Retrieve-Error", nil)
+
+	if request.QualifiedState != "" || request.EpcState != "" || request.StartTime != 0 || request.EndTime != 0 ||
+		len(request.EpcContext) > 0 {
+		mValidationErr.Update(1)
+		return errors.Wrap(web.ErrValidation, "as_of can only be combined with facility_id")
+	}
+
+	snapshot, err := tagevent.RetrieveAsOf(masterDB, request.AsOf, request.FacilityID)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return err
+	}
+
+	web.Respond(ctx, writer, snapshot, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
 func postToCloudInBatches(tags []tag.Tag) error {
 	if config.AppConfig.CloudConnectorUrl != "" {
 		triggerCloudConnectorEndpoint := config.AppConfig.CloudConnectorUrl + config.AppConfig.CloudConnectorApiGatewayEndpoint
@@ -364,12 +577,111 @@ func processUpdateRequest(ctx context.Context, masterDB *sql.DB, writer http.Res
 	return nil
 }
 
+// ifMatchVersion returns the tag version of the If-Match header, if any. The version may be quoted like an
+// entity tag, and "*" matches any version.
+func ifMatchVersion(request *http.Request) (*int64, error) {
+	ifMatch := strings.TrimSpace(request.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(web.ErrValidation, "If-Match must be the version of the tag, not %s", ifMatch)
+	}
+	return &version, nil
+}
+
+// validateEpcContext returns the epc context to store from its value in a request. Structured contexts must be
+// valid against the registered schema, if any, otherwise the error is caused by web.ErrValidation.
+func validateEpcContext(masterDB *sql.DB, value json.RawMessage) (string, error) {
+	epcContext, structured, err := epccontext.ParseContext(value)
+	if err != nil {
+		return "", errors.Wrap(web.ErrValidation, err.Error())
+	}
+	if !structured {
+		return epcContext, nil
+	}
+
+	contextSchema, err := epccontext.FindSchema(masterDB)
+	if err != nil {
+		return "", err
+	}
+	if contextSchema == nil {
+		return epcContext, nil
+	}
+	contextValidation, err := schemas.ValidateSchemaRequest([]byte(epcContext), string(contextSchema))
+	if err != nil {
+		return "", err
+	}
+	if !contextValidation.Valid() {
+		violations := make([]string, len(contextValidation.Errors()))
+		for i, violation := range contextValidation.Errors() {
+			violations[i] = violation.String()
+		}
+		return "", errors.Wrapf(web.ErrValidation, "epc context does not match the registered schema: %s",
+			strings.Join(violations, "; "))
+	}
+	return epcContext, nil
+}
+
+// processBulkUpdateRequest handles the requests updating the tags selected by a list of EPCs or a filter
+// nolint :lll
+func processBulkUpdateRequest(ctx context.Context, name string, schema string, masterDB *sql.DB, request *http.Request,
+	writer http.ResponseWriter, update func(*sql.DB, tag.BulkUpdateBody) (tag.BulkUpdateResult, error)) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge("Inventory."+name+".Attempt", nil).Update(1)
+
+	startTime := time.Now()
+	defer func() {
+		metrics.GetOrRegisterTimer("Inventory."+name+".Latency", nil).Update(time.Since(startTime))
+	}()
+
+	mSuccess := metrics.GetOrRegisterGauge("Inventory."+name+".Success", nil)
+	mValidationErr := metrics.GetOrRegisterGauge("Inventory."+name+".Validation-Error", nil)
+	mUpdateErr := metrics.GetOrRegisterGauge("Inventory."+name+".Update-Error", nil)
+
+	var mapping tag.BulkUpdateBody
+
+	validationErrors, err := readAndValidateRequest(request, schema, &mapping)
+	if err != nil {
+		mValidationErr.Update(1)
+		return err
+	}
+	if validationErrors != nil {
+		mValidationErr.Update(1)
+		web.Respond(ctx, writer, validationErrors, http.StatusBadRequest)
+		return nil
+	}
+
+	if mapping.ChangedBy == "" {
+		mapping.ChangedBy = request.RemoteAddr
+	}
+
+	result, err := update(masterDB, mapping)
+	if err != nil {
+		if errors.Cause(err) == web.ErrValidation {
+			mValidationErr.Update(1)
+		} else {
+			mUpdateErr.Update(1)
+		}
+		return err
+	}
+
+	web.Respond(ctx, writer, result, http.StatusOK)
+	mSuccess.Update(1)
+	return nil
+}
+
 // nolint :gocyclo
 func mapRequestToOdata(odataMap map[string][]string, request *tag.RequestBody) map[string][]string {
 
 	var filterSlice []string
+	if request.IncludeArchived {
+		odataMap[tag.ArchivedParam] = append(odataMap[tag.ArchivedParam], "true")
+	}
 	if request.Cursor != "" {
-		filterSlice = append(filterSlice, "_id gt '"+request.Cursor+"'")
+		odataMap[tag.CursorParam] = append(odataMap[tag.CursorParam], request.Cursor)
 	}
 	if request.Size > 0 {
 		odataMap["$top"] = append(odataMap["$top"], strconv.Itoa(request.Size))
@@ -388,7 +700,7 @@ func mapRequestToOdata(odataMap map[string][]string, request *tag.RequestBody) m
 		filterSlice = append(filterSlice, "confidence ge "+strconv.FormatFloat(request.Confidence, 'f', -1, 64))
 	}
 	if request.ProductID != "" {
-		filterSlice = append(filterSlice, "productId eq '"+request.ProductID+"'")
+		filterSlice = append(filterSlice, "product_id eq '"+request.ProductID+"'")
 	}
 	if request.StartTime != 0 {
 		filterSlice = append(filterSlice, "last_read ge "+strconv.FormatInt(request.StartTime, 10))
@@ -414,6 +726,15 @@ func mapRequestToOdata(odataMap map[string][]string, request *tag.RequestBody) m
 		}
 	}
 
+	paths := make([]string, 0, len(request.EpcContext))
+	for path := range request.EpcContext {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		filterSlice = append(filterSlice, tag.ContextPathPrefix+path+" eq '"+request.EpcContext[path]+"'")
+	}
+
 	odataMap["$filter"] = append(odataMap["$filter"], strings.Join(filterSlice, " and "))
 
 	if request.CountOnly {
@@ -10,9 +10,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/confidence"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/dailyturn"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/estimation"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/qualifiedstate"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/productdata"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
@@ -28,6 +31,213 @@ const (
 	historyTable = "dailyturnhistory"
 )
 
+// confidenceCalc calculates the confidence of one tag with the selected provider
+func confidenceCalc(dailyInvPerc float64, probUnreadToRead float64, probInStore float64, probExitError float64, lastRead int64) float64 {
+	confidences, err := confidenceProvider.Calculate([]confidence.Request{{
+		DailyInventoryPercentage: dailyInvPerc,
+		ProbUnreadToRead:         probUnreadToRead,
+		ProbInStoreRead:          probInStore,
+		ProbExitError:            probExitError,
+		LastRead:                 lastRead,
+	}})
+	if err != nil {
+		return confidence.Calculate(dailyInvPerc, probUnreadToRead, probInStore, probExitError, lastRead)
+	}
+	return confidences[0]
+}
+
+func TestSelectConfidenceModel(t *testing.T) {
+	previous := confidenceProvider
+	defer func() { confidenceProvider = previous }()
+
+	lastRead := helper.UnixMilliNow() - 24*60*60*1000
+	if err := SelectConfidenceModel(config.ConfidenceModelBuiltin); err != nil {
+		t.Fatalf("Unable to select the built-in confidence model: %s", err)
+	}
+	if conf := confidenceCalc(0.01, 0.2, 0.75, 0.1, lastRead); conf <= 0 || conf >= 1 {
+		t.Errorf("Expected built-in confidence between 0 and 1, got %f", conf)
+	}
+
+	err := SelectConfidenceModel(config.ConfidenceModelAuto)
+	if err != nil {
+		t.Fatalf("Unable to select the automatic confidence model: %s", err)
+	}
+	if conf := confidenceCalc(0.01, 0.2, 0.75, 0.1, lastRead); conf == 0 {
+		t.Errorf("Expected a confidence with the automatic model, got 0")
+	}
+
+	pluginErr := loadConfidencePlugin()
+	err = SelectConfidenceModel(config.ConfidenceModelPlugin)
+	if (pluginErr == nil) != (err == nil) {
+		t.Errorf("Expected the plugin model to be selectable only if the plugin is found, got %v", err)
+	}
+
+	defaultConfig := config.AppConfig
+	defer func() { config.AppConfig = defaultConfig }()
+	config.AppConfig.ConfidenceProviderUrl = "http://confidence:8080/confidence"
+	if err := SelectConfidenceModel(config.ConfidenceModelRemote); err != nil || confidenceProvider.Model() != confidence.ModelRemote {
+		t.Errorf("Expected the remote confidence model to be selected, got %s, %v", confidenceProvider.Model(), err)
+	}
+
+	if err := SelectConfidenceModel("magic"); err == nil {
+		t.Error("Expected an error selecting an unknown confidence model")
+	}
+}
+
+func TestExplainConfidence(t *testing.T) {
+	previous := confidenceProvider
+	defer func() { confidenceProvider = previous }()
+	if err := SelectConfidenceModel(config.ConfidenceModelBuiltin); err != nil {
+		t.Fatalf("Unable to select the built-in confidence model: %s", err)
+	}
+
+	inputs := confidenceInputs{
+		facilities: map[string]facility.Facility{
+			"store1": {
+				Name:                "store1",
+				Coefficients:        facility.Coefficients{DailyInventoryPercentage: 0.02, ProbUnreadToRead: 0.3, ProbInStoreRead: 0.7, ProbExitError: 0.2},
+				CoefficientsVersion: 4,
+			},
+		},
+		productDataMap: map[string]productdata.ProductMetadata{
+			"00111111": {ProductID: "00111111", BeingRead: 0.6},
+		},
+		definitions: qualifiedstate.Definitions{
+			"store1": {FacilityID: "store1", States: []qualifiedstate.State{{Name: "damaged", BlocksConfidence: true}}},
+		},
+	}
+	computedDailyTurnMap := map[string]dailyturn.History{"00111111": {DailyTurn: 0.05}}
+	now := helper.UnixMilliNow()
+	lastRead := now - 24*60*60*1000
+
+	explanation := inputs.explain(tag.Tag{Epc: "30143639F84191AD22900204", FacilityID: "store1", ProductID: "00111111", LastRead: lastRead},
+		computedDailyTurnMap, now)
+	expected := confidence.Explanation{
+		Epc:                      "30143639F84191AD22900204",
+		FacilityID:               "store1",
+		Model:                    confidence.ModelBuiltin,
+		DailyInventoryPercentage: confidence.Input{Value: 0.02, Source: confidence.SourceFacility},
+		ProbUnreadToRead:         confidence.Input{Value: 0.3, Source: confidence.SourceFacility},
+		ProbInStoreRead:          confidence.Input{Value: 0.6, Source: confidence.SourceProduct},
+		ProbExitError:            confidence.Input{Value: 0.2, Source: confidence.SourceFacility},
+		CoefficientsVersion:      4,
+		LastRead:                 lastRead,
+		ElapsedMillis:            24 * 60 * 60 * 1000,
+	}
+	if config.AppConfig.UseComputedDailyTurnInConfidence {
+		expected.DailyInventoryPercentage = confidence.Input{Value: 0.05, Source: confidence.SourceComputedDailyTurn}
+	}
+	expected.Confidence = confidenceCalc(expected.DailyInventoryPercentage.Value, expected.ProbUnreadToRead.Value,
+		expected.ProbInStoreRead.Value, expected.ProbExitError.Value, lastRead)
+	if explanation != expected {
+		t.Errorf("Expected explanation %+v, got %+v", expected, explanation)
+	}
+
+	explanation = inputs.explain(tag.Tag{Epc: "30143639F84191AD22900205", FacilityID: "unknown", LastRead: lastRead},
+		computedDailyTurnMap, now)
+	if explanation.DailyInventoryPercentage.Source != confidence.SourceDefault ||
+		explanation.ProbExitError != (confidence.Input{Value: config.AppConfig.ProbExitError, Source: confidence.SourceDefault}) {
+		t.Errorf("Expected the configured coefficients for an unknown facility, got %+v", explanation)
+	}
+	if explanation.CoefficientsVersion != 0 {
+		t.Errorf("Expected no coefficients version without facility coefficients, got %d", explanation.CoefficientsVersion)
+	}
+
+	explanation = inputs.explain(tag.Tag{Epc: "30143639F84191AD22900206", FacilityID: "store1", QualifiedState: "damaged", LastRead: lastRead},
+		computedDailyTurnMap, now)
+	if !explanation.BlockedByQualifiedState || explanation.Confidence != 0 {
+		t.Errorf("Expected the qualified state to block the confidence, got %+v", explanation)
+	}
+}
+
+func TestCalculateConfidenceRemote(t *testing.T) {
+	previous := confidenceProvider
+	defer func() { confidenceProvider = previous }()
+
+	posts := 0
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		posts++
+		var body confidence.RemoteRequest
+		if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+			t.Errorf("Unable to decode the confidence request: %s", err)
+		}
+		if failing {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		response := confidence.RemoteResponse{Confidences: make([]float64, len(body.Tags))}
+		for i := range body.Tags {
+			response.Confidences[i] = 0.5
+		}
+		if err := json.NewEncoder(writer).Encode(response); err != nil {
+			t.Errorf("Unable to encode the confidence response: %s", err)
+		}
+	}))
+	defer server.Close()
+	confidenceProvider = confidence.NewRemote(server.URL, time.Second)
+
+	lastRead := helper.UnixMilliNow() - 24*60*60*1000
+	tags := []tag.Tag{
+		{Epc: "30143639F84191AD22900201", LastRead: lastRead},
+		{Epc: "30143639F84191AD22900202", LastRead: lastRead},
+		{Epc: "30143639F84191AD22900203", LastRead: lastRead},
+	}
+	explanations := make([]confidence.Explanation, len(tags))
+	explanations[1].BlockedByQualifiedState = true
+	calculateConfidence(tags, explanations)
+
+	if posts != 1 {
+		t.Errorf("Expected the tags to be sent in one request, got %d", posts)
+	}
+	if explanations[0].Confidence != 0.5 || explanations[1].Confidence != 0 || explanations[2].Confidence != 0.5 ||
+		explanations[0].Model != confidence.ModelRemote {
+		t.Errorf("Expected the remote confidence except for the blocked tag, got %+v", explanations)
+	}
+
+	failing = true
+	explanations = make([]confidence.Explanation, len(tags))
+	calculateConfidence(tags, explanations)
+	if explanations[0].Model != confidence.ModelBuiltin || explanations[0].Confidence != confidence.Calculate(0, 0, 0, 0, lastRead) {
+		t.Errorf("Expected the built-in model when the provider fails, got %+v", explanations[0])
+	}
+}
+
+func TestCompareEstimates(t *testing.T) {
+	inputs := confidenceInputs{
+		facilities: map[string]facility.Facility{
+			"store1": {
+				Name:                "store1",
+				Coefficients:        facility.Coefficients{DailyInventoryPercentage: 0.02, ProbUnreadToRead: 0.3, ProbInStoreRead: 0.7, ProbExitError: 0.2},
+				CoefficientsVersion: 4,
+			},
+		},
+		productDataMap: map[string]productdata.ProductMetadata{
+			"00111111": {ProductID: "00111111", BeingRead: 0.6},
+		},
+	}
+
+	comparisons := inputs.compare([]estimation.Estimate{
+		{FacilityID: "store1", ProbInStoreRead: 0.5, ProbUnreadToRead: 0.1},
+		{FacilityID: "store1", ProductID: "00111111", ProbInStoreRead: 0.4, ProbUnreadToRead: 0.1},
+	})
+	if len(comparisons) != 2 || comparisons[0].ProbInStoreRead != 0.5 {
+		t.Fatalf("Expected a comparison for each estimate, got %+v", comparisons)
+	}
+	expected := estimation.Configured{
+		ProbInStoreRead:     confidence.Input{Value: 0.7, Source: confidence.SourceFacility},
+		ProbUnreadToRead:    confidence.Input{Value: 0.3, Source: confidence.SourceFacility},
+		CoefficientsVersion: 4,
+	}
+	if comparisons[0].Configured != expected {
+		t.Errorf("Expected the coefficients of the facility, got %+v", comparisons[0].Configured)
+	}
+	expected.ProbInStoreRead = confidence.Input{Value: 0.6, Source: confidence.SourceProduct}
+	if comparisons[1].Configured != expected {
+		t.Errorf("Expected the product override, got %+v", comparisons[1].Configured)
+	}
+}
+
 func TestApplyConfidenceFacilitiesDontExist(t *testing.T) {
 	result := buildProductData(0.0, 0.0, 0.0, 0.0, "00111111")
 	testServer := buildTestServer(t, result)
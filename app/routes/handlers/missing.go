@@ -0,0 +1,89 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-go-odata/parser"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/confidence"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/missing"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/statemodel"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// missingBatchSize is the number of present tags confidence is computed for at once
+const missingBatchSize = 500
+
+// DetectProbablyMissing calculates the confidence of the present tags, and returns the evaluation of the tags
+// which fell below the probably missing threshold of their facility, and of the probably missing tags which
+// were read again. Tags whose qualified state blocks their confidence are not evaluated, so they are not
+// reported and their flags are cleared. The evaluation is to be saved with missing.Save once its events are
+// sent: until then, the same tags are reported again.
+func DetectProbablyMissing(masterDB *sql.DB, url string) (*missing.Evaluation, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.DetectProbablyMissing.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.DetectProbablyMissing.Success`, nil)
+	mDetectErr := metrics.GetOrRegisterGauge(`Inventory.DetectProbablyMissing.Detect-Error`, nil)
+	mMissing := metrics.GetOrRegisterGauge(`Inventory.DetectProbablyMissing.Missing`, nil)
+	mRecovered := metrics.GetOrRegisterGauge(`Inventory.DetectProbablyMissing.Recovered`, nil)
+
+	// without a confidence model, every tag would be missing
+	if confidenceProvider.Model() == confidence.ModelNone {
+		log.Debug("No confidence model, probably missing tags are not detected")
+		return missing.NewEvaluation(nil, nil, helper.UnixMilliNow()), nil
+	}
+
+	inputs, err := loadConfidenceInputs(masterDB, url)
+	if err != nil {
+		mDetectErr.Update(1)
+		return nil, err
+	}
+
+	flags, err := missing.RetrieveFlags(masterDB)
+	if err != nil {
+		mDetectErr.Update(1)
+		return nil, err
+	}
+
+	evaluation := missing.NewEvaluation(inputs.facilities, flags, helper.UnixMilliNow())
+	batch := make([]tag.Tag, 0, missingBatchSize)
+	evaluateBatch := func() {
+		inputs.apply(masterDB, batch)
+		evaluation.Add(batch)
+		batch = batch[:0]
+	}
+
+	query := map[string][]string{parser.Filter: {fmt.Sprintf("epc_state eq '%s'", statemodel.PresentEpcState)}}
+	err = tag.StreamOdata(masterDB, query, func(present tag.Tag) error {
+		// a confidence of 0 is the way the qualified state marks the tag out of stock, not a missing tag
+		if inputs.definitions.BlocksConfidence(present.FacilityID, present.QualifiedState) {
+			return nil
+		}
+		batch = append(batch, present)
+		if len(batch) == missingBatchSize {
+			evaluateBatch()
+		}
+		return nil
+	})
+	if err != nil {
+		mDetectErr.Update(1)
+		return nil, errors.Wrap(err, "unable to stream present tags")
+	}
+	evaluateBatch()
+
+	mMissing.Update(int64(len(evaluation.Missing)))
+	mRecovered.Update(int64(len(evaluation.Recovered)))
+	mSuccess.Update(1)
+	return evaluation, nil
+}
@@ -0,0 +1,81 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package handlers
+
+import (
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/missing"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/qualifiedstate"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/statemodel"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/productdata"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+)
+
+func TestDetectProbablyMissingSkipsBlockedTags(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	previous, threshold := confidenceProvider, config.AppConfig.ProbablyMissingThreshold
+	defer func() { confidenceProvider, config.AppConfig.ProbablyMissingThreshold = previous, threshold }()
+	if err := SelectConfidenceModel(config.ConfidenceModelBuiltin); err != nil {
+		t.Fatalf("Unable to select the built-in confidence model: %s", err)
+	}
+	config.AppConfig.ProbablyMissingThreshold = 0.99
+
+	server := buildTestServer(t, productdata.Result{})
+	defer server.Close()
+
+	definition := qualifiedstate.Definition{FacilityID: "store1", States: []qualifiedstate.State{{Name: "sold", BlocksConfidence: true}}}
+	if err := qualifiedstate.UpsertDefinition(testDB.DB, definition); err != nil {
+		t.Fatalf("Unable to upsert the qualified state definition: %+v", err)
+	}
+
+	lastRead := helper.UnixMilliNow() - 30*24*60*60*1000
+	tags := []tag.Tag{
+		{Epc: "30143639F84191AD22900201", FacilityID: "store1", EpcState: statemodel.PresentEpcState, LastRead: lastRead},
+		{Epc: "30143639F84191AD22900202", FacilityID: "store1", EpcState: statemodel.PresentEpcState, LastRead: lastRead,
+			QualifiedState: "sold"},
+	}
+	if err := tag.Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+	// the tag was flagged before it was sold
+	if _, err := testDB.DB.Exec(`INSERT INTO probably_missing (data) VALUES ('{"epc": "30143639F84191AD22900202", "facility_id": "store1", "last_read": 1}');`); err != nil {
+		t.Fatalf("Unable to flag tag: %+v", err)
+	}
+
+	evaluation, err := DetectProbablyMissing(testDB.DB, server.URL+"/skus")
+	if err != nil {
+		t.Fatalf("Unable to detect probably missing tags: %+v", err)
+	}
+	if len(evaluation.Missing) != 1 || evaluation.Missing[0].Epc != tags[0].Epc || len(evaluation.Recovered) != 0 {
+		t.Errorf("expected only the unsold tag to be probably missing, got %+v", evaluation.Events())
+	}
+
+	// nothing is flagged until the events are sent and the evaluation saved
+	flags, err := missing.RetrieveFlags(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to retrieve flags: %+v", err)
+	}
+	if _, flagged := flags[tags[0].Epc]; flagged || len(flags) != 1 {
+		t.Errorf("expected the evaluation not to be saved yet, got %+v", flags)
+	}
+
+	if err := missing.Save(testDB.DB, evaluation); err != nil {
+		t.Fatalf("Unable to save the evaluation: %+v", err)
+	}
+	flags, err = missing.RetrieveFlags(testDB.DB)
+	if err != nil {
+		t.Fatalf("Unable to retrieve flags: %+v", err)
+	}
+	if _, flagged := flags[tags[0].Epc]; !flagged || len(flags) != 1 {
+		t.Errorf("expected the flag of the sold tag to be cleared, got %+v", flags)
+	}
+}
@@ -0,0 +1,32 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package handlers
+
+import (
+	"database/sql"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/pkg/errors"
+)
+
+// PurgeCutoffs returns the cutoffs keeping the given number of business days before the current one in each
+// facility with a time zone or business hours, and the given number of 24 hour periods in the other facilities
+func PurgeCutoffs(masterDB *sql.DB, now int64, days int) (tag.Cutoffs, error) {
+	cutoffs := tag.Cutoffs{Default: tag.PurgeCutoff(now, days), Facilities: make(map[string]int64)}
+
+	facilities, err := facility.CreateFacilityMap(masterDB)
+	if err != nil {
+		return cutoffs, errors.Wrap(err, "unable to find the business days of the facilities")
+	}
+	for name, site := range facilities {
+		if site.Timezone != "" || site.BusinessHours != nil {
+			cutoffs.Facilities[name] = site.BusinessDaysAgoStart(now, days)
+		}
+	}
+	return cutoffs, nil
+}
@@ -0,0 +1,48 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package handlers
+
+import (
+	"database/sql"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/sensor"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagprocessor"
+)
+
+// ResetInventory deletes the tags in the scope of the request from the database and makes the tag processor
+// forget them, so that they arrive again when next read. On a dry run, the tags are only counted.
+func ResetInventory(masterDB *sql.DB, request tag.ResetRequest) (tag.ResetResult, error) {
+	result := tag.ResetResult{DryRun: request.DryRun}
+
+	scope := tag.ResetScope{FacilityID: request.FacilityID, Sensors: request.Sensors}
+	for _, deviceID := range request.Sensors {
+		rsp, err := sensor.FindRSP(masterDB, deviceID)
+		if err != nil {
+			return result, err
+		}
+		if rsp != nil {
+			scope.Aliases = append(scope.Aliases, rsp.Aliases...)
+		}
+	}
+
+	// the reset tags are journaled as removed, so that they are no longer in the inventory as of a later time
+	var journal tag.Journal
+	var err error
+	if !request.DryRun {
+		if journal, err = tagevent.RemovalJournal(masterDB, tagevent.ResetSource); err != nil {
+			return result, err
+		}
+	}
+
+	if result.Deleted, err = tag.Reset(masterDB, scope, request.DryRun, journal); err != nil {
+		return result, err
+	}
+	result.Forgotten = tagprocessor.ResetInventory(request.FacilityID, request.Sensors, request.DryRun)
+	return result, nil
+}
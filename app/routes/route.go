@@ -69,6 +69,16 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		// /inventory/tags?$count - Shows how many records are in the database
 		// /inventory/tags?$filter=(epc eq 'example') and (tid ne '1000030404') - Filters on a particular epc whose tid does not match the one specified
 		// /inventory/tags?$filter=startswith(epc,'100') or endswith(epc,'003') or contains(epc,'2') - Allows you to filter based on only certain portions of an epc
+		// /inventory/tags?$filter=(epc_context.asnId eq '123') - Filters on a field of the structured epc context, nested fields are separated by dots
+		// /inventory/tags?$filter=last_read ge 1501863300000 - arrived, last_read, filter_value and version are compared as numbers
+		//
+		// + Paging: unless $orderby is set to another field, tags are ordered by epc. When a page is full, the response
+		// contains `paging.cursor`; pass it back as the `cursor` query parameter to retrieve the next page, e.g.
+		// /inventory/tags?$top=1000&cursor=MzAxNDM2MzlGODQxOTFBRDIyOTAwMjA0. A cursor cannot be combined with $orderby.
+		//
+		// + Archive: departed tags are moved to an archive after archiveDepartedDays, where they are kept unless
+		// archiveRetentionDays is set, which must then be greater. Add include_archived=true to also return them,
+		// e.g. /inventory/tags?include_archived=true&$filter=(epc eq 'example')
 		//
 		// Example of one object being returned:<br><br>
 		// ```
@@ -210,6 +220,131 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 			"/inventory/facilities",
 			inventory.GetFacilities,
 		},
+		//swagger:route POST /inventory/facilities facilities createFacility
+		//
+		// Create Facility
+		//
+		// This API call is used to create a facility with its metadata. Without coefficients, the facility gets
+		// the default coefficients set as configuration variables. Facilities seen in sensor configuration
+		// notifications and inventory data are created automatically with the default coefficients.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "name": "store555",
+		// "display_name": "Store 555",
+		// "timezone": "America/Los_Angeles",
+		// "address": "2111 NE 25th Ave, Hillsboro, OR 97124",
+		// "type": "store",
+		// "business_hours": {"open": "09:00", "close": "21:00"},
+		// "asn_receiving": true
+		// }
+		// ```
+		//
+		// + name  - Facility code or identifier
+		// + display_name  - Name of the facility to display
+		// + timezone  - IANA time zone of the facility, business days are local days of this time zone
+		// + business_hours  - Local opening and closing times, a store closing after midnight starts its business day at closing
+		// + address  - Postal address of the facility
+		// + type  - store or dc (distribution center)
+		// + asn_receiving  - Whether the facility receives advanced shipping notices
+		// + probably_missing_threshold  - Confidence below which present tags are reported probably missing, probablyMissingThreshold if not set
+		// + coefficients  - Optional coefficients of the probabilistic algorithm
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       201: body:resultsResponse
+		//       400: schemaValidation
+		//       409: conflict
+		//       500: internalError
+		//
+		{
+			"CreateFacility",
+			"POST",
+			"/inventory/facilities",
+			inventory.CreateFacility,
+		},
+		//swagger:route PUT /inventory/facilities facilities updateFacility
+		//
+		// Update Facility
+		//
+		// This API call is used to replace the metadata of a facility. Metadata left out of the request is cleared.
+		// The coefficients are kept, they are updated with PUT /inventory/update/coefficients.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "name": "store555",
+		// "display_name": "Store 555",
+		// "timezone": "America/Los_Angeles",
+		// "type": "store",
+		// "asn_receiving": false
+		// }
+		// ```
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       404: notFound
+		//       500: internalError
+		//
+		{
+			"UpdateFacility",
+			"PUT",
+			"/inventory/facilities",
+			inventory.UpdateFacility,
+		},
+		//swagger:route DELETE /inventory/facilities facilities deleteFacility
+		//
+		// Delete Facility
+		//
+		// This API call is used to delete a facility. A facility which still has sensors or tags cannot be deleted,
+		// as their messages would create it again right away. The coefficients history of the facility is kept,
+		// and continues if a facility of the same name is created again.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "name": "store555"
+		// }
+		// ```
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       204: body:resultsResponse
+		//       400: schemaValidation
+		//       404: notFound
+		//       409: conflict
+		//       500: internalError
+		//
+		{
+			"DeleteFacility",
+			"DELETE",
+			"/inventory/facilities",
+			inventory.DeleteFacility,
+		},
 		//swagger:operation GET /inventory/handheldevents handheldevents getHandheldevents
 		//
 		// Retrieves Handheld Event Data
@@ -279,6 +414,229 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 			"/inventory/handheldevents",
 			inventory.GetHandheldEvents,
 		},
+		//swagger:operation GET /inventory/events events getTagEvents
+		//
+		// Retrieves Tag Event Journal
+		//
+		// This API call is used to retrieve the append-only journal of tag events and the state changes they caused.
+		// Events are returned in chronological order and can be filtered by any combination of the query parameters.<br><br>
+		//
+		// + `/inventory/events?epc=30143639F84191AD22900104`
+		// + `/inventory/events?facility_id=Tavern&event_type=departed&starttime=1506967944919&endtime=1506968212265`
+		// + `/inventory/events?product_id=00888446671424&size=100`
+		//
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/json
+		//
+		// parameters:
+		// - name: epc
+		//   in: query
+		//   description: Only return events for this EPC
+		//   required: false
+		//   type: string
+		// - name: product_id
+		//   in: query
+		//   description: Only return events for this product
+		//   required: false
+		//   type: string
+		// - name: facility_id
+		//   in: query
+		//   description: Only return events for this facility
+		//   required: false
+		//   type: string
+		// - name: event_type
+		//   in: query
+		//   description: Only return events of this type (arrival, moved, departed, returned or cycle_count)
+		//   required: false
+		//   type: string
+		// - name: starttime
+		//   in: query
+		//   description: Millisecond epoch of the earliest event to return
+		//   required: false
+		//   type: integer
+		// - name: endtime
+		//   in: query
+		//   description: Millisecond epoch of the latest event to return
+		//   required: false
+		//   type: integer
+		// - name: size
+		//   in: query
+		//   description: Maximum number of events to return, bounded by the response limit
+		//   required: false
+		//   type: integer
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK
+		//     schema:
+		//       description: Results Response
+		//       type: object
+		//       properties:
+		//         results:
+		//           type: array
+		//           description: Array containing results of query
+		//           items:
+		//             "$ref": "#/definitions/TagEvent"
+		//   400:
+		//     "$ref": "#/responses/schemaValidation"
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"GetTagEvents",
+			"GET",
+			"/inventory/events",
+			inventory.GetTagEvents,
+		},
+		//swagger:operation GET /inventory/aggregate tags getAggregate
+		//
+		// Aggregates Tag Data
+		//
+		// This API call is used to count tags grouped by any combination of product_id, facility_id, epc_state,
+		// qualified_state and location (the most recent location of the tag). Counting is done by the database,
+		// so dashboards do not need to download raw tags.<br><br>
+		//
+		// + `/inventory/aggregate?group_by=facility_id,product_id`
+		// + `/inventory/aggregate?group_by=location&facility_id=store001&epc_state=present&expected=true`
+		// + `/inventory/aggregate?group_by=product_id&bucket=day`, counting the tags by the business day of their
+		// facility they were last read in, returned as the `day` of each group
+		//
+		// Example Result:
+		// ```
+		// {
+		//   "results": [
+		//     {
+		//       "group": {"location": "RSP-95bd71"},
+		//       "count": 120,
+		//       "expected_count": 112.4
+		//     }
+		//   ]
+		// }
+		// ```
+		//
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/json
+		//
+		// parameters:
+		// - name: group_by
+		//   in: query
+		//   description: Comma separated fields to group by (product_id, facility_id, epc_state, qualified_state, location). Without it, all matching tags are counted in one group.
+		//   required: false
+		//   type: string
+		// - name: product_id
+		//   in: query
+		//   description: Only count tags of this product
+		//   required: false
+		//   type: string
+		// - name: facility_id
+		//   in: query
+		//   description: Only count tags in this facility
+		//   required: false
+		//   type: string
+		// - name: epc_state
+		//   in: query
+		//   description: Only count tags in this state (present or departed)
+		//   required: false
+		//   type: string
+		// - name: qualified_state
+		//   in: query
+		//   description: Only count tags with this qualified state
+		//   required: false
+		//   type: string
+		// - name: location
+		//   in: query
+		//   description: Only count tags most recently read at this location
+		//   required: false
+		//   type: string
+		// - name: expected
+		//   in: query
+		//   description: Also return the expected count of each group, the sum of the confidence of its tags
+		//   required: false
+		//   type: boolean
+		// - name: bucket
+		//   in: query
+		//   description: Set to day to also group the tags by the business day of their facility they were last read in (YYYY-MM-DD)
+		//   required: false
+		//   type: string
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK
+		//   400:
+		//     "$ref": "#/responses/schemaValidation"
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"GetAggregate",
+			"GET",
+			"/inventory/aggregate",
+			inventory.GetAggregate,
+		},
+		//swagger:operation GET /inventory/export tags exportTags
+		//
+		// Exports Tag Data
+		//
+		// This API call is used to stream all the tags matching a filter, without the size limit of the other
+		// endpoints. Tags are exported with their computed confidence and decoded product ID, one per line,
+		// as NDJSON (`Accept: application/x-ndjson`, the default) or CSV (`Accept: text/csv`).<br><br>
+		//
+		// Tags can be filtered either with the OData $filter and $orderby query parameters, or with a request body
+		// containing any of __facility_id__, __qualified_state__, __epc_state__, __starttime__, __endtime__,
+		// __productId__, __epc__ and __confidence__ (minimum confidence), as in /inventory/query/current.
+		// Archived tags are included with the include_archived query parameter or body field.
+		//
+		// + `/inventory/export`
+		// + `/inventory/export?$filter=(facility_id eq 'store001') and (epc_state eq 'present')`
+		// + `/inventory/export?include_archived=true`
+		//
+		// CSV columns: epc, product_id, uri, facility_id, epc_state, qualified_state, event, source, arrived,
+		// last_read, location, confidence, epc_context
+		//
+		// Should the export fail once tags were sent, the response ends with an error record instead of the
+		// remaining tags: a last NDJSON line `{"error": "..."}`, or a last CSV row whose first field is `#error`.
+		//
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/x-ndjson
+		// - text/csv
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK, tags streamed in the requested format
+		//   400:
+		//     "$ref": "#/responses/schemaValidation"
+		//   406:
+		//     description: Not Acceptable, the Accept header does not allow NDJSON or CSV
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"ExportTags",
+			"GET",
+			"/inventory/export",
+			inventory.ExportTags,
+		},
 		//swagger:route POST /inventory/query/current current postCurrentInventory
 		//
 		// Post current inventory snapshot to the cloud connector
@@ -298,6 +656,29 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		// + __epc_state__ - EPC state of 'present' or 'departed'
 		// + __starttime__ - Millisecond epoch start time
 		// + __endtime__ - Millisecond epoch stop time
+		// + __size__ - Post only one page of this many tags, and respond with the posted tags and `paging.cursor`
+		// + __cursor__ - `paging.cursor` of the previous response, to post the next page
+		// + __epc_context__ - Fields of the structured epc context the tags must have, by path, e.g. {"asnId":"123"}
+		// + __as_of__ - Millisecond epoch of a past point in time. Instead of posting to the cloud connector, responds
+		// with the EPCs that were present at that time, counted by facility and product. Only __facility_id__ may be
+		// combined with __as_of__. The inventory is rebuilt from the tag event journal since its latest checkpoint,
+		// which journals the present tags when the journal is introduced and every journalCheckpointIntervalHours.
+		//
+		// Example as_of Response:
+		// ```
+		// {
+		//   "as_of": 1506967944919,
+		//   "count": 2,
+		//   "results": [
+		//     {
+		//       "facility_id": "store001",
+		//       "product_id": "00888446671424",
+		//       "count": 2,
+		//       "epcs": ["30143639F84191AD22900104", "30143639F84191AD22900105"]
+		//     }
+		//   ]
+		// }
+		// ```
 		//
 		//
 		//
@@ -351,6 +732,7 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		// + cursor  - Cursor from previous response used to retrieve next page of results
 		// + size  - Number of results per page
 		// + count_only  - Return only tag count
+		// + include_archived  - Also search the tags archived after departing
 		//
 		//
 		//
@@ -441,7 +823,9 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		// 	"probexiterror": 0.1,
 		// 	"probinstoreread": 0.75,
 		// 	"probunreadtoread": 0.2,
-		// 	"facility_id": "Facility"
+		// 	"facility_id": "Facility",
+		// 	"changed_by": "jdoe",
+		// 	"reason": "seasonal sales"
 		// }
 		// ```
 		//
@@ -451,6 +835,27 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		// +  probinstoreread - Probability of a tag in the store being read by the overhead sensor each day
 		// +  probunreadtoread - Probability of an unreadable tag becoming readable again each day (i.e. moved or retagged)
 		// +  facility_id - Facility name
+		// +  changed_by - Who requested the change, defaults to the address of the client
+		// +  reason - Why the coefficients changed
+		//
+		// Every change is recorded as the next version of the coefficients of the facility, which is returned.
+		//
+		// Example Response:
+		// ```
+		// {
+		// "facility_id": "Facility",
+		// "version": 3,
+		// "coefficients": {
+		// "dailyinventorypercentage": 0.01,
+		// "probexiterror": 0.1,
+		// "probinstoreread": 0.75,
+		// "probunreadtoread": 0.2
+		// },
+		// "changed_by": "jdoe",
+		// "reason": "seasonal sales",
+		// "timestamp": 1572854400000
+		// }
+		// ```
 		//
 		//     Consumes:
 		//     - application/json
@@ -471,25 +876,27 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 			"/inventory/update/coefficients",
 			inventory.UpdateCoefficients,
 		},
-		//swagger:route PUT /inventory/update/qualifiedstate update updateQualifiedState
+		//swagger:route PUT /inventory/update/coefficients/rollback update rollbackCoefficients
 		//
-		// Upload inventory events
+		// Roll Back Facility Coefficients
 		//
-		// The update endpoint is for uploading inventory events such as those from a handheld RFID reader.<br><br>
+		// This API call is used to set the coefficients of a facility back to the ones of a previous version. The
+		// rollback is recorded as the next version, with the version rolled back to in rolled_back_to.<br><br>
 		//
 		// Example Request Input:
 		// ```
 		// {
-		// "qualified_state":"string",
-		// "epc":"string",
-		// "facility_id":"string"
+		// "facility_id": "Facility",
+		// "version": 2,
+		// "changed_by": "jdoe",
+		// "reason": "confidence dropped after version 3"
 		// }
 		// ```
 		//
-		// + qualified_state  - User-defined state
-		// + epc  - SGTIN-96 EPC
-		// + facility_id  - Facility code or identifier
-		//
+		// +  facility_id - Facility name
+		// +  version - Version of the coefficients to roll back to
+		// +  changed_by - Who requested the rollback, defaults to the address of the client
+		// +  reason - Why the coefficients are rolled back
 		//
 		//     Consumes:
 		//     - application/json
@@ -502,38 +909,514 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		//     Responses:
 		//       200: body:resultsResponse
 		//       400: schemaValidation
-		//       403: forbidden
+		//       404: notFound
 		//       500: internalError
-		//       502: externalError
-		//       503: serviceUnavailable
-		//       504: externalServiceTimeout
 		//
 		{
-			"UpdateQualifiedState",
+			"RollbackCoefficients",
 			"PUT",
-			"/inventory/update/qualifiedstate",
-			inventory.UpdateQualifiedState,
+			"/inventory/update/coefficients/rollback",
+			inventory.RollbackCoefficients,
 		},
-		//swagger:route POST /inventory/search epc getSearchByEpc
+		//swagger:operation GET /inventory/coefficients/history facilities getCoefficientsHistory
 		//
-		// Retrieves tag data corresponding to specified EPC pattern
+		// Retrieves Facility Coefficients History
 		//
-		// Returns a list of tags with their EPCs matching a pattern. Body parameters shall be provided in request body in JSON format.<br><br>
+		// This API call is used to retrieve the versions of the coefficients of a facility, most recent first, with
+		// who changed them, when and why. The coefficients_version of a facility is its current version.<br><br>
 		//
-		// Example Request Input:
+		// + `/inventory/coefficients/history?facility_id=Facility`
+		//
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/json
+		//
+		// parameters:
+		// - name: facility_id
+		//   in: query
+		//   description: Facility name
+		//   required: true
+		//   type: string
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK
+		//     schema:
+		//       description: Results Response
+		//       type: object
+		//       properties:
+		//         results:
+		//           type: array
+		//           description: Array containing results of query
+		//           items:
+		//             "$ref": "#/definitions/CoefficientsVersion"
+		//   400:
+		//     "$ref": "#/responses/schemaValidation"
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"GetCoefficientsHistory",
+			"GET",
+			"/inventory/coefficients/history",
+			inventory.GetCoefficientsHistory,
+		},
+		//swagger:operation GET /inventory/coefficients/estimates facilities getCoefficientEstimates
+		//
+		// Retrieves Estimated Facility Coefficients
+		//
+		// This API call is used to retrieve the read probabilities estimated from the reads of the last
+		// coefficientEstimationDays, every coefficientEstimationIntervalHours, next to the ones currently used.
+		// probinstoreread is the fraction of the tags read one day which were read again the next day. probunreadtoread
+		// is estimated from the number of days tags missed once went without read. Estimates by product are made when
+		// coefficientEstimationByProduct is on, and only facilities and products with coefficientEstimationMinimumSamples
+		// gaps between reads are estimated. The configured values have the same sources as in the confidence
+		// explanation: facility, default or product.<br><br>
+		//
+		// + `/inventory/coefficients/estimates?facility_id=Facility`
+		//
+		// Example Response:
 		// ```
 		// {
-		// "epc":"3038E511C6E9A6400012D687",
-		// "facility_id":"store001",
-		// "cursor":"aGksIDovMSB0aGlz",
-		// "size":500
+		//   "results": [
+		//     {
+		//       "facility_id": "Facility",
+		//       "probinstoreread": 0.64,
+		//       "probunreadtoread": 0.31,
+		//       "samples": 18250,
+		//       "since": 1573000000000,
+		//       "timestamp": 1574209600000,
+		//       "configured": {
+		//         "probinstoreread": {"value": 0.75, "source": "facility"},
+		//         "probunreadtoread": {"value": 0.2, "source": "facility"},
+		//         "coefficients_version": 3
+		//       }
+		//     }
+		//   ]
 		// }
 		// ```
 		//
-		// + epc  - EPC search string which can contain a single asterisk at the beginning, middle, or end of EPC string
-		// + facility_id  - Facility code or identifier
-		// + cursor  - Cursor from previous response used to retrieve next page of results
-		// + size  - Number of results per page
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/json
+		//
+		// parameters:
+		// - name: facility_id
+		//   in: query
+		//   description: Facility name, all the facilities if omitted
+		//   required: false
+		//   type: string
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK
+		//     schema:
+		//       description: Results Response
+		//       type: object
+		//       properties:
+		//         results:
+		//           type: array
+		//           description: Array containing results of query
+		//           items:
+		//             "$ref": "#/definitions/Comparison"
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"GetCoefficientEstimates",
+			"GET",
+			"/inventory/coefficients/estimates",
+			inventory.GetCoefficientEstimates,
+		},
+		//swagger:route PUT /inventory/update/coefficients/estimates/apply update applyCoefficientEstimate
+		//
+		// Apply Estimated Facility Coefficients
+		//
+		// This API call is used to replace probinstoreread and probunreadtoread of the coefficients of a facility by
+		// their estimates, keeping the other coefficients. The change is recorded as the next version of the
+		// coefficients, and can be rolled back. Estimates by product are not applied, as product coefficients come from
+		// the SKU mapping. Setting applyEstimatedCoefficients applies the estimates of the facilities automatically.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "facility_id": "Facility",
+		// "changed_by": "jdoe",
+		// "reason": "use the read rates of the last two weeks"
+		// }
+		// ```
+		//
+		// +  facility_id - Facility name
+		// +  changed_by - Who requested the change, defaults to the address of the client
+		// +  reason - Why the coefficients change, defaults to the number of samples of the estimate
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       404: notFound
+		//       500: internalError
+		//
+		{
+			"ApplyCoefficientEstimate",
+			"PUT",
+			"/inventory/update/coefficients/estimates/apply",
+			inventory.ApplyCoefficientEstimate,
+		},
+		//swagger:operation GET /inventory/confidence tags explainConfidence
+		//
+		// Explains the Confidence of a Tag
+		//
+		// This API call is used to find out how the confidence of a tag is calculated. Each input coefficient is
+		// returned with its source: the coefficients of the facility of the tag (facility), the configured
+		// coefficients when the facility is unknown (default), the product overrides of the SKU mapping (product), or
+		// the computed daily turn of the product when useComputedDailyTurnInConfidence is on (computed_daily_turn).
+		// The model is plugin for the probabilistic algorithm plugin, builtin for the built-in model, remote for the
+		// model served at confidenceProviderUrl, or none when confidence is always 0. A failing remote model falls
+		// back to builtin. The coefficients_version is the version of the facility coefficients used.<br><br>
+		//
+		// + `/inventory/confidence?epc=3038E511C6E9A6400012D687`
+		//
+		// Example Response:
+		// ```
+		// {
+		//   "epc": "3038E511C6E9A6400012D687",
+		//   "facility_id": "store001",
+		//   "confidence": 0.93,
+		//   "model": "builtin",
+		//   "daily_inventory_percentage": {"value": 0.02, "source": "computed_daily_turn"},
+		//   "prob_unread_to_read": {"value": 0.2, "source": "facility"},
+		//   "prob_in_store_read": {"value": 0.6, "source": "product"},
+		//   "prob_exit_error": {"value": 0.1, "source": "facility"},
+		//   "coefficients_version": 3,
+		//   "last_read": 1573000000000,
+		//   "elapsed_millis": 86400000
+		// }
+		// ```
+		//
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/json
+		//
+		// parameters:
+		// - name: epc
+		//   in: query
+		//   description: EPC of the tag
+		//   required: true
+		//   type: string
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK
+		//     schema:
+		//       "$ref": "#/definitions/Explanation"
+		//   400:
+		//     "$ref": "#/responses/schemaValidation"
+		//   404:
+		//     "$ref": "#/responses/notFound"
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"ExplainConfidence",
+			"GET",
+			"/inventory/confidence",
+			inventory.ExplainConfidence,
+		},
+		//swagger:route PUT /inventory/update/qualifiedstate update updateQualifiedState
+		//
+		// Upload inventory events
+		//
+		// The update endpoint is for uploading inventory events such as those from a handheld RFID reader.
+		// If the facility has a qualified-state definition, the new state must be allowed from the current one.
+		// Every change is recorded in the qualified state history.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "qualified_state":"string",
+		// "epc":"string",
+		// "facility_id":"string",
+		// "changed_by":"string",
+		// "reason":"string"
+		// }
+		// ```
+		//
+		// + qualified_state  - User-defined state
+		// + epc  - SGTIN-96 EPC
+		// + facility_id  - Facility code or identifier
+		// + changed_by  - Who requested the change, defaults to the address of the client
+		// + reason  - Why the state changed
+		//
+		// To only change the state if the tag was not modified since it was read, send its `version` in the
+		// If-Match header. A 412 Precondition Failed is returned if the tag is at another version.
+		//
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       403: forbidden
+		//       500: internalError
+		//       502: externalError
+		//       503: serviceUnavailable
+		//       504: externalServiceTimeout
+		//
+		{
+			"UpdateQualifiedState",
+			"PUT",
+			"/inventory/update/qualifiedstate",
+			inventory.UpdateQualifiedState,
+		},
+		//swagger:route PUT /inventory/update/qualifiedstate/bulk update bulkUpdateQualifiedState
+		//
+		// Update the qualified state of many tags
+		//
+		// Sets the qualified state of the tags selected either by a list of EPCs, within the facility if given, or by
+		// product ID and/or EPC pattern within the facility, or of all the tags of the facility with all_in_facility,
+		// in one transaction. Responds with the number of tags updated and the listed EPCs that were not found.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "qualified_state":"damaged",
+		// "facility_id":"store001",
+		// "epcs":["30143639F84191AD22900104", "30143639F84191AD22900105"]
+		// }
+		// ```
+		//
+		// Example Response:
+		// ```
+		// {
+		// "updated": 1,
+		// "not_found": ["30143639F84191AD22900105"]
+		// }
+		// ```
+		//
+		// + qualified_state  - User-defined state
+		// + facility_id  - Facility code or identifier, required unless tags are selected by a list of EPCs
+		// + epcs  - SGTIN-96 EPCs of the tags to update
+		// + productId  - Update the tags of this GTIN-14, instead of a list of EPCs
+		// + epc  - Update the tags matching this EPC pattern with at most one '*', instead of a list of EPCs
+		// + all_in_facility  - Set to true to update all the tags of the facility, instead of a list of EPCs
+		// + changed_by  - Who requested the change, defaults to the address of the client
+		// + reason  - Why the state changed
+		//
+		// The update is rejected if the qualified-state definition of the facility of any of the selected tags does
+		// not allow its change.
+		//
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       500: internalError
+		//
+		{
+			"BulkUpdateQualifiedState",
+			"PUT",
+			"/inventory/update/qualifiedstate/bulk",
+			inventory.BulkUpdateQualifiedState,
+		},
+		//swagger:route PUT /inventory/qualifiedstates qualifiedstates upsertQualifiedStateDefinition
+		//
+		// Define the qualified states of a facility
+		//
+		// Creates or replaces the qualified-state workflow of a facility: the allowed states, the states a tag
+		// without qualified state may be given, the allowed transitions, and the states which zero the confidence
+		// of a tag or stop alerts on its state changes. Facilities without a definition accept any qualified state.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "facility_id":"store001",
+		// "states":[
+		//   {"name":"available"},
+		//   {"name":"damaged","blocks_confidence":true},
+		//   {"name":"sold","blocks_confidence":true,"blocks_alerts":true}
+		// ],
+		// "initial_states":["available"],
+		// "transitions":{"available":["damaged","sold"],"damaged":["available"]}
+		// }
+		// ```
+		//
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       500: internalError
+		//
+		{
+			"UpsertQualifiedStateDefinition",
+			"PUT",
+			"/inventory/qualifiedstates",
+			inventory.UpsertQualifiedStateDefinition,
+		},
+		//swagger:operation GET /inventory/qualifiedstates qualifiedstates getQualifiedStateDefinitions
+		//
+		// Retrieves Qualified-State Definitions
+		//
+		// This API call is used to retrieve the qualified-state definitions of all the facilities, or of one facility.<br><br>
+		//
+		// + `/inventory/qualifiedstates`
+		// + `/inventory/qualifiedstates?facility_id=store001`
+		//
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/json
+		//
+		// parameters:
+		// - name: facility_id
+		//   in: query
+		//   description: Only return the definition of this facility
+		//   required: false
+		//   type: string
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK
+		//     schema:
+		//       description: Results Response
+		//       type: object
+		//       properties:
+		//         results:
+		//           type: array
+		//           description: Array containing results of query
+		//           items:
+		//             "$ref": "#/definitions/QualifiedStateDefinition"
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"GetQualifiedStateDefinitions",
+			"GET",
+			"/inventory/qualifiedstates",
+			inventory.GetQualifiedStateDefinitions,
+		},
+		//swagger:operation GET /inventory/qualifiedstates/history qualifiedstates getQualifiedStateHistory
+		//
+		// Retrieves Qualified-State History
+		//
+		// This API call is used to retrieve the changes of qualified state of a tag, most recent first, with who
+		// changed it, when, from which state and why.<br><br>
+		//
+		// + `/inventory/qualifiedstates/history?epc=30143639F84191AD22900104`
+		//
+		// ---
+		// consumes:
+		// - application/json
+		//
+		// produces:
+		// - application/json
+		//
+		// parameters:
+		// - name: epc
+		//   in: query
+		//   description: EPC of the tag
+		//   required: true
+		//   type: string
+		//
+		// schemes:
+		// - http
+		//
+		// responses:
+		//   200:
+		//     description: OK
+		//     schema:
+		//       description: Results Response
+		//       type: object
+		//       properties:
+		//         results:
+		//           type: array
+		//           description: Array containing results of query
+		//           items:
+		//             "$ref": "#/definitions/QualifiedStateHistoryEntry"
+		//   400:
+		//     "$ref": "#/responses/schemaValidation"
+		//   500:
+		//     "$ref": "#/responses/internalError"
+		//
+		{
+			"GetQualifiedStateHistory",
+			"GET",
+			"/inventory/qualifiedstates/history",
+			inventory.GetQualifiedStateHistory,
+		},
+		//swagger:route POST /inventory/search epc getSearchByEpc
+		//
+		// Retrieves tag data corresponding to specified EPC pattern
+		//
+		// Returns a list of tags with their EPCs matching a pattern. Body parameters shall be provided in request body in JSON format.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "epc":"3038E511C6E9A6400012D687",
+		// "facility_id":"store001",
+		// "cursor":"aGksIDovMSB0aGlz",
+		// "size":500
+		// }
+		// ```
+		//
+		// + epc  - EPC search string which can contain a single asterisk at the beginning, middle, or end of EPC string
+		// + facility_id  - Facility code or identifier
+		// + cursor  - Cursor from previous response used to retrieve next page of results
+		// + size  - Number of results per page
+		// + epc_context  - Fields of the structured epc context the tags must have, by path, e.g. {"asnId":"123","carton.id":"C7"}
+		// + include_archived  - Also search the tags archived after departing
 		//
 		// Example Response:
 		// ```
@@ -623,10 +1506,23 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		// }
 		// ```
 		//
-		// + epc_context  - User-defined context
+		// The context may also be a structured JSON object, which is validated against the registered epc context
+		// schema, if any, and whose fields can then be queried by path, e.g. `$filter=epc_context.asnId eq '123'`:
+		// ```
+		// {
+		// "epc_context":{"asnId":"123","carton":{"id":"C7"}},
+		// "epc":"3038E511C6E9A6400012D687",
+		// "facility_id":"store555"
+		// }
+		// ```
+		//
+		// + epc_context  - User-defined context, a string or a JSON object
 		// + facility_id  - Facility code or identifier
 		// + epc  - SGTIN-96 EPC
 		//
+		// To only set the context if the tag was not modified since it was read, send its `version` in the
+		// If-Match header. A 412 Precondition Failed is returned if the tag is at another version.
+		//
 		//
 		//     Consumes:
 		//     - application/json
@@ -692,12 +1588,162 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 			"/inventory/update/epccontext",
 			inventory.DeleteEpcContext,
 		},
+		//swagger:route PUT /inventory/epccontext/schema epc registerEpcContextSchema
+		//
+		// Register the EPC context schema
+		//
+		// Registers the JSON schema structured EPC contexts must be valid against, replacing the previously
+		// registered one. The request body is the JSON schema itself. Plain string contexts are not validated.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "type":"object",
+		// "required":["asnId"],
+		// "properties":{"asnId":{"type":"string"}}
+		// }
+		// ```
+		//
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       500: internalError
+		//
+		{
+			"RegisterEpcContextSchema",
+			"PUT",
+			"/inventory/epccontext/schema",
+			inventory.RegisterEpcContextSchema,
+		},
+		//swagger:route GET /inventory/epccontext/schema epc getEpcContextSchema
+		//
+		// Retrieve the EPC context schema
+		//
+		// Returns the registered JSON schema structured EPC contexts must be valid against, or 404 Not Found when
+		// no schema is registered.
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       500: internalError
+		//
+		{
+			"GetEpcContextSchema",
+			"GET",
+			"/inventory/epccontext/schema",
+			inventory.GetEpcContextSchema,
+		},
+		//swagger:route DELETE /inventory/epccontext/schema epc deleteEpcContextSchema
+		//
+		// Delete the EPC context schema
+		//
+		// Unregisters the EPC context schema, after which structured EPC contexts are not validated.
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       500: internalError
+		//
+		{
+			"DeleteEpcContextSchema",
+			"DELETE",
+			"/inventory/epccontext/schema",
+			inventory.DeleteEpcContextSchema,
+		},
+		//swagger:route PUT /inventory/update/epccontext/bulk update bulkSetEpcContext
+		//
+		// Update the epc context of many tags
+		//
+		// Sets the epc context of the tags selected either by a list of EPCs, within the facility if given, or by
+		// product ID and/or EPC pattern within the facility, or of all the tags of the facility with all_in_facility,
+		// in one transaction. Responds with the number of tags updated and the listed EPCs that were not found.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "epc_context":"recall lot 42",
+		// "facility_id":"store001",
+		// "productId":"00888446671424"
+		// }
+		// ```
+		//
+		// + epc_context  - Customer defined context, a string or a JSON object validated against the registered
+		// epc context schema, if any
+		// + facility_id  - Facility code or identifier, required unless tags are selected by a list of EPCs
+		// + epcs  - SGTIN-96 EPCs of the tags to update
+		// + productId  - Update the tags of this GTIN-14, instead of a list of EPCs
+		// + epc  - Update the tags matching this EPC pattern with at most one '*', instead of a list of EPCs
+		// + all_in_facility  - Set to true to update all the tags of the facility, instead of a list of EPCs
+		//
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       500: internalError
+		//
+		{
+			"BulkSetEpcContext",
+			"PUT",
+			"/inventory/update/epccontext/bulk",
+			inventory.BulkSetEpcContext,
+		},
 		//swagger:route DELETE /inventory/tags tags deleteAllTags
 		//
 		// Delete Tag Collection in database
 		//
 		// This endpoint allows the customer to delete all the tags in the tags table.<br><br>
 		//
+		// With a request body, only the tags of a facility are deleted, optionally only those last read by some
+		// sensors. The tag processor also forgets them, so that they arrive again when next read. A dry run only
+		// reports how many tags would be deleted from the database and forgotten by the tag processor.
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "facility_id":"store555",
+		// "sensors":["RSP-150009"],
+		// "dry_run":true
+		// }
+		// ```
+		//
+		// + facility_id  - Facility of the tags to delete
+		// + sensors  - Only delete the tags last read by these sensors
+		// + dry_run  - Only count the tags which would be deleted
+		//
+		// Example Response:
+		// ```
+		// {
+		// "deleted":120,
+		// "forgotten":118,
+		// "dry_run":true
+		// }
+		// ```
+		//
 		//     Consumes:
 		//     - application/json
 		//
@@ -707,6 +1753,7 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 		//     Schemes: http
 		//
 		//     Responses:
+		//       200: body:resultsResponse
 		//       204: body:resultsResponse
 		//       400: schemaValidation
 		//       403: forbidden
@@ -721,6 +1768,75 @@ func NewRouter(masterDB *sql.DB, maxSize int) *mux.Router {
 			"/inventory/tags",
 			inventory.DeleteAllTags,
 		},
+		//swagger:route POST /inventory/purge tags purgeTags
+		//
+		// Purge departed tags
+		//
+		// Deletes the departed tags last read more than the given number of days ago, in batches so that the tags
+		// table is not locked for long. With dry_run, the tags are only counted. Departed tags older than
+		// purgingDays are also purged every purgingIntervalHours. In facilities with a time zone or business hours,
+		// the days are the business days before the current one, otherwise periods of 24 hours.<br><br>
+		//
+		// Example Request Input:
+		// ```
+		// {
+		// "days":90,
+		// "dry_run":true
+		// }
+		// ```
+		//
+		// Example Response:
+		// ```
+		// {
+		// "count":1520,
+		// "dry_run":true
+		// }
+		// ```
+		//
+		// + days  - Number of days departed tags are kept, counted from their last read (ttl)
+		// + dry_run  - Only count the tags which would be purged
+		//
+		//
+		//     Consumes:
+		//     - application/json
+		//
+		//     Produces:
+		//     - application/json
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       200: body:resultsResponse
+		//       400: schemaValidation
+		//       500: internalError
+		//
+		{
+			"PurgeTags",
+			"POST",
+			"/inventory/purge",
+			inventory.PurgeTags,
+		},
+		//swagger:route DELETE /inventory/productdata/cache productdata invalidateProductDataCache
+		//
+		// Invalidate the product data cache
+		//
+		// The product data of the mapping SKU service, whose coefficients override the ones of the facilities, is
+		// cached for productDataCacheTTLSeconds. Invalidating the cache fetches the product data again on its next
+		// use, e.g. after the product coefficients changed. If the mapping SKU service cannot be reached then, the
+		// cached product data keeps being used.<br><br>
+		//
+		//     Schemes: http
+		//
+		//     Responses:
+		//       204: body:resultsResponse
+		//       500: internalError
+		//
+		{
+			"InvalidateProductDataCache",
+			"DELETE",
+			"/inventory/productdata/cache",
+			inventory.InvalidateProductDataCache,
+		},
 	}
 
 	router := mux.NewRouter().StrictSlash(true)
@@ -0,0 +1,84 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package schemas
+
+// BulkUpdateQualifiedStateSchema defines the body which sets the qualified state of many tags
+const BulkUpdateQualifiedStateSchema = `{
+	"type": "object",
+	"required": ["qualified_state"],
+	"properties": {
+		"epcs": {
+			"type": "array",
+			"items": {
+				"type": "string",
+				"pattern": "^[a-fA-F0-9]{1,}$"
+			},
+			"minItems": 1,
+			"uniqueItems": true
+		},
+		"facility_id": {
+			"type": "string"
+		},
+		"all_in_facility": {
+			"type": "boolean"
+		},
+		"productId": {
+			"type": "string",
+			"pattern": "^\\d{14}$"
+		},
+		"epc": {
+			"type": "string",
+			"pattern": "^(?:[a-fA-F0-9]+\\*?[a-fA-F0-9]*|[a-fA-F0-9]*\\*?[a-fA-F0-9]+)$"
+		},
+		"qualified_state": {
+			"type": "string",
+			"pattern": "^[-a-zA-Z0-9_ ]{1,}$"
+		},
+		"changed_by": {
+			"type": "string"
+		},
+		"reason": {
+			"type": "string"
+		}
+	},
+	"additionalProperties": false
+}`
+
+// BulkSetEpcContextSchema defines the body which sets the epc context of many tags
+const BulkSetEpcContextSchema = `{
+	"type": "object",
+	"required": ["epc_context"],
+	"properties": {
+		"epcs": {
+			"type": "array",
+			"items": {
+				"type": "string",
+				"pattern": "^[a-fA-F0-9]{1,}$"
+			},
+			"minItems": 1,
+			"uniqueItems": true
+		},
+		"facility_id": {
+			"type": "string"
+		},
+		"all_in_facility": {
+			"type": "boolean"
+		},
+		"productId": {
+			"type": "string",
+			"pattern": "^\\d{14}$"
+		},
+		"epc": {
+			"type": "string",
+			"pattern": "^(?:[a-fA-F0-9]+\\*?[a-fA-F0-9]*|[a-fA-F0-9]*\\*?[a-fA-F0-9]+)$"
+		},
+		"epc_context": {
+			"type": ["string", "object"]
+		}
+	},
+	"additionalProperties": false
+}`
@@ -31,6 +31,59 @@ const CoefficientsSchema = `{
 		},
 		"probexiterror": {
 			"type": "number"
+		},
+		"changed_by": {
+			"type": "string"
+		},
+		"reason": {
+			"type": "string"
+		}
+	},
+	"additionalProperties": false
+}`
+
+// RollbackCoefficientsSchema gets the json schema to roll the coefficients of a facility back to a previous version
+const RollbackCoefficientsSchema = `{
+	"type": "object",
+	"required": [
+		"facility_id",
+		"version"
+	],
+	"properties": {
+		"facility_id": {
+			"type": "string",
+			"minLength": 1
+		},
+		"version": {
+			"type": "integer",
+			"minimum": 1
+		},
+		"changed_by": {
+			"type": "string"
+		},
+		"reason": {
+			"type": "string"
+		}
+	},
+	"additionalProperties": false
+}`
+
+// ApplyEstimateSchema gets the json schema to apply the estimated read probabilities to the coefficients of a facility
+const ApplyEstimateSchema = `{
+	"type": "object",
+	"required": [
+		"facility_id"
+	],
+	"properties": {
+		"facility_id": {
+			"type": "string",
+			"minLength": 1
+		},
+		"changed_by": {
+			"type": "string"
+		},
+		"reason": {
+			"type": "string"
 		}
 	},
 	"additionalProperties": false
@@ -0,0 +1,44 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package schemas
+
+// ExportSchema defines the optional request body filtering the tags of the export endpoint
+const ExportSchema = `{
+	"type": "object",
+	"properties": {
+		"facility_id": {
+			"type": "string"
+		},
+		"qualified_state": {
+			"type": "string"
+		},
+		"epc_state": {
+			"type": "string"
+		},
+		"starttime": {
+			"type": "integer"
+		},
+		"endtime": {
+			"type": "integer"
+		},
+		"productId": {
+			"type": "string",
+			"pattern": "^\\d{14}$"
+		},
+		"epc": {
+			"type": "string",
+			"pattern": "^(?:[a-fA-F0-9]+\\*?[a-fA-F0-9]*|[a-fA-F0-9]*\\*?[a-fA-F0-9]+|\\*)$"
+		},
+		"confidence": {
+			"type": "number"
+		},
+		"include_archived": {
+			"type": "boolean"
+		}
+	},
+	"additionalProperties": false
+}`
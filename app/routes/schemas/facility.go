@@ -0,0 +1,114 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package schemas
+
+// facilityMetadataProperties are the properties of a facility, other than its coefficients
+const facilityMetadataProperties = `
+		"name": {
+			"type": "string",
+			"minLength": 1
+		},
+		"display_name": {
+			"type": "string"
+		},
+		"timezone": {
+			"type": "string"
+		},
+		"address": {
+			"type": "string"
+		},
+		"type": {
+			"type": "string",
+			"enum": ["store", "dc"]
+		},
+		"business_hours": {
+			"type": "object",
+			"required": [
+				"open",
+				"close"
+			],
+			"properties": {
+				"open": {
+					"type": "string",
+					"pattern": "^([01][0-9]|2[0-3]):[0-5][0-9]$"
+				},
+				"close": {
+					"type": "string",
+					"pattern": "^([01][0-9]|2[0-3]):[0-5][0-9]$"
+				}
+			},
+			"additionalProperties": false
+		},
+		"asn_receiving": {
+			"type": "boolean"
+		},
+		"probably_missing_threshold": {
+			"type": "number",
+			"minimum": 0,
+			"maximum": 1
+		}`
+
+// CreateFacilitySchema gets the json schema to create a facility
+const CreateFacilitySchema = `{
+	"type": "object",
+	"required": [
+		"name"
+	],
+	"properties": {` + facilityMetadataProperties + `,
+		"coefficients": {
+			"type": "object",
+			"required": [
+				"dailyinventorypercentage",
+				"probunreadtoread",
+				"probinstoreread",
+				"probexiterror"
+			],
+			"properties": {
+				"dailyinventorypercentage": {
+					"type": "number"
+				},
+				"probunreadtoread": {
+					"type": "number"
+				},
+				"probinstoreread": {
+					"type": "number"
+				},
+				"probexiterror": {
+					"type": "number"
+				}
+			},
+			"additionalProperties": false
+		}
+	},
+	"additionalProperties": false
+}`
+
+// UpdateFacilitySchema gets the json schema to update the metadata of a facility
+const UpdateFacilitySchema = `{
+	"type": "object",
+	"required": [
+		"name"
+	],
+	"properties": {` + facilityMetadataProperties + `
+	},
+	"additionalProperties": false
+}`
+
+// DeleteFacilitySchema gets the json schema to delete a facility
+const DeleteFacilitySchema = `{
+	"type": "object",
+	"required": [
+		"name"
+	],
+	"properties": {
+		"name": {
+			"type": "string",
+			"minLength": 1
+		}
+	},
+	"additionalProperties": false
+}`
@@ -23,6 +23,27 @@ const PostCurrentInventorySchema = `{
 		},
 		"endtime": {
 			"type": "integer"
+		},
+		"size": {
+			"type": "integer",
+			"minimum": 1
+		},
+		"cursor": {
+			"type": "string"
+		},
+		"epc_context": {
+			"type": "object",
+			"propertyNames": {
+				"pattern": "^[a-zA-Z][a-zA-Z0-9_.]*$"
+			},
+			"additionalProperties": {
+				"type": "string",
+				"pattern": "^[^']*$"
+			}
+		},
+		"as_of": {
+			"type": "integer",
+			"minimum": 1
 		}
 	},
 	"additionalProperties": false
@@ -6,7 +6,7 @@
 
 package schemas
 
-// PurgingSchema gets the json schema to update purging days
+// PurgingSchema gets the json schema to purge the departed tags older than a number of days
 const PurgingSchema = `{
 	 "type": "object",
 	 "required": [
@@ -14,7 +14,11 @@ const PurgingSchema = `{
 	 ],
 	 "properties": {	
 		 "days": {
-			 "type": "integer"
+			 "type": "integer",
+			 "minimum": 0
+		 },
+		 "dry_run": {
+			 "type": "boolean"
 		 }
 	 },
 	 "additionalProperties": false
@@ -0,0 +1,58 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package schemas
+
+// QualifiedStateDefinitionSchema defines the qualified-state workflow of a facility
+const QualifiedStateDefinitionSchema = `{
+	"type": "object",
+	"required": ["facility_id", "states"],
+	"properties": {
+		"facility_id": {
+			"type": "string",
+			"minLength": 1
+		},
+		"states": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {
+						"type": "string",
+						"pattern": "^[-a-zA-Z0-9_ ]{1,}$"
+					},
+					"blocks_confidence": {
+						"type": "boolean"
+					},
+					"blocks_alerts": {
+						"type": "boolean"
+					}
+				},
+				"additionalProperties": false
+			}
+		},
+		"initial_states": {
+			"type": "array",
+			"items": {
+				"type": "string"
+			},
+			"uniqueItems": true
+		},
+		"transitions": {
+			"type": "object",
+			"additionalProperties": {
+				"type": "array",
+				"items": {
+					"type": "string"
+				},
+				"uniqueItems": true
+			}
+		}
+	},
+	"additionalProperties": false
+}`
@@ -0,0 +1,32 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package schemas
+
+// ResetSchema gets the json schema to reset the tags of a facility, optionally only those of some sensors
+const ResetSchema = `{
+	 "type": "object",
+	 "required": [
+		 "facility_id"
+	 ],
+	 "properties": {
+		 "facility_id": {
+			 "type": "string",
+			 "minLength": 1
+		 },
+		 "sensors": {
+			 "type": "array",
+			 "items": {
+				 "type": "string",
+				 "minLength": 1
+			 }
+		 },
+		 "dry_run": {
+			 "type": "boolean"
+		 }
+	 },
+	 "additionalProperties": false
+ }`
@@ -25,6 +25,19 @@ const SearchByEpcSchema = `{
 		"cursor": {
 			"type": "string"
 		},
+		"epc_context": {
+			"type": "object",
+			"propertyNames": {
+				"pattern": "^[a-zA-Z][a-zA-Z0-9_.]*$"
+			},
+			"additionalProperties": {
+				"type": "string",
+				"pattern": "^[^']*$"
+			}
+		},
+		"include_archived": {
+			"type": "boolean"
+		},
 		"confidence": {
 			"type": "number"
 		}
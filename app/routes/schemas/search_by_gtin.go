@@ -27,6 +27,9 @@ const SearchByProductIdSchema = `{
 		"facility_id": {
 			"type": "string"
 		},
+		"include_archived": {
+			"type": "boolean"
+		},
 		"confidence": {
 			"type": "number"
 		}
@@ -21,7 +21,7 @@ const SetEpcContextSchema = `{
       "type": "string"
     },
     "epc_context": {
-      "type": "string"
+      "type": ["string", "object"]
     }
   },
   "additionalProperties": false
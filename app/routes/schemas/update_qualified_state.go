@@ -21,6 +21,12 @@ const UpdateQualifiedStateSchema = `{
 		"qualified_state": {
 			"type": "string",
 			"pattern": "^[-a-zA-Z0-9_ ]{1,}$"
+		},
+		"changed_by": {
+			"type": "string"
+		},
+		"reason": {
+			"type": "string"
 		}
 	},
 	"additionalProperties": false
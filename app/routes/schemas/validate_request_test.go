@@ -51,6 +51,52 @@ func TestValidatePostCurrentInventoryRequest(t *testing.T) {
 	if result.Valid() {
 		t.Fatal("Failed to catch json schema validation error, facility_id type is wrong")
 	}
+
+	asOfRequest := []byte(`{
+		"facility_id": "store001",
+		"as_of":1483228800000
+	  }`)
+	result, err = ValidateSchemaRequest(asOfRequest, PostCurrentInventorySchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if !result.Valid() {
+		t.Errorf("Validation of Json schema failed %s", result.Errors())
+	}
+
+	contextRequest := []byte(`{
+		"facility_id": "store001",
+		"epc_context": {"asnId": "123", "carton.id": "C7"}
+	  }`)
+	result, err = ValidateSchemaRequest(contextRequest, PostCurrentInventorySchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if !result.Valid() {
+		t.Errorf("Validation of Json schema failed %s", result.Errors())
+	}
+
+	invalidRequest = []byte(`{
+		"epc_context": {"asnId": "1' or '1"}
+	  }`)
+	result, err = ValidateSchemaRequest(invalidRequest, PostCurrentInventorySchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if result.Valid() {
+		t.Fatal("Failed to catch json schema validation error, epc_context values cannot contain quotes")
+	}
+
+	invalidRequest = []byte(`{
+		"as_of":0
+	  }`)
+	result, err = ValidateSchemaRequest(invalidRequest, PostCurrentInventorySchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if result.Valid() {
+		t.Fatal("Failed to catch json schema validation error, as_of must be positive")
+	}
 }
 
 //nolint: dupl
@@ -105,3 +151,108 @@ func TestValidateDeleteEpcContextRequest(t *testing.T) {
 		t.Fatal("Failed to catch json schema validation error, additional properties")
 	}
 }
+
+func TestValidatePurgingRequest(t *testing.T) {
+	result, err := ValidateSchemaRequest([]byte(`{"days": 90, "dry_run": true}`), PurgingSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if !result.Valid() {
+		t.Errorf("Validation of Json schema failed %s", result.Errors())
+	}
+
+	result, err = ValidateSchemaRequest([]byte(`{"days": -1}`), PurgingSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if result.Valid() {
+		t.Fatal("Failed to catch json schema validation error, days cannot be negative")
+	}
+}
+
+func TestValidateResetRequest(t *testing.T) {
+	result, err := ValidateSchemaRequest([]byte(`{"facility_id": "store1", "sensors": ["RSP-150009"], "dry_run": true}`), ResetSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if !result.Valid() {
+		t.Errorf("Validation of Json schema failed %s", result.Errors())
+	}
+
+	result, err = ValidateSchemaRequest([]byte(`{"sensors": ["RSP-150009"]}`), ResetSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if result.Valid() {
+		t.Fatal("Failed to catch json schema validation error, facility_id is required")
+	}
+}
+
+func TestValidateFacilityRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		schema  string
+		valid   bool
+	}{
+		{"create", `{"name": "store1", "display_name": "Store 1", "timezone": "America/Los_Angeles", "type": "store", "asn_receiving": true}`, CreateFacilitySchema, true},
+		{"create with coefficients", `{"name": "dc1", "type": "dc", "coefficients": {"dailyinventorypercentage": 0.01, "probunreadtoread": 0.2, "probinstoreread": 0.75, "probexiterror": 0.1}}`, CreateFacilitySchema, true},
+		{"create without name", `{"display_name": "Store 1"}`, CreateFacilitySchema, false},
+		{"create unknown type", `{"name": "store1", "type": "warehouse"}`, CreateFacilitySchema, false},
+		{"create with business hours", `{"name": "store1", "business_hours": {"open": "10:00", "close": "02:00"}}`, CreateFacilitySchema, true},
+		{"create invalid business hours", `{"name": "store1", "business_hours": {"open": "25:00", "close": "02:00"}}`, CreateFacilitySchema, false},
+		{"create with probably missing threshold", `{"name": "store1", "probably_missing_threshold": 0.05}`, CreateFacilitySchema, true},
+		{"create invalid probably missing threshold", `{"name": "store1", "probably_missing_threshold": 5}`, CreateFacilitySchema, false},
+		{"update", `{"name": "store1", "address": "2111 NE 25th Ave, Hillsboro, OR"}`, UpdateFacilitySchema, true},
+		{"update coefficients", `{"name": "store1", "coefficients": {}}`, UpdateFacilitySchema, false},
+		{"delete", `{"name": "store1"}`, DeleteFacilitySchema, true},
+		{"delete without name", `{}`, DeleteFacilitySchema, false},
+	}
+
+	for _, test := range tests {
+		result, err := ValidateSchemaRequest([]byte(test.request), test.schema)
+		if err != nil {
+			t.Errorf("%s: error validating the json schema %s", test.name, err)
+			continue
+		}
+		if result.Valid() != test.valid {
+			t.Errorf("%s: expected valid %v, got errors %s", test.name, test.valid, result.Errors())
+		}
+	}
+}
+
+func TestValidateRollbackCoefficientsRequest(t *testing.T) {
+	result, err := ValidateSchemaRequest([]byte(`{"facility_id": "store1", "version": 2, "changed_by": "jdoe", "reason": "undo"}`), RollbackCoefficientsSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if !result.Valid() {
+		t.Errorf("Validation of Json schema failed %s", result.Errors())
+	}
+
+	result, err = ValidateSchemaRequest([]byte(`{"facility_id": "store1", "version": 0}`), RollbackCoefficientsSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if result.Valid() {
+		t.Fatal("Failed to catch json schema validation error, versions start at 1")
+	}
+}
+
+func TestValidateApplyEstimateRequest(t *testing.T) {
+	result, err := ValidateSchemaRequest([]byte(`{"facility_id": "store1", "changed_by": "jdoe", "reason": "measured"}`), ApplyEstimateSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if !result.Valid() {
+		t.Errorf("Validation of Json schema failed %s", result.Errors())
+	}
+
+	result, err = ValidateSchemaRequest([]byte(`{"facility_id": "", "probinstoreread": 0.5}`), ApplyEstimateSchema)
+	if err != nil {
+		t.Errorf("Error validating the json schema %s", err)
+	}
+	if result.Valid() {
+		t.Fatal("Failed to catch json schema validation error, the estimated values cannot be set")
+	}
+}
@@ -0,0 +1,262 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Fields tags can be aggregated by
+const (
+	AggregateProductID      = "product_id"
+	AggregateFacilityID     = "facility_id"
+	AggregateEpcState       = "epc_state"
+	AggregateQualifiedState = "qualified_state"
+	AggregateLocation       = "location"
+)
+
+// AggregateQuery is the model of the grouping and filters of an aggregation
+type AggregateQuery struct {
+	// Fields to group by, any combination of product_id, facility_id, epc_state, qualified_state and location
+	GroupBy []string
+	// Filters, each ignored when empty
+	ProductID      string
+	FacilityID     string
+	EpcState       string
+	QualifiedState string
+	Location       string
+}
+
+// AggregateGroup is the number of tags sharing the values of the grouped fields
+type AggregateGroup struct {
+	// Values of the grouped fields
+	Group map[string]string `json:"group"`
+	// Number of tags in the group
+	Count int `json:"count"`
+	// Sum of the confidence of the tags in the group, when requested
+	ExpectedCount *float64 `json:"expected_count,omitempty"`
+}
+
+// AggregateBucket is the number of tags of a group that share the inputs confidence is computed from:
+// facility, product and last read time, rounded down to the bucket size
+type AggregateBucket struct {
+	Group      map[string]string
+	FacilityID string
+	ProductID  string
+	LastRead   int64
+	Count      int
+}
+
+// aggregateExpressions are the SQL expressions of the fields tags can be aggregated by
+var aggregateExpressions = map[string]string{
+	AggregateProductID:      fmt.Sprintf("%s ->> 'product_id'", pq.QuoteIdentifier(jsonb)),
+	AggregateFacilityID:     fmt.Sprintf("%s ->> 'facility_id'", pq.QuoteIdentifier(jsonb)),
+	AggregateEpcState:       fmt.Sprintf("%s ->> 'epc_state'", pq.QuoteIdentifier(jsonb)),
+	AggregateQualifiedState: fmt.Sprintf("%s ->> 'qualified_state'", pq.QuoteIdentifier(jsonb)),
+	AggregateLocation:       fmt.Sprintf("%s -> 'location_history' -> 0 ->> 'location'", pq.QuoteIdentifier(jsonb)),
+}
+
+// Aggregate counts the tags matching the filters of the query by the grouped fields
+func Aggregate(dbs *sql.DB, query AggregateQuery) ([]AggregateGroup, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Aggregate.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Aggregate.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge("Inventory.Aggregate.Find-Error", nil)
+	mFindLatency := metrics.GetOrRegisterTimer(`Inventory.Aggregate.Find-Latency`, nil)
+
+	whereClause, err := buildAggregateWhereClause(query)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := groupByColumns(query.GroupBy)
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s %s %s;`,
+		strings.Join(append(columns, "count(*)"), ", "),
+		pq.QuoteIdentifier(tagsTable),
+		whereClause,
+		groupByClause(len(columns)),
+	)
+
+	retrieveTimer := time.Now()
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mFindErr.Update(1)
+		return nil, errors.Wrap(err, "error in aggregating tags")
+	}
+	mFindLatency.Update(time.Since(retrieveTimer))
+	defer rows.Close()
+
+	groups := make([]AggregateGroup, 0)
+	for rows.Next() {
+		values := make([]string, len(columns))
+		var group AggregateGroup
+		if err := rows.Scan(append(scanTargets(values), &group.Count)...); err != nil {
+			mFindErr.Update(1)
+			return nil, err
+		}
+		group.Group = groupOf(query.GroupBy, values)
+		groups = append(groups, group)
+	}
+	if err = rows.Err(); err != nil {
+		mFindErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return groups, nil
+}
+
+// AggregateBuckets counts the tags matching the filters of the query by the grouped fields and the inputs
+// of their confidence, so that the expected count of each group can be computed from a bounded number of rows
+func AggregateBuckets(dbs *sql.DB, query AggregateQuery, bucketMillis int64) ([]AggregateBucket, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.AggregateBuckets.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.AggregateBuckets.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge("Inventory.AggregateBuckets.Find-Error", nil)
+	mFindLatency := metrics.GetOrRegisterTimer(`Inventory.AggregateBuckets.Find-Latency`, nil)
+
+	if bucketMillis < 1 {
+		return nil, errors.Wrap(web.ErrValidation, "bucket size must be positive")
+	}
+
+	whereClause, err := buildAggregateWhereClause(query)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := append(groupByColumns(query.GroupBy),
+		fmt.Sprintf("COALESCE(%s, '')", aggregateExpressions[AggregateFacilityID]),
+		fmt.Sprintf("COALESCE(%s, '')", aggregateExpressions[AggregateProductID]),
+		fmt.Sprintf("COALESCE((%s ->> 'last_read')::BIGINT / %d * %d, 0)", pq.QuoteIdentifier(jsonb), bucketMillis, bucketMillis),
+	)
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s %s %s;`,
+		strings.Join(append(columns, "count(*)"), ", "),
+		pq.QuoteIdentifier(tagsTable),
+		whereClause,
+		groupByClause(len(columns)),
+	)
+
+	retrieveTimer := time.Now()
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mFindErr.Update(1)
+		return nil, errors.Wrap(err, "error in aggregating tags")
+	}
+	mFindLatency.Update(time.Since(retrieveTimer))
+	defer rows.Close()
+
+	buckets := make([]AggregateBucket, 0)
+	for rows.Next() {
+		values := make([]string, len(query.GroupBy))
+		var bucket AggregateBucket
+		targets := append(scanTargets(values), &bucket.FacilityID, &bucket.ProductID, &bucket.LastRead, &bucket.Count)
+		if err := rows.Scan(targets...); err != nil {
+			mFindErr.Update(1)
+			return nil, err
+		}
+		bucket.Group = groupOf(query.GroupBy, values)
+		buckets = append(buckets, bucket)
+	}
+	if err = rows.Err(); err != nil {
+		mFindErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return buckets, nil
+}
+
+// ValidateGroupBy checks that the fields are ones tags can be aggregated by, without duplicates
+func ValidateGroupBy(groupBy []string) error {
+	seen := make(map[string]bool, len(groupBy))
+	for _, field := range groupBy {
+		if _, ok := aggregateExpressions[field]; !ok {
+			return errors.Wrapf(web.ErrValidation, "cannot group by %s", field)
+		}
+		if seen[field] {
+			return errors.Wrapf(web.ErrValidation, "%s is grouped by more than once", field)
+		}
+		seen[field] = true
+	}
+	return nil
+}
+
+func buildAggregateWhereClause(query AggregateQuery) (string, error) {
+	if err := ValidateGroupBy(query.GroupBy); err != nil {
+		return "", err
+	}
+
+	filters := []struct {
+		field string
+		value string
+	}{
+		{AggregateProductID, query.ProductID},
+		{AggregateFacilityID, query.FacilityID},
+		{AggregateEpcState, query.EpcState},
+		{AggregateQualifiedState, query.QualifiedState},
+		{AggregateLocation, query.Location},
+	}
+
+	var conditions []string
+	for _, filter := range filters {
+		if filter.value != "" {
+			conditions = append(conditions, fmt.Sprintf("%s = %s",
+				aggregateExpressions[filter.field], pq.QuoteLiteral(filter.value)))
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+func groupByColumns(groupBy []string) []string {
+	columns := make([]string, 0, len(groupBy))
+	for _, field := range groupBy {
+		columns = append(columns, fmt.Sprintf("COALESCE(%s, '')", aggregateExpressions[field]))
+	}
+	return columns
+}
+
+// groupByClause groups and orders by the first numColumns selected columns
+func groupByClause(numColumns int) string {
+	if numColumns == 0 {
+		return ""
+	}
+	positions := make([]string, numColumns)
+	for i := range positions {
+		positions[i] = fmt.Sprintf("%d", i+1)
+	}
+	return fmt.Sprintf("GROUP BY %s ORDER BY %s", strings.Join(positions, ", "), strings.Join(positions, ", "))
+}
+
+func scanTargets(values []string) []interface{} {
+	targets := make([]interface{}, len(values))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	return targets
+}
+
+func groupOf(groupBy []string, values []string) map[string]string {
+	group := make(map[string]string, len(groupBy))
+	for i, field := range groupBy {
+		group[field] = values[i]
+	}
+	return group
+}
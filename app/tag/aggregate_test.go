@@ -0,0 +1,79 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+func TestAggregate(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	location := func(name string) []LocationHistory {
+		return []LocationHistory{{Location: name}}
+	}
+	tags := []Tag{
+		{Epc: "1", ProductID: "P1", FacilityID: "F1", EpcState: "present", LocationHistory: location("L1"), LastRead: 1000},
+		{Epc: "2", ProductID: "P1", FacilityID: "F1", EpcState: "present", LocationHistory: location("L2"), LastRead: 2000},
+		{Epc: "3", ProductID: "P2", FacilityID: "F1", EpcState: "departed", LocationHistory: location("L1"), LastRead: 70000},
+		{Epc: "4", ProductID: "P2", FacilityID: "F2", EpcState: "present"},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	groups, err := Aggregate(testDB.DB, AggregateQuery{GroupBy: []string{AggregateFacilityID, AggregateProductID}})
+	if err != nil {
+		t.Fatalf("Unable to aggregate tags: %+v", err)
+	}
+	expected := []AggregateGroup{
+		{Group: map[string]string{AggregateFacilityID: "F1", AggregateProductID: "P1"}, Count: 2},
+		{Group: map[string]string{AggregateFacilityID: "F1", AggregateProductID: "P2"}, Count: 1},
+		{Group: map[string]string{AggregateFacilityID: "F2", AggregateProductID: "P2"}, Count: 1},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("expected %+v, got %+v", expected, groups)
+	}
+
+	groups, err = Aggregate(testDB.DB, AggregateQuery{GroupBy: []string{AggregateLocation}, FacilityID: "F1", EpcState: "present"})
+	if err != nil {
+		t.Fatalf("Unable to aggregate tags: %+v", err)
+	}
+	expected = []AggregateGroup{
+		{Group: map[string]string{AggregateLocation: "L1"}, Count: 1},
+		{Group: map[string]string{AggregateLocation: "L2"}, Count: 1},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("expected %+v, got %+v", expected, groups)
+	}
+
+	groups, err = Aggregate(testDB.DB, AggregateQuery{})
+	if err != nil {
+		t.Fatalf("Unable to aggregate tags: %+v", err)
+	}
+	if len(groups) != 1 || groups[0].Count != len(tags) {
+		t.Errorf("expected a single group of all tags, got %+v", groups)
+	}
+
+	buckets, err := AggregateBuckets(testDB.DB, AggregateQuery{GroupBy: []string{AggregateFacilityID}, FacilityID: "F1"}, 60000)
+	if err != nil {
+		t.Fatalf("Unable to aggregate tags: %+v", err)
+	}
+	// P1 tags share the first minute, the P2 tag is in the second one
+	if len(buckets) != 2 || buckets[0].Count != 2 || buckets[1].LastRead != 60000 {
+		t.Errorf("unexpected buckets %+v", buckets)
+	}
+
+	if _, err := Aggregate(testDB.DB, AggregateQuery{GroupBy: []string{"epc"}}); errors.Cause(err) != web.ErrValidation {
+		t.Errorf("expected a validation error grouping by an unsupported field, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ArchivedParam is the query parameter which includes the archived tags in the results when true
+	ArchivedParam = "include_archived"
+	archiveTable  = "tags_archive"
+	// allTagsView is the view of the tags and the archived tags
+	allTagsView = "tags_all"
+)
+
+// includeArchived removes ArchivedParam from the query, which the OData parser does not know, and
+// reports whether it was true
+func includeArchived(query url.Values) (bool, error) {
+	value := query.Get(ArchivedParam)
+	delete(query, ArchivedParam)
+	if value == "" {
+		return false, nil
+	}
+
+	archived, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Wrapf(web.ErrValidation, "invalid %s value", ArchivedParam)
+	}
+	return archived, nil
+}
+
+// Archive moves the departed tags last read before their cutoff from the tags table to the archive table,
+// which has the same shape. Tags are moved in batches of batchSize, each in its own transaction, so that
+// the table is never locked for long. The journal, if any, records the archived tags in the transaction
+// of their batch.
+func Archive(dbs *sql.DB, cutoffs Cutoffs, batchSize int, journal Journal) (int64, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Archive.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Archive.Success`, nil)
+	mArchiveErr := metrics.GetOrRegisterGauge(`Inventory.Archive.Archive-Error`, nil)
+	mArchived := metrics.GetOrRegisterGauge(`Inventory.Archive.Archived`, nil)
+	mArchiveLatency := metrics.GetOrRegisterTimer(`Inventory.Archive.Archive-Latency`, nil)
+
+	if batchSize < 1 {
+		return 0, errors.Errorf("invalid archive batch size %d", batchSize)
+	}
+
+	archiveStmt := fmt.Sprintf(`WITH moved AS (
+			DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE %s LIMIT %d) RETURNING id, %s
+		)
+		INSERT INTO %s (id, %s) SELECT id, %s FROM moved
+		ON CONFLICT ((%s ->> %s)) DO UPDATE SET %s = EXCLUDED.%s
+		RETURNING %s;`,
+		pq.QuoteIdentifier(tagsTable),
+		pq.QuoteIdentifier(tagsTable),
+		departedWhereClause(cutoffs),
+		batchSize,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(archiveTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(epcColumn),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+	)
+
+	archiveTimer := time.Now()
+	var archived int64
+	for {
+		moved, err := removeInTransaction(dbs, archiveStmt, journal)
+		if err != nil {
+			mArchiveErr.Update(1)
+			return archived, errors.Wrap(err, "error in archiving tags")
+		}
+		archived += moved
+		if moved < int64(batchSize) {
+			break
+		}
+	}
+	mArchiveLatency.Update(time.Since(archiveTimer))
+
+	mArchived.Update(archived)
+	mSuccess.Update(1)
+	return archived, nil
+}
+
+// Restore moves the archived tag back to the tags table and returns it, or returns an empty Tag if the
+// EPC is not archived
+func Restore(dbs *sql.DB, epc string) (Tag, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Restore.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Restore.Success`, nil)
+	mRestoreErr := metrics.GetOrRegisterGauge(`Inventory.Restore.Restore-Error`, nil)
+
+	// should the tag have been inserted meanwhile, its current state is kept over the archived one
+	restoreStmt := fmt.Sprintf(`WITH restored AS (
+			DELETE FROM %s WHERE %s ->> %s = %s RETURNING id, %s
+		)
+		INSERT INTO %s (id, %s) SELECT id, %s FROM restored
+		ON CONFLICT ((%s ->> %s)) DO UPDATE SET %s = EXCLUDED.%s || %s.%s
+		RETURNING %s;`,
+		pq.QuoteIdentifier(archiveTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(epcColumn),
+		pq.QuoteLiteral(epc),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(tagsTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(epcColumn),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(tagsTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+	)
+
+	var tag Tag
+	if err := dbs.QueryRow(restoreStmt).Scan(&tag); err != nil {
+		if err == sql.ErrNoRows {
+			mSuccess.Update(1)
+			return Tag{}, nil
+		}
+		mRestoreErr.Update(1)
+		return Tag{}, errors.Wrap(err, "error in restoring an archived tag")
+	}
+
+	mSuccess.Update(1)
+	return tag, nil
+}
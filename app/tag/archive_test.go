@@ -0,0 +1,122 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+func TestIncludeArchived(t *testing.T) {
+	query := url.Values{ArchivedParam: {"true"}, "$filter": {"epc eq '3014'"}}
+	archived, err := includeArchived(query)
+	if err != nil || !archived {
+		t.Errorf("expected archived tags to be included: %v", err)
+	}
+	if _, found := query[ArchivedParam]; found {
+		t.Error("expected the parameter to be removed from the OData query")
+	}
+
+	if archived, err := includeArchived(url.Values{}); err != nil || archived {
+		t.Errorf("expected archived tags to be excluded by default: %v", err)
+	}
+	if _, err := includeArchived(url.Values{ArchivedParam: {"maybe"}}); errors.Cause(err) != web.ErrValidation {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "departed", LastRead: 1000, QualifiedState: "sold"},
+		{Epc: "3014AA02", FacilityID: "store1", EpcState: "departed", LastRead: 2000},
+		{Epc: "3014AA03", FacilityID: "store1", EpcState: "present", LastRead: 1000},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	archived, err := Archive(testDB.DB, Cutoffs{Default: 5000}, 1, nil)
+	if err != nil {
+		t.Fatalf("Unable to archive tags: %+v", err)
+	}
+	if archived != 2 {
+		t.Errorf("expected 2 archived tags, got %d", archived)
+	}
+
+	hot, _, _, err := Retrieve(testDB.DB, url.Values{}, 100)
+	if err != nil {
+		t.Fatalf("Unable to retrieve tags: %+v", err)
+	}
+	if found := hot.([]Tag); len(found) != 1 {
+		t.Errorf("expected only the present tag in the tags table, got %+v", found)
+	}
+
+	all, _, _, err := Retrieve(testDB.DB, url.Values{ArchivedParam: {"true"}}, 100)
+	if err != nil {
+		t.Fatalf("Unable to retrieve tags: %+v", err)
+	}
+	if found := all.([]Tag); len(found) != 3 {
+		t.Errorf("expected the archived tags to be included, got %+v", found)
+	}
+
+	restored, err := Restore(testDB.DB, "3014AA01")
+	if err != nil {
+		t.Fatalf("Unable to restore tag: %+v", err)
+	}
+	if restored.Epc != "3014AA01" || restored.QualifiedState != "sold" {
+		t.Errorf("expected the archived state to be restored, got %+v", restored)
+	}
+	if found, err := FindByEpc(testDB.DB, "3014AA01"); err != nil || found.Epc != "3014AA01" {
+		t.Errorf("expected the restored tag back in the tags table, got %+v: %v", found, err)
+	}
+
+	notArchived, err := Restore(testDB.DB, "3014AA03")
+	if err != nil {
+		t.Fatalf("Unable to restore tag: %+v", err)
+	}
+	if notArchived.Epc != "" {
+		t.Errorf("expected nothing to restore, got %+v", notArchived)
+	}
+}
+
+func TestPurgeArchive(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "departed", LastRead: 1000},
+		{Epc: "3014AA02", FacilityID: "store1", EpcState: "departed", LastRead: 3000},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+	if _, err := Archive(testDB.DB, Cutoffs{Default: 5000}, 10, nil); err != nil {
+		t.Fatalf("Unable to archive tags: %+v", err)
+	}
+
+	purged, err := PurgeArchive(testDB.DB, Cutoffs{Default: 2000}, 1)
+	if err != nil {
+		t.Fatalf("Unable to purge archived tags: %+v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged archived tag, got %d", purged)
+	}
+
+	all, _, _, err := Retrieve(testDB.DB, url.Values{ArchivedParam: {"true"}}, 100)
+	if err != nil {
+		t.Fatalf("Unable to retrieve tags: %+v", err)
+	}
+	if found := all.([]Tag); len(found) != 1 || found[0].Epc != "3014AA02" {
+		t.Errorf("expected only the tag read after the cutoff in the archive, got %+v", found)
+	}
+}
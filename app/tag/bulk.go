@@ -0,0 +1,234 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// BulkUpdateBody is the model for request body of the bulk update apis. Tags are selected either by the
+// list of EPCs, within the facility if any, or within the facility by product ID and/or EPC pattern,
+// or all the tags of the facility if explicitly requested.
+type BulkUpdateBody struct {
+	// SGTIN EPC codes of the tags to update
+	Epcs []string `json:"epcs"`
+	// Facility of the tags to update, optional for a list of EPCs
+	FacilityID string `json:"facility_id"`
+	// Update all the tags of the facility, which must be explicitly requested
+	AllInFacility bool `json:"all_in_facility"`
+	// Update the tags of this GTIN-14
+	ProductID string `json:"productId"`
+	// Update the tags matching this EPC pattern, which may contain one '*'
+	Epc string `json:"epc"`
+	// User set qualified state for the items
+	QualifiedState string `json:"qualified_state"`
+	// Customer defined context, either a plain string or a structured JSON object
+	EpcContext json.RawMessage `json:"epc_context"`
+	// Who requested the change of qualified state
+	ChangedBy string `json:"changed_by"`
+	// Why the qualified state changed
+	Reason string `json:"reason"`
+	// Version the selected tags must still be at, e.g. from an If-Match header
+	Version *int64 `json:"-"`
+}
+
+// BulkUpdateResult is the model used to return the result of a bulk update
+type BulkUpdateResult struct {
+	// Number of tags updated
+	Updated int `json:"updated"`
+	// EPCs of the list that were not found in the facility
+	NotFound []string `json:"not_found"`
+}
+
+// BulkHook is called within the transaction of a bulk update, before the update, with the selected tags locked.
+// Returning an error rolls the update back.
+type BulkHook func(transaction *sql.Tx, selected []Tag) error
+
+// BulkUpdate sets the fields of the object on all the tags selected by the body in one transaction.
+// The hook is optional.
+func BulkUpdate(dbs *sql.DB, selection BulkUpdateBody, object map[string]string, hook BulkHook) (BulkUpdateResult, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.BulkUpdate.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.BulkUpdate.Success`, nil)
+	mUpdateErr := metrics.GetOrRegisterGauge(`Inventory.BulkUpdate.Update-Error`, nil)
+	mUpdated := metrics.GetOrRegisterGauge(`Inventory.BulkUpdate.Updated`, nil)
+	mUpdateLatency := metrics.GetOrRegisterTimer(`Inventory.BulkUpdate.Update-Latency`, nil)
+
+	result := BulkUpdateResult{NotFound: []string{}}
+
+	whereClause, err := buildBulkWhereClause(selection)
+	if err != nil {
+		return result, err
+	}
+
+	// all the fields are set by one statement, instead of one per field
+	fields, err := json.Marshal(object)
+	if err != nil {
+		return result, err
+	}
+
+	updateStmt := fmt.Sprintf(`UPDATE %s SET %s = %s || %s::JSONB || %s WHERE %s RETURNING %s ->> %s;`,
+		pq.QuoteIdentifier(tagsTable),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(string(fields)),
+		nextVersion(pq.QuoteIdentifier(jsonb)),
+		whereClause,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteLiteral(epcColumn),
+	)
+
+	updateTimer := time.Now()
+	transaction, err := dbs.Begin()
+	if err != nil {
+		mUpdateErr.Update(1)
+		return result, errors.Wrap(err, "unable to begin transaction")
+	}
+
+	updatedEpcs, err := bulkUpdateInTransaction(transaction, whereClause, updateStmt, selection.Version, hook)
+	if err != nil {
+		mUpdateErr.Update(1)
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return result, errors.Wrap(rollbackErr, err.Error())
+		}
+		return result, errors.Wrap(err, "error in bulk updating tags")
+	}
+
+	if err := transaction.Commit(); err != nil {
+		mUpdateErr.Update(1)
+		return result, errors.Wrap(err, "unable to commit bulk update")
+	}
+	mUpdateLatency.Update(time.Since(updateTimer))
+
+	result.Updated = len(updatedEpcs)
+	for _, epc := range selection.Epcs {
+		if !updatedEpcs[epc] {
+			result.NotFound = append(result.NotFound, epc)
+		}
+	}
+
+	mUpdated.Update(int64(result.Updated))
+	mSuccess.Update(1)
+	return result, nil
+}
+
+func bulkUpdateInTransaction(transaction *sql.Tx, whereClause string, updateStmt string, version *int64,
+	hook BulkHook) (map[string]bool, error) {
+	if hook != nil || version != nil {
+		selectStmt := fmt.Sprintf(`SELECT %s FROM %s WHERE %s FOR UPDATE;`,
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteIdentifier(tagsTable),
+			whereClause,
+		)
+		selected, err := queryTags(transaction, selectStmt)
+		if err != nil {
+			return nil, err
+		}
+		if version != nil {
+			if err := checkVersion(selected, *version); err != nil {
+				return nil, err
+			}
+		}
+		if hook != nil {
+			if err := hook(transaction, selected); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return queryEpcs(transaction, updateStmt)
+}
+
+func queryTags(transaction *sql.Tx, statement string) ([]Tag, error) {
+	rows, err := transaction.Query(statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func queryEpcs(transaction *sql.Tx, statement string) (map[string]bool, error) {
+	rows, err := transaction.Query(statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	epcs := make(map[string]bool)
+	for rows.Next() {
+		var epc string
+		if err := rows.Scan(&epc); err != nil {
+			return nil, err
+		}
+		epcs[epc] = true
+	}
+	return epcs, rows.Err()
+}
+
+func buildBulkWhereClause(selection BulkUpdateBody) (string, error) {
+	byList := len(selection.Epcs) > 0
+	byFilter := selection.ProductID != "" || selection.Epc != ""
+	selections := 0
+	for _, selected := range []bool{byList, byFilter, selection.AllInFacility} {
+		if selected {
+			selections++
+		}
+	}
+	if selections != 1 {
+		return "", errors.Wrap(web.ErrValidation,
+			"tags must be selected either by a list of epcs, by productId and/or epc pattern, or all in the facility")
+	}
+	if !byList && selection.FacilityID == "" {
+		return "", errors.Wrap(web.ErrValidation, "facility_id is required unless tags are selected by a list of epcs")
+	}
+
+	epc := fmt.Sprintf("%s ->> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(epcColumn))
+	var conditions []string
+	if selection.FacilityID != "" {
+		conditions = append(conditions, fmt.Sprintf("%s ->> %s = %s",
+			pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(facilityColumn), pq.QuoteLiteral(selection.FacilityID)))
+	}
+
+	if byList {
+		quotedEpcs := make([]string, len(selection.Epcs))
+		for i, listed := range selection.Epcs {
+			quotedEpcs[i] = pq.QuoteLiteral(listed)
+		}
+		conditions = append(conditions, fmt.Sprintf("%s IN (%s)", epc, strings.Join(quotedEpcs, ", ")))
+	}
+	if selection.ProductID != "" {
+		conditions = append(conditions, fmt.Sprintf("%s ->> 'product_id' = %s",
+			pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(selection.ProductID)))
+	}
+	if selection.Epc != "" {
+		// the json schema only allows hex digits and at most one '*', so there is nothing else to escape
+		conditions = append(conditions, fmt.Sprintf("%s LIKE %s",
+			epc, pq.QuoteLiteral(strings.Replace(selection.Epc, "*", "%", 1))))
+	}
+
+	return strings.Join(conditions, " AND "), nil
+}
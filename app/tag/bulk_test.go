@@ -0,0 +1,99 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+func TestBulkUpdate(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", ProductID: "00000000000001", FacilityID: "store1"},
+		{Epc: "3014AA02", ProductID: "00000000000001", FacilityID: "store1"},
+		{Epc: "3014BB03", ProductID: "00000000000002", FacilityID: "store1"},
+		{Epc: "3014AA04", ProductID: "00000000000001", FacilityID: "store2"},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	// by list, in one statement for several fields
+	result, err := BulkUpdate(testDB.DB, BulkUpdateBody{FacilityID: "store1", Epcs: []string{"3014AA01", "3014AA04", "FFFF"}},
+		map[string]string{"qualified_state": "damaged", "epc_context": "shipment 7"}, nil)
+	if err != nil {
+		t.Fatalf("Unable to bulk update: %+v", err)
+	}
+	expected := BulkUpdateResult{Updated: 1, NotFound: []string{"3014AA04", "FFFF"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	updated, err := FindByEpc(testDB.DB, "3014AA01")
+	if err != nil {
+		t.Fatalf("Unable to find tag: %+v", err)
+	}
+	if updated.QualifiedState != "damaged" || updated.EpcContext != "shipment 7" || updated.ProductID != "00000000000001" {
+		t.Errorf("unexpected updated tag %+v", updated)
+	}
+
+	// by filter
+	result, err = BulkUpdate(testDB.DB, BulkUpdateBody{FacilityID: "store1", ProductID: "00000000000001", Epc: "3014AA*"},
+		map[string]string{"qualified_state": "recalled"}, nil)
+	if err != nil {
+		t.Fatalf("Unable to bulk update: %+v", err)
+	}
+	if result.Updated != 2 || len(result.NotFound) != 0 {
+		t.Errorf("expected 2 tags updated, got %+v", result)
+	}
+	untouched, err := FindByEpc(testDB.DB, "3014AA04")
+	if err != nil {
+		t.Fatalf("Unable to find tag: %+v", err)
+	}
+	if untouched.QualifiedState != "" {
+		t.Errorf("expected the tag of another facility not to be updated, got %+v", untouched)
+	}
+
+	// by list across facilities
+	result, err = BulkUpdate(testDB.DB, BulkUpdateBody{Epcs: []string{"3014AA01", "3014AA04"}},
+		map[string]string{"epc_context": "shipment 8"}, nil)
+	if err != nil {
+		t.Fatalf("Unable to bulk update: %+v", err)
+	}
+	if result.Updated != 2 || len(result.NotFound) != 0 {
+		t.Errorf("expected the listed tags of both facilities updated, got %+v", result)
+	}
+
+	// all the tags of a facility
+	result, err = BulkUpdate(testDB.DB, BulkUpdateBody{FacilityID: "store1", AllInFacility: true},
+		map[string]string{"qualified_state": "counted"}, nil)
+	if err != nil {
+		t.Fatalf("Unable to bulk update: %+v", err)
+	}
+	if result.Updated != 3 {
+		t.Errorf("expected the 3 tags of store1 updated, got %+v", result)
+	}
+
+	// ambiguous selections
+	invalidSelections := []BulkUpdateBody{
+		{FacilityID: "store1"},
+		{FacilityID: "store1", Epcs: []string{"3014AA01"}, ProductID: "00000000000001"},
+		{FacilityID: "store1", Epcs: []string{"3014AA01"}, AllInFacility: true},
+		{ProductID: "00000000000001"},
+		{AllInFacility: true},
+	}
+	for _, selection := range invalidSelections {
+		if _, err := BulkUpdate(testDB.DB, selection, map[string]string{"qualified_state": "x"}, nil); errors.Cause(err) != web.ErrValidation {
+			t.Errorf("expected validation error for %+v, got %v", selection, err)
+		}
+	}
+}
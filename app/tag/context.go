@@ -0,0 +1,71 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// ContextPathPrefix prefixes the OData fields referring to a path in the structured epc context,
+// e.g. epc_context.asnId
+const ContextPathPrefix = "epc_context."
+
+// contextObject is the structured epc context of a tag, an empty object if its context is not a JSON object.
+// It is the expression of the GIN index of the context, so that equality filters on a path use the index
+// through a containment.
+var contextObject = fmt.Sprintf("epc_context_object(%s ->> %s)", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("epc_context"))
+
+// odataTable returns the table to run the OData query against: the tags table, including the archived tags
+// if requested
+func odataTable(archived bool) string {
+	if archived {
+		return allTagsView
+	}
+	return tagsTable
+}
+
+// contextPath returns the path in the structured epc context the OData field refers to, if any
+func contextPath(field string) ([]string, bool) {
+	if !strings.HasPrefix(field, ContextPathPrefix) {
+		return nil, false
+	}
+	return strings.Split(strings.TrimPrefix(field, ContextPathPrefix), "."), true
+}
+
+// contextPathExpression returns the expression of the value at the path, as text if requested, as JSON otherwise
+func contextPathExpression(path []string, text bool) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = pq.QuoteLiteral(key)
+	}
+	operator := "#>"
+	if text {
+		operator = "#>>"
+	}
+	return fmt.Sprintf("%s %s ARRAY[%s]::TEXT[]", contextObject, operator, strings.Join(keys, ", "))
+}
+
+// contextContains returns the condition of the structured epc context holding the value at the path
+func contextContains(path []string, value interface{}) (string, error) {
+	contained := value
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == "" {
+			return "", errors.Errorf("invalid epc context path %s", strings.Join(path, "."))
+		}
+		contained = map[string]interface{}{path[i]: contained}
+	}
+	document, err := json.Marshal(contained)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s @> %s::JSONB", contextObject, pq.QuoteLiteral(string(document))), nil
+}
@@ -0,0 +1,71 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestOdataTable(t *testing.T) {
+	if table := odataTable(false); table != tagsTable {
+		t.Errorf("expected the tags table, got %s", table)
+	}
+	if table := odataTable(true); table != allTagsView {
+		t.Errorf("expected the view including the archived tags, got %s", table)
+	}
+}
+
+func TestContextContains(t *testing.T) {
+	condition, err := contextContains([]string{"carton", "id"}, "C'7")
+	if err != nil {
+		t.Fatalf("Unable to build the condition: %+v", err)
+	}
+	if expected := `epc_context_object("data" ->> 'epc_context') @> '{"carton":{"id":"C''7"}}'::JSONB`; condition != expected {
+		t.Errorf("expected %s, got %s", expected, condition)
+	}
+
+	if _, err := contextContains([]string{"carton", ""}, "C7"); err == nil {
+		t.Error("expected an empty path key to be invalid")
+	}
+}
+
+func TestRetrieveByContextPath(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcContext: `{"asnId":"123","carton":{"id":"C7"}}`},
+		{Epc: "3014AA02", FacilityID: "store1", EpcContext: `{"asnId":"456"}`},
+		{Epc: "3014AA03", FacilityID: "store1", EpcContext: "received"},
+		{Epc: "3014AA04", FacilityID: "store1", EpcContext: "{not json"},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	query := url.Values{"$filter": {"epc_context.carton.id eq 'C7' and epc_context.asnId eq '123'"}}
+	results, _, _, err := Retrieve(testDB.DB, query, 100)
+	if err != nil {
+		t.Fatalf("Unable to retrieve tags: %+v", err)
+	}
+	found := results.([]Tag)
+	if len(found) != 1 || found[0].Epc != "3014AA01" || found[0].EpcContext != tags[0].EpcContext {
+		t.Errorf("expected only the tag of ASN 123 with its context unchanged, got %+v", found)
+	}
+
+	if err := Update(testDB.DB, "3014AA03", "store1", map[string]string{"epc_context": `{"asnId":"123"}`}); err != nil {
+		t.Fatalf("Unable to update tag: %+v", err)
+	}
+	results, _, _, err = Retrieve(testDB.DB, url.Values{"$filter": {"epc_context.asnId eq '123'"}}, 100)
+	if err != nil {
+		t.Fatalf("Unable to retrieve tags: %+v", err)
+	}
+	if found := results.([]Tag); len(found) != 2 {
+		t.Errorf("expected the updated structured context to be queryable, got %+v", found)
+	}
+}
@@ -40,9 +40,11 @@ type tagsDataWrapper struct {
 	Data Tag     `db:"data" json:"data"`
 }
 
-// Retrieve retrieves tags from database based on Odata query and a size limit
+// Retrieve retrieves tags from database based on Odata query and a size limit.
+// Unless ordered by another field, tags are ordered by epc and the paging cursor of the next page is
+// returned when the page is full. The cursor is passed back in the CursorParam query parameter.
 //nolint:dupl
-func Retrieve(dbs *sql.DB, query url.Values, maxSize int) (interface{}, *CountType, error) {
+func Retrieve(dbs *sql.DB, query url.Values, maxSize int) (interface{}, *CountType, *PagingType, error) {
 
 	// Metrics
 	metrics.GetOrRegisterGauge(`Inventory.Retrieve.Attempt`, nil).Update(1)
@@ -51,20 +53,29 @@ func Retrieve(dbs *sql.DB, query url.Values, maxSize int) (interface{}, *CountTy
 	mInputErr := metrics.GetOrRegisterGauge("Inventory.Retrieve.Input-Error", nil)
 	mFindLatency := metrics.GetOrRegisterTimer(`Inventory.Retrieve.Find-Latency`, nil)
 
-	/* Cursor is not a priority for the upcoming release it will be added in future if required */
-	//var paging PagingType
+	archived, err := includeArchived(query)
+	if err != nil {
+		mInputErr.Update(1)
+		return nil, nil, nil, err
+	}
 
-	// If count is true, and only $count is set return total count of the collection
-	if len(query["$count"]) > 0 && len(query) < 2 {
+	keyset, err := applyCursor(query)
+	if err != nil {
+		mInputErr.Update(1)
+		return nil, nil, nil, err
+	}
 
-		return countHandler(dbs)
+	// If count is true, and only $count is set (besides $orderby) return total count of the collection
+	if len(query["$count"]) > 0 && len(query) < 3 {
+		count, err := countHandler(dbs, odataTable(archived))
+		return nil, count, nil, err
 	}
 
 	if len(query["$top"]) > 0 {
 
 		topVal, err := strconv.Atoi(query["$top"][0])
 		if err != nil {
-			return nil, nil, errors.Wrap(web.ErrValidation, "invalid $top value")
+			return nil, nil, nil, errors.Wrap(web.ErrValidation, "invalid $top value")
 		}
 
 		if topVal > maxSize {
@@ -78,19 +89,20 @@ func Retrieve(dbs *sql.DB, query url.Values, maxSize int) (interface{}, *CountTy
 	// Else, run filter query and return slice of Tag
 	retrieveTimer := time.Now()
 
-	rows, err := odata.ODataSQLQuery(query, tagsTable, jsonb, dbs)
+	rows, err := queryOdata(dbs, query, odataTable(archived))
 	if err != nil {
 		if errors.Cause(err) == odata.ErrInvalidInput {
 			mInputErr.Update(1)
-			return nil, nil, errors.Wrap(web.ErrInvalidInput, err.Error())
+			return nil, nil, nil, errors.Wrap(web.ErrInvalidInput, err.Error())
 		}
-		return nil, nil, errors.Wrap(err, "error in retrieving tags")
+		return nil, nil, nil, errors.Wrap(err, "error in retrieving tags")
 	}
 
 	mFindLatency.Update(time.Since(retrieveTimer))
 	defer rows.Close()
 
 	tagSlice := make([]Tag, 0)
+	top, _ := strconv.Atoi(query["$top"][0])
 
 	inlineCount := 0
 
@@ -101,7 +113,7 @@ func Retrieve(dbs *sql.DB, query url.Values, maxSize int) (interface{}, *CountTy
 		err := rows.Scan(&tagsDataWrapper.ID, &tagsDataWrapper.Data)
 		if err != nil {
 			mFindErr.Update(1)
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		tagSlice = append(tagSlice, tagsDataWrapper.Data)
 		inlineCount++
@@ -109,28 +121,29 @@ func Retrieve(dbs *sql.DB, query url.Values, maxSize int) (interface{}, *CountTy
 	}
 	if err = rows.Err(); err != nil {
 		mFindErr.Update(1)
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	// Check if inlinecount is set
 	isInlineCount := query["$inlinecount"]
 	countQuery := query["$count"]
 
+	// A full page may be followed by more tags
+	var paging *PagingType
+	if keyset && top > 0 && inlineCount == top {
+		paging = &PagingType{Cursor: encodeCursor(tagSlice[inlineCount-1].Epc)}
+	}
+
 	if len(isInlineCount) > 0 && isInlineCount[0] == "allpages" {
 		mSuccess.Update(1)
-		return tagSlice, &CountType{Count: &inlineCount}, nil
+		return tagSlice, &CountType{Count: &inlineCount}, paging, nil
 	} else if len(countQuery) > 0 {
 		mSuccess.Update(1)
-		return nil, &CountType{Count: &inlineCount}, nil
+		return nil, &CountType{Count: &inlineCount}, nil, nil
 	}
 
-	/*var pagingType *PagingType
-	if len(query["$top"]) > 0 {
-		pagingType = &paging
-	}*/
-
 	mSuccess.Update(1)
-	return tagSlice, nil, nil
+	return tagSlice, nil, paging, nil
 }
 
 // RetrieveOdataAll retrieves all tags from the database that matches the query without any size limit
@@ -142,10 +155,16 @@ func RetrieveOdataAll(dbs *sql.DB, query url.Values) ([]Tag, error) {
 	mFindErr := metrics.GetOrRegisterGauge("Inventory.RetrieveOdataAll.Find-Error", nil)
 	mFindLatency := metrics.GetOrRegisterTimer(`Inventory.RetrieveOdataAll.Find-Latency`, nil)
 
+	archived, err := includeArchived(query)
+	if err != nil {
+		mFindErr.Update(1)
+		return nil, err
+	}
+
 	// Else, run filter query and return slice of Tag
 	retrieveTimer := time.Now()
 
-	rows, err := odata.ODataSQLQuery(query, tagsTable, jsonb, dbs)
+	rows, err := queryOdata(dbs, query, odataTable(archived))
 	if err != nil {
 		if errors.Cause(err) == odata.ErrInvalidInput {
 			mFindErr.Update(1)
@@ -177,22 +196,71 @@ func RetrieveOdataAll(dbs *sql.DB, query url.Values) ([]Tag, error) {
 	return tagSlice, nil
 }
 
-func countHandler(dbs *sql.DB) (interface{}, *CountType, error) {
+// StreamOdata streams the tags matching the query without any size limit to the handler, one row at a time,
+// so that the whole result set is never held in memory. Streaming stops at the first error of the handler.
+func StreamOdata(dbs *sql.DB, query url.Values, handler func(Tag) error) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.StreamOdata.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.StreamOdata.Success`, nil)
+	mFindErr := metrics.GetOrRegisterGauge("Inventory.StreamOdata.Find-Error", nil)
+	mInputErr := metrics.GetOrRegisterGauge("Inventory.StreamOdata.Input-Error", nil)
+	mStreamLatency := metrics.GetOrRegisterTimer(`Inventory.StreamOdata.Stream-Latency`, nil)
+
+	archived, err := includeArchived(query)
+	if err != nil {
+		mInputErr.Update(1)
+		return err
+	}
+
+	streamTimer := time.Now()
+
+	rows, err := queryOdata(dbs, query, odataTable(archived))
+	if err != nil {
+		if errors.Cause(err) == odata.ErrInvalidInput {
+			mInputErr.Update(1)
+			return errors.Wrap(web.ErrInvalidInput, err.Error())
+		}
+		return errors.Wrap(err, "Error streaming tags based on odata query")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tagsDataWrapper := new(tagsDataWrapper)
+		if err := rows.Scan(&tagsDataWrapper.ID, &tagsDataWrapper.Data); err != nil {
+			mFindErr.Update(1)
+			return err
+		}
+		if err := handler(tagsDataWrapper.Data); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		mFindErr.Update(1)
+		return err
+	}
+
+	mStreamLatency.Update(time.Since(streamTimer))
+	mSuccess.Update(1)
+	return nil
+}
+
+func countHandler(dbs *sql.DB, table string) (*CountType, error) {
 
 	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Retrieve.Success`, nil)
 	mCountErr := metrics.GetOrRegisterGauge("Inventory.Retrieve.Count-Error", nil)
 
 	var count int
 
-	row := dbs.QueryRow("SELECT count(*) FROM " + tagsTable)
+	row := dbs.QueryRow("SELECT count(*) FROM " + pq.QuoteIdentifier(table))
 	err := row.Scan(&count)
 	if err != nil {
 		mCountErr.Update(1)
-		return nil, nil, err
+		return nil, err
 	}
 
 	mSuccess.Update(1)
-	return nil, &CountType{Count: &count}, nil
+	return &CountType{Count: &count}, nil
 }
 
 // Value implements driver.Valuer interfaces
@@ -247,7 +315,7 @@ func FindByEpc(dbs *sql.DB, epc string) (Tag, error) {
 	return tag, nil
 }
 
-// Replace bulk upserts tags into database
+// Replace bulk upserts tags into database, in multi-row statements of at most the configured batch size
 func Replace(dbs *sql.DB, tagData []Tag) error {
 
 	// Metrics
@@ -255,6 +323,7 @@ func Replace(dbs *sql.DB, tagData []Tag) error {
 	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Replace.Success`, nil)
 	mValidationErr := metrics.GetOrRegisterGauge(`Inventory.Replace.Validation-Error`, nil)
 	mBulkErr := metrics.GetOrRegisterGauge(`Inventory.Replace.Bulk-Error`, nil)
+	mBulkLatency := metrics.GetOrRegisterTimer(`Inventory.Replace.Bulk-Latency`, nil)
 
 	if len(tagData) == 0 {
 		return nil
@@ -265,36 +334,69 @@ func Replace(dbs *sql.DB, tagData []Tag) error {
 			mValidationErr.Update(1)
 			return errors.Wrap(web.ErrValidation, "Unable to add new tag with empty EPC code")
 		}
+	}
 
-		obj, err := json.Marshal(tag)
-		if err != nil {
-			return err
+	bulkTimer := time.Now()
+	for _, batch := range replaceBatches(tagData, config.AppConfig.ReplaceBatchSize) {
+		values := make([]string, 0, len(batch))
+		for _, tag := range batch {
+			// the version of new tags, existing tags get the one after their version in the database
+			tag.Version++
+			obj, err := json.Marshal(tag)
+			if err != nil {
+				return err
+			}
+			values = append(values, fmt.Sprintf("(%s)", pq.QuoteLiteral(string(obj))))
 		}
 
-		upsertClause := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) 
+		upsertClause := fmt.Sprintf(`INSERT INTO %s (%s) VALUES %s 
 									 ON CONFLICT (( %s  ->> %s )) 
-									 DO UPDATE SET %s = %s.%s || %s; `,
+									 DO UPDATE SET %s = %s.%s || EXCLUDED.%s || %s; `,
 			pq.QuoteIdentifier(tagsTable),
 			pq.QuoteIdentifier(jsonb),
-			pq.QuoteLiteral(string(obj)),
+			strings.Join(values, ", "),
 			pq.QuoteIdentifier(jsonb),
 			pq.QuoteLiteral(epcColumn),
 			pq.QuoteIdentifier(jsonb),
 			pq.QuoteIdentifier(tagsTable),
 			pq.QuoteIdentifier(jsonb),
-			pq.QuoteLiteral(string(obj)),
+			pq.QuoteIdentifier(jsonb),
+			nextVersion(pq.QuoteIdentifier(tagsTable)+"."+pq.QuoteIdentifier(jsonb)),
 		)
 
-		_, err = dbs.Exec(upsertClause)
-		if err != nil {
+		if _, err := dbs.Exec(upsertClause); err != nil {
 			mBulkErr.Update(1)
 			return err
 		}
 	}
+	mBulkLatency.Update(time.Since(bulkTimer))
+
 	mSuccess.Update(1)
 	return nil
 }
 
+// replaceBatches splits the tags into batches of at most batchSize tags, starting a new batch whenever
+// an epc repeats, since a single upsert cannot update the same row twice. The tags keep their order, so
+// that later tags of an epc are still merged over the earlier ones.
+func replaceBatches(tagData []Tag, batchSize int) [][]Tag {
+	if batchSize <= 0 {
+		batchSize = len(tagData)
+	}
+
+	var batches [][]Tag
+	start := 0
+	epcs := make(map[string]bool, batchSize)
+	for i, tag := range tagData {
+		if i-start == batchSize || epcs[tag.Epc] {
+			batches = append(batches, tagData[start:i])
+			start = i
+			epcs = make(map[string]bool, batchSize)
+		}
+		epcs[tag.Epc] = true
+	}
+	return append(batches, tagData[start:])
+}
+
 // Delete removes tag from database based on epc
 // nolint :dupl
 func Delete(dbs *sql.DB, epc string) error {
@@ -399,13 +501,15 @@ func Update(dbs *sql.DB, epc string, facilityId string, object map[string]string
 	mUpdateLatency := metrics.GetOrRegisterTimer(`Inventory.Update.Update-Latency`, nil)
 
 	for key, value := range object {
-		updateStmt := fmt.Sprintf(`UPDATE %s SET %s = jsonb_set(%s, '{%s}', '%s')
+		// to_jsonb escapes the value, which may itself be serialized JSON such as a structured epc context
+		updateStmt := fmt.Sprintf(`UPDATE %s SET %s = jsonb_set(%s, '{%s}', to_jsonb(%s::TEXT)) || %s
 					WHERE (%s ->> %s = %s AND %s ->> %s = %s) returning %s;`,
 			pq.QuoteIdentifier(tagsTable),
 			pq.QuoteIdentifier(jsonb),
 			pq.QuoteIdentifier(jsonb),
 			pq.QuoteIdentifier(key),
-			pq.QuoteIdentifier(value),
+			pq.QuoteLiteral(value),
+			nextVersion(pq.QuoteIdentifier(jsonb)),
 			pq.QuoteIdentifier(jsonb),
 			pq.QuoteLiteral(epcColumn),
 			pq.QuoteLiteral(epc),
@@ -71,7 +71,7 @@ func TestNoDataRetrieve(t *testing.T) {
 		t.Error("failed to parse test url")
 	}
 
-	_, _, err = Retrieve(testDB.DB, testURL.Query(), config.AppConfig.ResponseLimit)
+	_, _, _, err = Retrieve(testDB.DB, testURL.Query(), config.AppConfig.ResponseLimit)
 	if err != nil {
 		t.Error("Unable to retrieve tags")
 	}
@@ -91,7 +91,7 @@ func TestWithDataRetrieve(t *testing.T) {
 		t.Error("failed to parse test url")
 	}
 
-	tags, _, err := Retrieve(testDB.DB, testURL.Query(), config.AppConfig.ResponseLimit)
+	tags, _, _, err := Retrieve(testDB.DB, testURL.Query(), config.AppConfig.ResponseLimit)
 
 	if err != nil {
 		t.Error("Unable to retrieve tags")
@@ -108,67 +108,91 @@ func TestWithDataRetrieve(t *testing.T) {
 	}
 }
 
-/*func TestCursor(t *testing.T) {
+func TestCursor(t *testing.T) {
 
-	testDB := dbTestSetup(t)
+	testDB := dbHost.CreateDB(t)
 	defer testDB.Close()
 
 	clearAllData(t, testDB.DB)
-	insertSample(t, testDB.DB)
-
-	testURL, err := url.Parse("http://localhost/test?$top=10")
-	if err != nil {
-		t.Error("failed to parse test url")
-	}
-
-	tags, _, pagingfirst, err := Retrieve(testDB.DB, testURL.Query(), config.AppConfig.ResponseLimit)
 
-	if err != nil {
-		t.Error("Unable to retrieve tags")
-	}
+	numOfSamples := 25
+	tagSlice := make([]Tag, numOfSamples)
+	epcSlice := generateSequentialEpcs("3014", 0, int64(numOfSamples))
+	for i := 0; i < numOfSamples; i++ {
+		tagSlice[i] = Tag{Epc: epcSlice[i], FacilityID: "front"}
+	}
+	if err := Replace(testDB.DB, tagSlice); err != nil {
+		t.Fatalf("Unable to replace tags: %s", err.Error())
+	}
+
+	// walk all pages and make sure every tag is seen exactly once, in epc order
+	seen := make(map[string]bool)
+	lastEpc := ""
+	cursor := ""
+	pages := 0
+	for {
+		// the cursor must not bind to the last term of an 'or'
+		query := url.Values{"$top": {"10"}, "$filter": {"facility_id eq 'back' or facility_id eq 'front'"}}
+		if cursor != "" {
+			query.Set(CursorParam, cursor)
+		}
 
-	if pagingfirst.Cursor == "" {
-		t.Error("Cursor is empty")
-	}
+		tags, _, paging, err := Retrieve(testDB.DB, query, config.AppConfig.ResponseLimit)
+		if err != nil {
+			t.Fatalf("Unable to retrieve tags: %+v", err)
+		}
+		pages++
+
+		for _, tag := range tags.([]Tag) {
+			if seen[tag.Epc] {
+				t.Errorf("epc %s returned twice", tag.Epc)
+			}
+			if tag.Epc <= lastEpc {
+				t.Errorf("epc %s returned out of order after %s", tag.Epc, lastEpc)
+			}
+			seen[tag.Epc] = true
+			lastEpc = tag.Epc
+		}
 
-	cFirst := pagingfirst.Cursor
-	tagSlice := reflect.ValueOf(tags)
+		if paging == nil {
+			break
+		}
+		if paging.Cursor == cursor {
+			t.Fatal("cursor did not advance")
+		}
+		cursor = paging.Cursor
 
-	if tagSlice.Len() <= 0 {
-		t.Error("Unable to retrieve tags")
+		// tags inserted behind the cursor must not disturb the following pages
+		behind := []Tag{{Epc: "0000" + strconv.Itoa(pages), FacilityID: "front"}}
+		if err := Replace(testDB.DB, behind); err != nil {
+			t.Fatalf("Unable to replace tags: %s", err.Error())
+		}
 	}
 
-	// Initiating second http request to check if first sceond cursor or not same
-	insertSampleCustom(t, testDB.DB, "cursor")
-
-	cursorTestURL, err := url.Parse("http://localhost/test?$filter=_id gt '" + url.QueryEscape(cFirst) + "'&$top=10")
-	if err != nil {
-		t.Error("failed to parse test url")
+	if len(seen) != numOfSamples {
+		t.Errorf("expected %d tags, got %d", numOfSamples, len(seen))
 	}
-
-	ctags, _, pagingnext, err := Retrieve(testDB.DB, cursorTestURL.Query(), config.AppConfig.ResponseLimit)
-
-	if err != nil {
-		t.Error("Unable to retrieve tags")
-		fmt.Println(err.Error())
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
 	}
+}
 
-	if pagingnext.Cursor == "" {
-		t.Error("Cursor is empty")
-	}
+func TestCursorInvalid(t *testing.T) {
 
-	cSecond := pagingnext.Cursor
-	tagSlice2 := reflect.ValueOf(ctags)
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
 
-	if tagSlice2.Len() <= 0 {
-		t.Error("Unable to retrieve tags")
+	testCases := []url.Values{
+		{CursorParam: {"not base64!"}},
+		{CursorParam: {encodeCursor("3014")}, "$orderby": {"last_read"}},
 	}
 
-	if cFirst == cSecond {
-		t.Error("paging failed ")
+	for _, query := range testCases {
+		if _, _, _, err := Retrieve(testDB.DB, query, config.AppConfig.ResponseLimit); errors.Cause(err) != web.ErrValidation {
+			t.Errorf("expected validation error for %v, got %v", query, err)
+		}
 	}
-
-}*/
+}
 
 //nolint:dupl
 func TestRetrieveCount(t *testing.T) {
@@ -191,7 +215,7 @@ func TestRetrieveCount(t *testing.T) {
 }
 
 func retrieveCountTest(t *testing.T, testURL *url.URL, session *sql.DB) {
-	results, count, err := Retrieve(session, testURL.Query(), config.AppConfig.ResponseLimit)
+	results, count, _, err := Retrieve(session, testURL.Query(), config.AppConfig.ResponseLimit)
 	if results != nil {
 		t.Error("expecting results to be nil")
 	}
@@ -213,7 +237,7 @@ func TestRetrieveInlineCount(t *testing.T) {
 	testDB := dbHost.CreateDB(t)
 	defer testDB.Close()
 
-	results, count, err := Retrieve(testDB.DB, testURL.Query(), config.AppConfig.ResponseLimit)
+	results, count, _, err := Retrieve(testDB.DB, testURL.Query(), config.AppConfig.ResponseLimit)
 
 	if results == nil {
 		t.Error("expecting results to not be nil")
@@ -397,7 +421,7 @@ func TestRetrieveSizeLimitWithTop(t *testing.T) {
 		t.Errorf("Unable to replace tags: %s", replaceErr.Error())
 	}
 
-	results, count, err := Retrieve(testDB.DB, testURL.Query(), sizeLimit)
+	results, count, _, err := Retrieve(testDB.DB, testURL.Query(), sizeLimit)
 	if err != nil {
 		t.Errorf("Retrieve failed with error %v", err.Error())
 	}
@@ -423,7 +447,7 @@ func TestRetrieveSizeLimitInvalidTop(t *testing.T) {
 	testDB := dbHost.CreateDB(t)
 	defer testDB.Close()
 
-	_, _, err = Retrieve(testDB.DB, testURL.Query(), sizeLimit)
+	_, _, _, err = Retrieve(testDB.DB, testURL.Query(), sizeLimit)
 	if err == nil {
 		t.Errorf("Expecting an error for invalid $top value")
 	}
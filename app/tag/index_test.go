@@ -0,0 +1,101 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestQueriesUseIndexes guards against the hot tag queries falling back to full table scans.
+// The OData queries are built by the same translation as the endpoints.
+func TestQueriesUseIndexes(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "present", LastRead: 1000, ProductID: "00111111", QualifiedState: "sold",
+			EpcContext: `{"asnId":"123"}`},
+		{Epc: "3014AA02", FacilityID: "store2", EpcState: "departed", LastRead: 2000, ProductID: "00222222", QualifiedState: "unknown"},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	odataQuery := func(filter string) string {
+		query, err := odataSQL(url.Values{"$filter": {filter}}, tagsTable)
+		if err != nil {
+			t.Fatalf("Unable to translate %s: %+v", filter, err)
+		}
+		return query
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		indexes []string
+	}{
+		{"odata facility", odataQuery("facility_id eq 'store1'"), []string{"idx_tags_facility_id"}},
+		{"odata epc state", odataQuery("epc_state eq 'present'"), []string{"idx_tags_epc_state"}},
+		{"odata product", odataQuery("product_id eq '00111111'"), []string{"idx_tags_product_id"}},
+		{"odata qualified state", odataQuery("qualified_state eq 'sold'"), []string{"idx_tags_qualified_state"}},
+		{"odata epc context", odataQuery("epc_context.asnId eq '123'"), []string{"idx_tags_epc_context"}},
+		{"odata last read", odataQuery("last_read ge 1000 and last_read le 1500"), []string{"idx_tags_last_read_millis"}},
+		{"purge", fmt.Sprintf(`SELECT * FROM %s WHERE %s`, tagsTable, departedWhereClause(Cutoffs{Default: 5000})),
+			[]string{"idx_tags_epc_state", "idx_tags_last_read_millis"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := explain(testDB.DB, test.query)
+			if err != nil {
+				t.Fatalf("Unable to explain query: %+v", err)
+			}
+			if strings.Contains(plan, "Seq Scan") {
+				t.Fatalf("expected an index scan, got:\n%s", plan)
+			}
+			for _, index := range test.indexes {
+				if strings.Contains(plan, index) {
+					return
+				}
+			}
+			t.Errorf("expected one of the indexes %v to be used, got:\n%s", test.indexes, plan)
+		})
+	}
+}
+
+// explain returns the query plan, with sequential scans disabled as the test tables are too small
+// for the planner to otherwise prefer an index
+func explain(dbs *sql.DB, query string) (string, error) {
+	tx, err := dbs.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`SET LOCAL enable_seqscan = off`); err != nil {
+		return "", err
+	}
+	rows, err := tx.Query(`EXPLAIN ` + query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		plan = append(plan, line)
+	}
+	return strings.Join(plan, "\n"), rows.Err()
+}
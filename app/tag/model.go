@@ -50,6 +50,8 @@ type Tag struct {
 	Confidence float64 `json:"confidence,omitempty"` //omitempty - confidence is not stored in the db
 	// Cycle Count indicator
 	CycleCount bool `json:"-"`
+	// Incremented on every write of the tag, for optimistic concurrency
+	Version int64 `json:"version"`
 }
 
 // LocationHistory is the model to record the whereabouts history of a tag
@@ -95,6 +97,8 @@ type RequestBody struct {
 	EndTime int64 `json:"endtime"`
 	// Millisecond epoch current time
 	Time int64 `json:"time"`
+	// Millisecond epoch of a past point in time to rebuild the inventory at
+	AsOf int64 `json:"as_of"`
 	// Minimum probability items must meet
 	Confidence float64 `json:"confidence"`
 	// Cursor from previous response used to retrieve next page of results.
@@ -107,6 +111,10 @@ type RequestBody struct {
 	ProductID string `json:"productId"`
 	// SGTIN EPC code
 	Epc string `json:"epc"`
+	// Values of fields of the structured epc context, by path
+	EpcContext map[string]string `json:"epc_context"`
+	// Also search the archived tags
+	IncludeArchived bool `json:"include_archived"`
 }
 
 // doc_RequsetBody is the swagger doc model
@@ -223,7 +231,10 @@ type AdvanceShippingNotice struct {
 
 // PurgingRequest is the model for request body of the api used for purging the collection periodically
 type PurgingRequest struct {
+	// Departed tags last read more than this many days ago are purged
 	Days int `json:"days"`
+	// Only count the tags which would be purged
+	DryRun bool `json:"dry_run"`
 }
 
 const (
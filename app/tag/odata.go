@@ -0,0 +1,210 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-go-odata/parser"
+	odata "github.com/intel/rsp-sw-toolkit-im-suite-go-odata/postgresql"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// numericFields are the tag fields holding numbers. The go-odata translation compares every field as text,
+// so that last_read ge 999 would match 1000 and miss 10000; they are compared as numbers instead, using the
+// expression of their index.
+var numericFields = map[string]bool{
+	"filter_value": true,
+	"arrived":      true,
+	"last_read":    true,
+	"version":      true,
+}
+
+var odataOperators = map[string]string{
+	"eq": "=",
+	"ne": "!=",
+	"gt": ">",
+	"ge": ">=",
+	"lt": "<",
+	"le": "<=",
+}
+
+var odataFunctions = map[string]string{
+	"contains":   "%%%s%%",
+	"endswith":   "%%%s",
+	"startswith": "%s%%",
+}
+
+// queryOdata runs the OData query against the table. It selects, filters, orders and pages the tags
+// the way the go-odata translation does, except that numeric fields are compared as numbers, that the
+// fields of the structured epc context are supported, and that the parentheses of the filter are kept.
+// Invalid queries return an error caused by odata.ErrInvalidInput.
+func queryOdata(dbs *sql.DB, query url.Values, table string) (*sql.Rows, error) {
+	statement, err := odataSQL(query, table)
+	if err != nil {
+		return nil, err
+	}
+	return dbs.Query(statement)
+}
+
+// odataSQL translates the OData query into the SQL query on the table
+func odataSQL(query url.Values, table string) (string, error) {
+	queryMap, err := parser.ParseURLValues(query)
+	if err != nil {
+		return "", errors.Wrap(odata.ErrInvalidInput, err.Error())
+	}
+
+	var statement strings.Builder
+	statement.WriteString(odataSelect(queryMap))
+	fmt.Fprintf(&statement, " FROM %s", pq.QuoteIdentifier(table))
+
+	if filter, ok := queryMap[parser.Filter].(*parser.ParseNode); ok && filter != nil {
+		where, err := odataWhere(filter)
+		if err != nil {
+			return "", errors.Wrap(odata.ErrInvalidInput, err.Error())
+		}
+		fmt.Fprintf(&statement, " WHERE %s", where)
+	}
+
+	if orderBy, ok := queryMap[parser.OrderBy].([]parser.OrderItem); ok && len(orderBy) > 0 {
+		order := make([]string, len(orderBy))
+		for i, item := range orderBy {
+			order[i] = odataField(item.Field)
+			if item.Order == "desc" {
+				order[i] += " DESC"
+			}
+		}
+		fmt.Fprintf(&statement, " ORDER BY %s", strings.Join(order, ", "))
+	}
+
+	if top, ok := queryMap[parser.Top].(int); ok {
+		fmt.Fprintf(&statement, " LIMIT %d", top)
+	}
+	if skip, ok := queryMap[parser.Skip].(int); ok {
+		fmt.Fprintf(&statement, " OFFSET %d", skip)
+	}
+
+	return statement.String(), nil
+}
+
+func odataSelect(queryMap map[string]interface{}) string {
+	fields, _ := queryMap[parser.Select].([]string)
+	if len(fields) == 0 {
+		return "SELECT *"
+	}
+
+	pairs := make([]string, len(fields))
+	for i, field := range fields {
+		if path, ok := contextPath(field); ok {
+			pairs[i] = fmt.Sprintf("%s, %s", pq.QuoteLiteral(field), contextPathExpression(path, false))
+			continue
+		}
+		pairs[i] = fmt.Sprintf("%s, %s -> %s", pq.QuoteLiteral(field), pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(field))
+	}
+	return fmt.Sprintf("SELECT id, jsonb_build_object(%s) AS %s", strings.Join(pairs, ", "), pq.QuoteIdentifier(jsonb))
+}
+
+// odataWhere translates the filter tree, keeping each and/or in parentheses
+func odataWhere(node *parser.ParseNode) (string, error) {
+	operator, _ := node.Token.Value.(string)
+	if len(node.Children) != 2 {
+		return "", errors.Errorf("invalid filter near %v", node.Token.Value)
+	}
+
+	switch operator {
+	case "and", "or":
+		left, err := odataWhere(node.Children[0])
+		if err != nil {
+			return "", err
+		}
+		right, err := odataWhere(node.Children[1])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(operator), right), nil
+	}
+
+	field, ok := node.Children[0].Token.Value.(string)
+	if !ok || len(node.Children[0].Children) != 0 || len(node.Children[1].Children) != 0 {
+		return "", errors.Errorf("invalid operands of %s", operator)
+	}
+
+	if sqlOperator, ok := odataOperators[operator]; ok {
+		if numericFields[field] {
+			number, err := odataInteger(node.Children[1].Token.Value)
+			if err != nil {
+				return "", errors.Wrapf(err, "%s is a number", field)
+			}
+			return fmt.Sprintf("%s %s %d", odataField(field), sqlOperator, number), nil
+		}
+		if path, ok := contextPath(field); ok && operator == "eq" {
+			return contextContains(path, odataValue(node.Children[1].Token.Value))
+		}
+		return fmt.Sprintf("%s %s %s", odataField(field), sqlOperator,
+			pq.QuoteLiteral(odataString(node.Children[1].Token.Value))), nil
+	}
+
+	if pattern, ok := odataFunctions[operator]; ok {
+		value, ok := node.Children[1].Token.Value.(string)
+		if !ok {
+			return "", errors.Errorf("%s expects a string", operator)
+		}
+		return fmt.Sprintf("%s LIKE %s", odataField(field), pq.QuoteLiteral(fmt.Sprintf(pattern, odataString(value)))), nil
+	}
+
+	return "", errors.Errorf("unknown operator %v", node.Token.Value)
+}
+
+// odataField returns the expression of the field, the indexed number for numeric fields, the text at the path
+// for the fields of the structured epc context
+func odataField(field string) string {
+	if path, ok := contextPath(field); ok {
+		return contextPathExpression(path, true)
+	}
+	if numericFields[field] {
+		return fmt.Sprintf("(%s ->> %s)::BIGINT", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(field))
+	}
+	return fmt.Sprintf("%s ->> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(field))
+}
+
+// odataString returns the value of an OData literal, unquoting strings
+func odataString(value interface{}) string {
+	text, ok := value.(string)
+	if !ok {
+		return fmt.Sprintf("%v", value)
+	}
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1)
+	}
+	return text
+}
+
+// odataValue returns the value of an OData literal with its type, unquoting strings
+func odataValue(value interface{}) interface{} {
+	switch value.(type) {
+	case int, float64, bool:
+		return value
+	}
+	return odataString(value)
+}
+
+// odataInteger returns the value of an OData integer, quoted or not
+func odataInteger(value interface{}) (int64, error) {
+	if number, ok := value.(int); ok {
+		return int64(number), nil
+	}
+	number, err := strconv.ParseInt(odataString(value), 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid integer %v", value)
+	}
+	return number, nil
+}
@@ -0,0 +1,78 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"net/url"
+	"testing"
+
+	odata "github.com/intel/rsp-sw-toolkit-im-suite-go-odata/postgresql"
+	"github.com/pkg/errors"
+)
+
+func TestOdataSQL(t *testing.T) {
+	tests := []struct {
+		query    url.Values
+		expected string
+	}{
+		{url.Values{}, `SELECT * FROM "tags"`},
+		{url.Values{"$filter": {"last_read ge 1000"}},
+			`SELECT * FROM "tags" WHERE ("data" ->> 'last_read')::BIGINT >= 1000`},
+		{url.Values{"$filter": {"version eq '3'"}},
+			`SELECT * FROM "tags" WHERE ("data" ->> 'version')::BIGINT = 3`},
+		{url.Values{"$filter": {"product_id eq 'it''s' and (facility_id eq 'a' or facility_id eq 'b')"}},
+			`SELECT * FROM "tags" WHERE ("data" ->> 'product_id' = 'it''s' AND ("data" ->> 'facility_id' = 'a' OR "data" ->> 'facility_id' = 'b'))`},
+		{url.Values{"$filter": {"epc_context.carton.id eq 'C7' and epc_context.quantity gt '5'"}},
+			`SELECT * FROM "tags" WHERE (epc_context_object("data" ->> 'epc_context') @> '{"carton":{"id":"C7"}}'::JSONB AND ` +
+				`epc_context_object("data" ->> 'epc_context') #>> ARRAY['quantity']::TEXT[] > '5')`},
+		{url.Values{"$filter": {"startswith(epc, '3014')"}},
+			`SELECT * FROM "tags" WHERE "data" ->> 'epc' LIKE '3014%'`},
+		{url.Values{"$select": {"epc,last_read"}, "$orderby": {"last_read desc,epc"}, "$top": {"10"}, "$skip": {"20"}},
+			`SELECT id, jsonb_build_object('epc', "data" -> 'epc', 'last_read', "data" -> 'last_read') AS "data" FROM "tags"` +
+				` ORDER BY ("data" ->> 'last_read')::BIGINT DESC, "data" ->> 'epc' LIMIT 10 OFFSET 20`},
+	}
+
+	for _, test := range tests {
+		actual, err := odataSQL(test.query, tagsTable)
+		if err != nil {
+			t.Errorf("Unable to translate %v: %+v", test.query, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+
+	for _, filter := range []string{"last_read ge 'yesterday'", "last_read ge 1.5", "epc eqs '3014'", "(epc eq )"} {
+		if _, err := odataSQL(url.Values{"$filter": {filter}}, tagsTable); errors.Cause(err) != odata.ErrInvalidInput {
+			t.Errorf("expected invalid input for %s, got %v", filter, err)
+		}
+	}
+}
+
+func TestRetrieveComparesNumbers(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", LastRead: 900},
+		{Epc: "3014AA02", FacilityID: "store1", LastRead: 1000},
+		{Epc: "3014AA03", FacilityID: "store1", LastRead: 10000},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	results, _, _, err := Retrieve(testDB.DB, url.Values{"$filter": {"last_read ge 999"}}, 100)
+	if err != nil {
+		t.Fatalf("Unable to retrieve tags: %+v", err)
+	}
+	found := results.([]Tag)
+	if len(found) != 2 || found[0].Epc != "3014AA02" || found[1].Epc != "3014AA03" {
+		t.Errorf("expected the tags read at 1000 and 10000, got %+v", found)
+	}
+}
@@ -0,0 +1,84 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-go-odata/parser"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+// CursorParam is the query parameter holding the cursor returned in paging.cursor of the previous page
+const CursorParam = "cursor"
+
+// applyCursor prepares the query for keyset pagination on the epc, which is unique and indexed:
+// results are ordered by epc, and when a cursor is provided, only epcs after it are returned.
+// It reports whether the results are keyset ordered, in which case a next cursor can be returned.
+func applyCursor(query url.Values) (bool, error) {
+	cursor := query.Get(CursorParam)
+	delete(query, CursorParam)
+
+	if orderBy := strings.TrimSpace(query.Get(parser.OrderBy)); orderBy != "" && orderBy != epcColumn && orderBy != epcColumn+" asc" {
+		if cursor != "" {
+			return false, errors.Wrap(web.ErrValidation, "cursor cannot be combined with $orderby")
+		}
+		return false, nil
+	}
+	query.Set(parser.OrderBy, epcColumn)
+
+	// the epc is needed to build the next cursor
+	if selectQuery := query.Get(parser.Select); selectQuery != "" && !selectsField(selectQuery, epcColumn) {
+		query.Set(parser.Select, selectQuery+","+epcColumn)
+	}
+
+	if cursor == "" {
+		return true, nil
+	}
+
+	lastEpc, err := decodeCursor(cursor)
+	if err != nil {
+		return false, err
+	}
+
+	cursorFilter := epcColumn + " gt '" + lastEpc + "'"
+	filter := query.Get(parser.Filter)
+	if filter == "" {
+		query.Set(parser.Filter, cursorFilter)
+		return true, nil
+	}
+
+	// the filter is parenthesized so that its own 'or' do not take precedence over the cursor
+	query.Set(parser.Filter, "("+filter+") and "+cursorFilter)
+
+	return true, nil
+}
+
+func selectsField(selectQuery string, field string) bool {
+	for _, selected := range strings.Split(selectQuery, ",") {
+		if strings.TrimSpace(selected) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCursor returns the opaque cursor pointing after the epc
+func encodeCursor(epc string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(epc))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	epc, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(epc) == 0 || strings.ContainsAny(string(epc), "' ") {
+		return "", errors.Wrap(web.ErrValidation, "invalid cursor")
+	}
+	return string(epc), nil
+}
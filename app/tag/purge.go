@@ -0,0 +1,197 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// departedState is the epc_state of the tags which can be purged or archived
+const departedState = "departed"
+
+// PurgeResult is the model used to return the result of a purge
+type PurgeResult struct {
+	// Number of tags purged, or which would be purged in a dry run
+	Count int64 `json:"count"`
+	// True if no tag was purged
+	DryRun bool `json:"dry_run"`
+}
+
+// PurgeCutoff returns the millisecond epoch before which tags are purged when keeping the given number of days
+func PurgeCutoff(now int64, days int) int64 {
+	return now - int64(days)*int64(24*time.Hour/time.Millisecond)
+}
+
+// Cutoffs are the millisecond epochs before which departed tags are purged or archived, by facility, as the
+// days of each facility start at its local business day boundary. Tags of the other facilities use the default.
+type Cutoffs struct {
+	Default    int64
+	Facilities map[string]int64
+}
+
+// Purge deletes the departed tags last read before their cutoff. Tags are deleted in batches of batchSize,
+// each in its own transaction, so that the table is never locked for long. The journal, if any, records
+// the deleted tags in the transaction of their batch.
+func Purge(dbs *sql.DB, cutoffs Cutoffs, batchSize int, journal Journal) (int64, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Purge.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Purge.Success`, nil)
+	mDeleteErr := metrics.GetOrRegisterGauge(`Inventory.Purge.Delete-Error`, nil)
+	mPurged := metrics.GetOrRegisterGauge(`Inventory.Purge.Purged`, nil)
+	mPurgeLatency := metrics.GetOrRegisterTimer(`Inventory.Purge.Purge-Latency`, nil)
+
+	if batchSize < 1 {
+		return 0, errors.Errorf("invalid purge batch size %d", batchSize)
+	}
+
+	purgeTimer := time.Now()
+	purged, err := purgeTable(dbs, tagsTable, cutoffs, batchSize, journal)
+	if err != nil {
+		mDeleteErr.Update(1)
+		return purged, errors.Wrap(err, "error in purging tags")
+	}
+	mPurgeLatency.Update(time.Since(purgeTimer))
+
+	mPurged.Update(purged)
+	mSuccess.Update(1)
+	return purged, nil
+}
+
+// PurgeArchive deletes the archived tags last read before their cutoff, in batches of batchSize like Purge.
+// They were journaled as removed when archived, so nothing is journaled.
+func PurgeArchive(dbs *sql.DB, cutoffs Cutoffs, batchSize int) (int64, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.PurgeArchive.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.PurgeArchive.Success`, nil)
+	mDeleteErr := metrics.GetOrRegisterGauge(`Inventory.PurgeArchive.Delete-Error`, nil)
+	mPurged := metrics.GetOrRegisterGauge(`Inventory.PurgeArchive.Purged`, nil)
+	mPurgeLatency := metrics.GetOrRegisterTimer(`Inventory.PurgeArchive.Purge-Latency`, nil)
+
+	if batchSize < 1 {
+		return 0, errors.Errorf("invalid purge batch size %d", batchSize)
+	}
+
+	purgeTimer := time.Now()
+	purged, err := purgeTable(dbs, archiveTable, cutoffs, batchSize, nil)
+	if err != nil {
+		mDeleteErr.Update(1)
+		return purged, errors.Wrap(err, "error in purging archived tags")
+	}
+	mPurgeLatency.Update(time.Since(purgeTimer))
+
+	mPurged.Update(purged)
+	mSuccess.Update(1)
+	return purged, nil
+}
+
+// purgeTable deletes the departed tags of the table last read before their cutoff, in batches of batchSize
+func purgeTable(dbs *sql.DB, table string, cutoffs Cutoffs, batchSize int, journal Journal) (int64, error) {
+	deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE %s LIMIT %d) RETURNING %s;`,
+		pq.QuoteIdentifier(table),
+		pq.QuoteIdentifier(table),
+		departedWhereClause(cutoffs),
+		batchSize,
+		pq.QuoteIdentifier(jsonb),
+	)
+
+	var purged int64
+	for {
+		deleted, err := removeInTransaction(dbs, deleteStmt, journal)
+		if err != nil {
+			return purged, err
+		}
+		purged += deleted
+		if deleted < int64(batchSize) {
+			return purged, nil
+		}
+	}
+}
+
+// removeInTransaction runs the statement removing tags and returning their data in a transaction, in which
+// the journal, if any, records the removed tags, and returns the number of removed tags
+func removeInTransaction(dbs *sql.DB, statement string, journal Journal) (int64, error) {
+	transaction, err := dbs.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to begin transaction")
+	}
+
+	removed, err := queryTags(transaction, statement)
+	if err == nil && journal != nil && len(removed) > 0 {
+		err = journal(transaction, removed)
+	}
+	if err != nil {
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return 0, errors.Wrap(rollbackErr, err.Error())
+		}
+		return 0, err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return 0, errors.Wrap(err, "unable to commit transaction")
+	}
+	return int64(len(removed)), nil
+}
+
+// CountPurgeable counts the departed tags last read before their cutoff, which Purge would delete
+func CountPurgeable(dbs *sql.DB, cutoffs Cutoffs) (int64, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.CountPurgeable.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.CountPurgeable.Success`, nil)
+	mCountErr := metrics.GetOrRegisterGauge(`Inventory.CountPurgeable.Count-Error`, nil)
+
+	countQuery := fmt.Sprintf(`SELECT count(*) FROM %s WHERE %s;`,
+		pq.QuoteIdentifier(tagsTable),
+		departedWhereClause(cutoffs),
+	)
+
+	var count int64
+	if err := dbs.QueryRow(countQuery).Scan(&count); err != nil {
+		mCountErr.Update(1)
+		return 0, errors.Wrap(err, "error in counting purgeable tags")
+	}
+
+	mSuccess.Update(1)
+	return count, nil
+}
+
+// departedWhereClause selects the departed tags last read before their cutoff, the last read being the ttl of a tag
+func departedWhereClause(cutoffs Cutoffs) string {
+	lastRead := fmt.Sprintf(`(%s ->> 'last_read')::BIGINT`, pq.QuoteIdentifier(jsonb))
+	departed := fmt.Sprintf(`%s ->> 'epc_state' = %s`, pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(departedState))
+	if len(cutoffs.Facilities) == 0 {
+		return fmt.Sprintf(`%s AND %s < %d`, departed, lastRead, cutoffs.Default)
+	}
+
+	facilityID := fmt.Sprintf(`COALESCE(%s ->> %s, '')`, pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(facilityColumn))
+	facilities := make([]string, 0, len(cutoffs.Facilities))
+	for facility := range cutoffs.Facilities {
+		facilities = append(facilities, facility)
+	}
+	sort.Strings(facilities)
+
+	var conditions, quotedFacilities []string
+	for _, facility := range facilities {
+		conditions = append(conditions, fmt.Sprintf(`(%s = %s AND %s < %d)`,
+			facilityID, pq.QuoteLiteral(facility), lastRead, cutoffs.Facilities[facility]))
+		quotedFacilities = append(quotedFacilities, pq.QuoteLiteral(facility))
+	}
+	conditions = append(conditions, fmt.Sprintf(`(%s NOT IN (%s) AND %s < %d)`,
+		facilityID, strings.Join(quotedFacilities, ", "), lastRead, cutoffs.Default))
+
+	return fmt.Sprintf(`%s AND (%s)`, departed, strings.Join(conditions, " OR "))
+}
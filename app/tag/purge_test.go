@@ -0,0 +1,83 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"testing"
+)
+
+func TestPurgeCutoff(t *testing.T) {
+	if cutoff := PurgeCutoff(3*86400000, 2); cutoff != 86400000 {
+		t.Errorf("expected the cutoff 2 days before now, got %d", cutoff)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "departed", LastRead: 1000},
+		{Epc: "3014AA02", FacilityID: "store1", EpcState: "departed", LastRead: 2000},
+		{Epc: "3014AA03", FacilityID: "store1", EpcState: "departed", LastRead: 3000},
+		{Epc: "3014AA04", FacilityID: "store1", EpcState: "present", LastRead: 1000},
+		{Epc: "3014AA05", FacilityID: "store1", EpcState: "departed", LastRead: 9000},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	count, err := CountPurgeable(testDB.DB, Cutoffs{Default: 5000})
+	if err != nil {
+		t.Fatalf("Unable to count purgeable tags: %+v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 purgeable tags, got %d", count)
+	}
+
+	// a batch size smaller than the purgeable tags takes several batches
+	purged, err := Purge(testDB.DB, Cutoffs{Default: 5000}, 2, nil)
+	if err != nil {
+		t.Fatalf("Unable to purge tags: %+v", err)
+	}
+	if purged != 3 {
+		t.Errorf("expected 3 purged tags, got %d", purged)
+	}
+
+	for _, kept := range []string{"3014AA04", "3014AA05"} {
+		found, err := FindByEpc(testDB.DB, kept)
+		if err != nil {
+			t.Fatalf("Unable to find tag: %+v", err)
+		}
+		if found.Epc != kept {
+			t.Errorf("expected %s to be kept", kept)
+		}
+	}
+}
+
+func TestPurgeFacilityCutoffs(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "departed", LastRead: 3000},
+		{Epc: "3014AA02", FacilityID: "store2", EpcState: "departed", LastRead: 3000},
+		{Epc: "3014AA03", EpcState: "departed", LastRead: 3000},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	// store1 keeps more of its tags, as its business day started earlier
+	count, err := CountPurgeable(testDB.DB, Cutoffs{Default: 5000, Facilities: map[string]int64{"store1": 2000}})
+	if err != nil {
+		t.Fatalf("Unable to count purgeable tags: %+v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected the tags of store2 and without facility to be purgeable, got %d", count)
+	}
+}
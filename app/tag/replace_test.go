@@ -0,0 +1,127 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestReplaceBatches(t *testing.T) {
+	tags := []Tag{{Epc: "A"}, {Epc: "B"}, {Epc: "C"}, {Epc: "A"}, {Epc: "D"}}
+
+	tests := []struct {
+		batchSize int
+		expected  []int
+	}{
+		{2, []int{2, 2, 1}},
+		{3, []int{3, 2}},
+		// the repeated epc starts a new batch
+		{10, []int{3, 2}},
+		{0, []int{3, 2}},
+	}
+
+	for _, test := range tests {
+		batches := replaceBatches(tags, test.batchSize)
+		sizes := make([]int, len(batches))
+		total := 0
+		for i, batch := range batches {
+			sizes[i] = len(batch)
+			total += len(batch)
+		}
+		if fmt.Sprint(sizes) != fmt.Sprint(test.expected) {
+			t.Errorf("batch size %d: expected batches of %v, got %v", test.batchSize, test.expected, sizes)
+		}
+		if total != len(tags) {
+			t.Errorf("batch size %d: expected all %d tags to be batched, got %d", test.batchSize, len(tags), total)
+		}
+	}
+}
+
+func TestReplaceRepeatedEpc(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "present", LastRead: 1000},
+		{Epc: "3014AA02", FacilityID: "store1", EpcState: "present", LastRead: 1000},
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "departed", LastRead: 2000},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	found, err := FindByEpc(testDB.DB, "3014AA01")
+	if err != nil {
+		t.Fatalf("Unable to find tag: %+v", err)
+	}
+	if found.EpcState != "departed" || found.LastRead != 2000 {
+		t.Errorf("expected the last tag of the epc to win, got %+v", found)
+	}
+}
+
+// BenchmarkReplace compares the batched upserts to upserting the tags one by one, as Replace used to
+func BenchmarkReplace(b *testing.B) {
+	testDB := dbHost.CreateDB(b)
+	defer testDB.Close()
+
+	for _, size := range []int{100, 1000, 10000} {
+		tags := make([]Tag, size)
+		for i := range tags {
+			tags[i] = Tag{Epc: fmt.Sprintf("3014%020X", i), FacilityID: "store1", EpcState: "present", LastRead: int64(i)}
+		}
+
+		b.Run(fmt.Sprintf("Batched-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := Replace(testDB.DB, tags); err != nil {
+					b.Fatalf("Unable to replace tags: %+v", err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("OneByOne-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := replaceOneByOne(testDB.DB, tags); err != nil {
+					b.Fatalf("Unable to replace tags: %+v", err)
+				}
+			}
+		})
+	}
+}
+
+// replaceOneByOne is the former implementation of Replace, kept as the benchmark baseline
+func replaceOneByOne(dbs *sql.DB, tagData []Tag) error {
+	for _, tag := range tagData {
+		obj, err := json.Marshal(tag)
+		if err != nil {
+			return err
+		}
+
+		upsertClause := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)
+									 ON CONFLICT (( %s  ->> %s ))
+									 DO UPDATE SET %s = %s.%s || %s; `,
+			pq.QuoteIdentifier(tagsTable),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(string(obj)),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(epcColumn),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteIdentifier(tagsTable),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(string(obj)),
+		)
+
+		if _, err = dbs.Exec(upsertClause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// ResetRequest is the model of the request body to reset the tags of a facility
+type ResetRequest struct {
+	// Facility of the tags to reset
+	FacilityID string `json:"facility_id"`
+	// Only reset the tags last read by these sensors
+	Sensors []string `json:"sensors"`
+	// Only count the tags which would be reset
+	DryRun bool `json:"dry_run"`
+}
+
+// ResetResult is the model used to return the result of a reset
+type ResetResult struct {
+	// Number of tags deleted from the database, or which would be deleted in a dry run
+	Deleted int64 `json:"deleted"`
+	// Number of tags forgotten by the tag processor, or which would be forgotten in a dry run
+	Forgotten int `json:"forgotten"`
+	// True if no tag was reset
+	DryRun bool `json:"dry_run"`
+}
+
+// ResetScope selects the tags of a facility to reset, optionally only the tags last read by some sensors
+type ResetScope struct {
+	// Facility of the tags
+	FacilityID string
+	// Device ids of the sensors, whose default antenna aliases are prefixed by the device id
+	Sensors []string
+	// Antenna aliases configured on the sensors
+	Aliases []string
+}
+
+// Reset deletes the tags in the scope, or only counts them on a dry run. The journal, if any, records the
+// deleted tags in the transaction of the deletion.
+func Reset(dbs *sql.DB, scope ResetScope, dryRun bool, journal Journal) (int64, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Reset.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Reset.Success`, nil)
+	mResetErr := metrics.GetOrRegisterGauge(`Inventory.Reset.Reset-Error`, nil)
+	mDeleted := metrics.GetOrRegisterGauge(`Inventory.Reset.Deleted`, nil)
+	mResetLatency := metrics.GetOrRegisterTimer(`Inventory.Reset.Reset-Latency`, nil)
+
+	whereClause, err := resetWhereClause(scope)
+	if err != nil {
+		return 0, err
+	}
+
+	resetTimer := time.Now()
+	if dryRun {
+		countQuery := fmt.Sprintf(`SELECT count(*) FROM %s WHERE %s;`, pq.QuoteIdentifier(tagsTable), whereClause)
+		var count int64
+		if err := dbs.QueryRow(countQuery).Scan(&count); err != nil {
+			mResetErr.Update(1)
+			return 0, errors.Wrap(err, "error in counting tags to reset")
+		}
+		mSuccess.Update(1)
+		return count, nil
+	}
+
+	deleteStmt := fmt.Sprintf(`DELETE FROM %s WHERE %s RETURNING %s;`,
+		pq.QuoteIdentifier(tagsTable), whereClause, pq.QuoteIdentifier(jsonb))
+	deleted, err := removeInTransaction(dbs, deleteStmt, journal)
+	if err != nil {
+		mResetErr.Update(1)
+		return 0, errors.Wrap(err, "error in resetting tags")
+	}
+	mResetLatency.Update(time.Since(resetTimer))
+
+	mDeleted.Update(deleted)
+	mSuccess.Update(1)
+	return deleted, nil
+}
+
+// resetWhereClause selects the tags of the facility, and of the sensors if any, by the location the tags were
+// last read at
+func resetWhereClause(scope ResetScope) (string, error) {
+	if scope.FacilityID == "" {
+		return "", errors.Wrap(web.ErrValidation, "facility_id is required")
+	}
+
+	whereClause := fmt.Sprintf("%s ->> %s = %s",
+		pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(facilityColumn), pq.QuoteLiteral(scope.FacilityID))
+	if len(scope.Sensors) == 0 {
+		return whereClause, nil
+	}
+
+	location := fmt.Sprintf("%s -> 'location_history' -> 0 ->> 'location'", pq.QuoteIdentifier(jsonb))
+	var locations []string
+	for _, sensor := range scope.Sensors {
+		// default aliases are the device id followed by the antenna port, e.g. RSP-150009-0
+		prefix := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(sensor) + "-%"
+		locations = append(locations, fmt.Sprintf("%s LIKE %s", location, pq.QuoteLiteral(prefix)))
+	}
+	if len(scope.Aliases) > 0 {
+		quotedAliases := make([]string, len(scope.Aliases))
+		for i, alias := range scope.Aliases {
+			quotedAliases[i] = pq.QuoteLiteral(alias)
+		}
+		locations = append(locations, fmt.Sprintf("%s IN (%s)", location, strings.Join(quotedAliases, ", ")))
+	}
+
+	return fmt.Sprintf("%s AND (%s)", whereClause, strings.Join(locations, " OR ")), nil
+}
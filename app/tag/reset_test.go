@@ -0,0 +1,72 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+func TestResetWhereClause(t *testing.T) {
+	if _, err := resetWhereClause(ResetScope{Sensors: []string{"RSP-150009"}}); errors.Cause(err) != web.ErrValidation {
+		t.Errorf("expected a validation error without a facility, got %v", err)
+	}
+
+	whereClause, err := resetWhereClause(ResetScope{
+		FacilityID: "store1",
+		Sensors:    []string{"RSP_150009"},
+		Aliases:    []string{"Front"},
+	})
+	if err != nil {
+		t.Fatalf("Unable to build where clause: %+v", err)
+	}
+	for _, expected := range []string{`'facility_id' = 'store1'`, `LIKE 'RSP\_150009-%'`, `IN ('Front')`} {
+		if !strings.Contains(whereClause, expected) {
+			t.Errorf("expected %s in the where clause %s", expected, whereClause)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", LocationHistory: []LocationHistory{{Location: "RSP-150009-0"}}},
+		{Epc: "3014AA02", FacilityID: "store1", LocationHistory: []LocationHistory{{Location: "RSP-150010-0"}}},
+		{Epc: "3014AA03", FacilityID: "store2", LocationHistory: []LocationHistory{{Location: "RSP-150009-0"}}},
+	}
+	if err := Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+
+	scope := ResetScope{FacilityID: "store1", Sensors: []string{"RSP-150009"}}
+	count, err := Reset(testDB.DB, scope, true, nil)
+	if err != nil {
+		t.Fatalf("Unable to count tags to reset: %+v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 tag to be reset on a dry run, got %d", count)
+	}
+	if found, _ := FindByEpc(testDB.DB, "3014AA01"); found.Epc == "" {
+		t.Error("expected the dry run to keep the tag")
+	}
+
+	deleted, err := Reset(testDB.DB, ResetScope{FacilityID: "store1"}, false, nil)
+	if err != nil {
+		t.Fatalf("Unable to reset tags: %+v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected the 2 tags of the facility to be deleted, got %d", deleted)
+	}
+	if found, _ := FindByEpc(testDB.DB, "3014AA03"); found.Epc == "" {
+		t.Error("expected the tag of the other facility to be kept")
+	}
+}
@@ -0,0 +1,175 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const versionColumn = "version"
+
+// Journal records a change of the tags in the transaction of the change, so that the journal and the tags
+// never disagree
+type Journal func(transaction *sql.Tx, tags []Tag) error
+
+// ReplaceIfUnchanged upserts the tags like Replace, but only if none of them was written since it was read,
+// i.e. the version in the database is still the version of the tag. Either all the tags are written, or none
+// and the error is caused by web.ErrPreconditionFailed, in which case the tags should be read again.
+// The journal, if any, records the written tags in the same transaction.
+// The versions of the tags are updated to the written versions.
+func ReplaceIfUnchanged(dbs *sql.DB, tagData []Tag, journal Journal) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.ReplaceIfUnchanged.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.ReplaceIfUnchanged.Success`, nil)
+	mValidationErr := metrics.GetOrRegisterGauge(`Inventory.ReplaceIfUnchanged.Validation-Error`, nil)
+	mConflict := metrics.GetOrRegisterGauge(`Inventory.ReplaceIfUnchanged.Conflict`, nil)
+	mBulkErr := metrics.GetOrRegisterGauge(`Inventory.ReplaceIfUnchanged.Bulk-Error`, nil)
+	mJournalErr := metrics.GetOrRegisterGauge(`Inventory.ReplaceIfUnchanged.Journal-Error`, nil)
+	mBulkLatency := metrics.GetOrRegisterTimer(`Inventory.ReplaceIfUnchanged.Bulk-Latency`, nil)
+
+	if len(tagData) == 0 {
+		return nil
+	}
+
+	// a later tag of an epc follows the earlier one of the same call, rather than conflicting with it
+	versions := make(map[string]int64, len(tagData))
+	written := make([]Tag, len(tagData))
+	for i, tag := range tagData {
+		if tag.Epc == "" {
+			mValidationErr.Update(1)
+			return errors.Wrap(web.ErrValidation, "Unable to add new tag with empty EPC code")
+		}
+		if version, found := versions[tag.Epc]; found {
+			tag.Version = version
+		}
+		tag.Version++
+		versions[tag.Epc] = tag.Version
+		written[i] = tag
+	}
+
+	bulkTimer := time.Now()
+	transaction, err := dbs.Begin()
+	if err != nil {
+		mBulkErr.Update(1)
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+
+	conflicts, err := replaceIfUnchangedInTransaction(transaction, written)
+	if err == nil && len(conflicts) > 0 {
+		mConflict.Update(1)
+		err = errors.Wrapf(web.ErrPreconditionFailed, "tags modified concurrently: %s", strings.Join(conflicts, ", "))
+	}
+	if err == nil && journal != nil {
+		if err = journal(transaction, written); err != nil {
+			mJournalErr.Update(1)
+			err = errors.Wrap(err, "unable to journal tags")
+		}
+	}
+	if err != nil {
+		if errors.Cause(err) != web.ErrPreconditionFailed {
+			mBulkErr.Update(1)
+		}
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return errors.Wrap(rollbackErr, err.Error())
+		}
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		mBulkErr.Update(1)
+		return errors.Wrap(err, "unable to commit tags")
+	}
+	mBulkLatency.Update(time.Since(bulkTimer))
+
+	for i := range tagData {
+		tagData[i].Version = written[i].Version
+	}
+
+	mSuccess.Update(1)
+	return nil
+}
+
+// replaceIfUnchangedInTransaction upserts the tags, which already have their new version, and returns the epcs
+// of the tags whose version in the database is not the one before
+func replaceIfUnchangedInTransaction(transaction *sql.Tx, tagData []Tag) ([]string, error) {
+	var conflicts []string
+	for _, batch := range replaceBatches(tagData, config.AppConfig.ReplaceBatchSize) {
+		values := make([]string, 0, len(batch))
+		for _, tag := range batch {
+			obj, err := json.Marshal(tag)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, fmt.Sprintf("(%s)", pq.QuoteLiteral(string(obj))))
+		}
+
+		upsertClause := fmt.Sprintf(`INSERT INTO %s (%s) VALUES %s
+									 ON CONFLICT (( %s  ->> %s ))
+									 DO UPDATE SET %s = %s.%s || EXCLUDED.%s
+									 WHERE COALESCE((%s.%s ->> %s)::BIGINT, 0) = (EXCLUDED.%s ->> %s)::BIGINT - 1
+									 RETURNING %s ->> %s;`,
+			pq.QuoteIdentifier(tagsTable),
+			pq.QuoteIdentifier(jsonb),
+			strings.Join(values, ", "),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(epcColumn),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteIdentifier(tagsTable),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteIdentifier(tagsTable),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(versionColumn),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(versionColumn),
+			pq.QuoteIdentifier(jsonb),
+			pq.QuoteLiteral(epcColumn),
+		)
+
+		upserted, err := queryEpcs(transaction, upsertClause)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range batch {
+			if !upserted[tag.Epc] {
+				conflicts = append(conflicts, tag.Epc)
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// checkVersion returns an error caused by web.ErrPreconditionFailed if any of the tags is not at the version
+func checkVersion(tags []Tag, version int64) error {
+	for _, tag := range tags {
+		if tag.Version != version {
+			return errors.Wrapf(web.ErrPreconditionFailed, "tag %s is at version %d, not %d", tag.Epc, tag.Version, version)
+		}
+	}
+	return nil
+}
+
+// nextVersion is the JSONB object setting the version of a tag to the one after its version in the column,
+// to be merged into the data of the tags by every write
+func nextVersion(column string) string {
+	return fmt.Sprintf(`jsonb_build_object(%s, COALESCE((%s ->> %s)::BIGINT, 0) + 1)`,
+		pq.QuoteLiteral(versionColumn),
+		column,
+		pq.QuoteLiteral(versionColumn),
+	)
+}
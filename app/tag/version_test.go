@@ -0,0 +1,103 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tag
+
+import (
+	"testing"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/pkg/errors"
+)
+
+func TestCheckVersion(t *testing.T) {
+	tags := []Tag{{Epc: "3014AA01", Version: 2}, {Epc: "3014AA02", Version: 2}}
+	if err := checkVersion(tags, 2); err != nil {
+		t.Errorf("expected the tags to be at version 2: %v", err)
+	}
+
+	tags[1].Version = 3
+	if err := checkVersion(tags, 2); errors.Cause(err) != web.ErrPreconditionFailed {
+		t.Errorf("expected a failed precondition, got %v", err)
+	}
+}
+
+func TestReplaceIfUnchanged(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", QualifiedState: "unknown"},
+		{Epc: "3014AA02", FacilityID: "store1", QualifiedState: "unknown"},
+	}
+	if err := ReplaceIfUnchanged(testDB.DB, tags, nil); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+	if tags[0].Version != 1 || tags[1].Version != 1 {
+		t.Fatalf("expected new tags at version 1, got %d and %d", tags[0].Version, tags[1].Version)
+	}
+
+	// a concurrent update, e.g. from the REST api, between reading the tags and writing them back
+	if _, err := BulkUpdate(testDB.DB, BulkUpdateBody{Epcs: []string{"3014AA02"}, FacilityID: "store1"},
+		map[string]string{"qualified_state": "sold"}, nil); err != nil {
+		t.Fatalf("Unable to update tag: %+v", err)
+	}
+
+	tags[0].LastRead = 1000
+	tags[1].LastRead = 1000
+	if err := ReplaceIfUnchanged(testDB.DB, tags, nil); errors.Cause(err) != web.ErrPreconditionFailed {
+		t.Fatalf("expected a failed precondition, got %+v", err)
+	}
+
+	// none of the tags was written
+	for _, epc := range []string{"3014AA01", "3014AA02"} {
+		found, err := FindByEpc(testDB.DB, epc)
+		if err != nil {
+			t.Fatalf("Unable to find tag: %+v", err)
+		}
+		if found.LastRead != 0 {
+			t.Errorf("expected %s not to be written", epc)
+		}
+	}
+
+	// writing the tags read again keeps the concurrent update
+	reread, err := FindByEpc(testDB.DB, "3014AA02")
+	if err != nil {
+		t.Fatalf("Unable to find tag: %+v", err)
+	}
+	reread.LastRead = 1000
+	if err := ReplaceIfUnchanged(testDB.DB, []Tag{reread}, nil); err != nil {
+		t.Fatalf("Unable to replace tags: %+v", err)
+	}
+	found, err := FindByEpc(testDB.DB, "3014AA02")
+	if err != nil {
+		t.Fatalf("Unable to find tag: %+v", err)
+	}
+	if found.QualifiedState != "sold" || found.LastRead != 1000 || found.Version != 3 {
+		t.Errorf("expected the sold tag read at 1000 at version 3, got %+v", found)
+	}
+}
+
+func TestReplaceIfUnchangedRepeatedEpc(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	tags := []Tag{
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "present"},
+		{Epc: "3014AA01", FacilityID: "store1", EpcState: "departed"},
+	}
+	if err := ReplaceIfUnchanged(testDB.DB, tags, nil); err != nil {
+		t.Fatalf("expected the later tag to follow the earlier one: %+v", err)
+	}
+
+	found, err := FindByEpc(testDB.DB, "3014AA01")
+	if err != nil {
+		t.Fatalf("Unable to find tag: %+v", err)
+	}
+	if found.EpcState != "departed" || found.Version != 2 {
+		t.Errorf("expected the departed tag at version 2, got %+v", found)
+	}
+}
@@ -0,0 +1,642 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tagevent
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	tagEventsTable   = "tag_events"
+	checkpointsTable = "tag_event_checkpoints"
+	tagsTable        = "tags"
+	jsonb            = "data"
+	timestampColumn  = "timestamp"
+	epcColumn        = "epc"
+	productIDColumn  = "product_id"
+	facilityColumn   = "facility_id"
+	eventTypeColumn  = "event_type"
+	insertBatchSize  = 500
+	statePresent     = "present"
+	partitionNameFmt = "%s_%04d%02d"
+	// reads are grouped by UTC day in the facilities without business days
+	millisecondsInDay = 24 * 60 * 60 * 1000
+	// how late the events received after a checkpoint may be, i.e. how long before it they may have happened
+	checkpointLateness = millisecondsInDay
+)
+
+var (
+	// partitions caches the monthly partitions known to exist for each database
+	partitions     = make(map[string]bool)
+	partitionMutex = &sync.Mutex{}
+)
+
+// Insert appends tag events to the journal, creating the monthly partitions they belong to as needed
+func Insert(dbs *sql.DB, events []TagEvent) error {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.TagEvent.Insert.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Insert.Success`, nil)
+	mInsertErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Insert.Insert-Error`, nil)
+	mPartitionErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Insert.Partition-Error`, nil)
+	mInsertLatency := metrics.GetOrRegisterTimer(`Inventory.TagEvent.Insert.Insert-Latency`, nil)
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := ensurePartitions(dbs, events); err != nil {
+		mPartitionErr.Update(1)
+		return err
+	}
+
+	insertTimer := time.Now()
+	if err := insertEvents(dbs.Exec, events); err != nil {
+		mInsertErr.Update(1)
+		// the partitions may have been dropped since they were cached, so check again next time
+		forgetPartitions(dbs)
+		return err
+	}
+	mInsertLatency.Update(time.Since(insertTimer))
+
+	mSuccess.Update(1)
+	return nil
+}
+
+// Journal returns the journal appending the tag events in the transaction of the change of the tags.
+// The partitions of the events are created beforehand, as they cannot be created in that transaction.
+func Journal(dbs *sql.DB, events []TagEvent) (tag.Journal, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.TagEvent.Journal.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Journal.Success`, nil)
+	mInsertErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Journal.Insert-Error`, nil)
+	mPartitionErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Journal.Partition-Error`, nil)
+
+	if err := ensurePartitions(dbs, events); err != nil {
+		mPartitionErr.Update(1)
+		return nil, err
+	}
+
+	return func(transaction *sql.Tx, _ []tag.Tag) error {
+		if err := insertEvents(transaction.Exec, events); err != nil {
+			mInsertErr.Update(1)
+			forgetPartitions(dbs)
+			return err
+		}
+		mSuccess.Update(1)
+		return nil
+	}, nil
+}
+
+// RemovalJournal returns the journal appending the removal by the source of the tags removed in the
+// transaction, e.g. by a reset, so that the inventory as of a later time no longer includes them.
+// The partition of the removals is created beforehand, as it cannot be created in that transaction.
+func RemovalJournal(dbs *sql.DB, source string) (tag.Journal, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.TagEvent.RemovalJournal.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.TagEvent.RemovalJournal.Success`, nil)
+	mInsertErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.RemovalJournal.Insert-Error`, nil)
+	mPartitionErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.RemovalJournal.Partition-Error`, nil)
+
+	removedOn := helper.UnixMilliNow()
+	if err := ensurePartition(dbs, removedOn); err != nil {
+		mPartitionErr.Update(1)
+		return nil, err
+	}
+
+	return func(transaction *sql.Tx, removed []tag.Tag) error {
+		events := make([]TagEvent, len(removed))
+		for i, removedTag := range removed {
+			events[i] = NewRemovedEvent(removedTag, source, removedOn)
+		}
+		if err := insertEvents(transaction.Exec, events); err != nil {
+			mInsertErr.Update(1)
+			forgetPartitions(dbs)
+			return err
+		}
+		mSuccess.Update(1)
+		return nil
+	}, nil
+}
+
+// Checkpoint journals the state of every present tag, so that the inventory as of a later time is rebuilt from
+// the journal since the latest checkpoint rather than since its start, and includes the tags which have not
+// changed since. Returns the number of tags journaled.
+func Checkpoint(dbs *sql.DB) (int64, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.TagEvent.Checkpoint.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Checkpoint.Success`, nil)
+	mInsertErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Checkpoint.Insert-Error`, nil)
+	mPartitionErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Checkpoint.Partition-Error`, nil)
+	mInsertLatency := metrics.GetOrRegisterTimer(`Inventory.TagEvent.Checkpoint.Insert-Latency`, nil)
+
+	checkpointOn := helper.UnixMilliNow()
+	if err := ensurePartition(dbs, checkpointOn); err != nil {
+		mPartitionErr.Update(1)
+		return 0, err
+	}
+
+	field := func(name string) string {
+		return fmt.Sprintf("%s, %s -> %s", pq.QuoteLiteral(name), pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(name))
+	}
+	state := fmt.Sprintf("jsonb_build_object(%s, %s, %s, %s, %s -> %s -> 0 -> %s, %s)",
+		field("event"), field("epc_state"), field(facilityColumn),
+		pq.QuoteLiteral("location"), pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("location_history"), pq.QuoteLiteral("location"),
+		field("last_read"),
+	)
+	event := fmt.Sprintf("jsonb_build_object(%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)",
+		field(epcColumn), field(productIDColumn), field(facilityColumn),
+		pq.QuoteLiteral("location"), fmt.Sprintf("%s -> %s -> 0 -> %s",
+			pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("location_history"), pq.QuoteLiteral("location")),
+		pq.QuoteLiteral(eventTypeColumn), pq.QuoteLiteral(CheckpointEvent),
+		pq.QuoteLiteral("source"), pq.QuoteLiteral(CheckpointEvent),
+		pq.QuoteLiteral(timestampColumn), strconv.FormatInt(checkpointOn, 10),
+		pq.QuoteLiteral("processed_on"), strconv.FormatInt(checkpointOn, 10),
+		fmt.Sprintf("%s, %s, %s, %s", pq.QuoteLiteral("previous_state"), state, pq.QuoteLiteral("current_state"), state),
+	)
+
+	insertStmt := fmt.Sprintf(`INSERT INTO %s (%s, %s) SELECT %d, %s FROM %s WHERE %s ->> %s = %s;`,
+		pq.QuoteIdentifier(tagEventsTable),
+		pq.QuoteIdentifier(timestampColumn),
+		pq.QuoteIdentifier(jsonb),
+		checkpointOn,
+		event,
+		pq.QuoteIdentifier(tagsTable),
+		pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("epc_state"), pq.QuoteLiteral(statePresent),
+	)
+	checkpointStmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%d);`,
+		pq.QuoteIdentifier(checkpointsTable),
+		pq.QuoteIdentifier(timestampColumn),
+		checkpointOn,
+	)
+
+	insertTimer := time.Now()
+	transaction, err := dbs.Begin()
+	if err != nil {
+		mInsertErr.Update(1)
+		return 0, errors.Wrap(err, "unable to begin transaction")
+	}
+
+	var journaled int64
+	result, err := transaction.Exec(insertStmt)
+	if err == nil {
+		journaled, err = result.RowsAffected()
+	}
+	if err == nil {
+		_, err = transaction.Exec(checkpointStmt)
+	}
+	if err != nil {
+		mInsertErr.Update(1)
+		forgetPartitions(dbs)
+		if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+			return 0, errors.Wrap(rollbackErr, err.Error())
+		}
+		return 0, errors.Wrap(err, "error in checkpointing tag events")
+	}
+
+	if err := transaction.Commit(); err != nil {
+		mInsertErr.Update(1)
+		return 0, errors.Wrap(err, "unable to commit transaction")
+	}
+	mInsertLatency.Update(time.Since(insertTimer))
+
+	mSuccess.Update(1)
+	return journaled, nil
+}
+
+// EnsureBaseline takes the first checkpoint of the journal unless one was taken already, so that the tags
+// present before the journal was kept are included in the inventory as of a later time.
+// Returns whether the baseline was taken.
+func EnsureBaseline(dbs *sql.DB) (bool, error) {
+	latest, err := latestCheckpoint(dbs, helper.UnixMilliNow())
+	if err != nil || latest != 0 {
+		return false, err
+	}
+	_, err = Checkpoint(dbs)
+	return err == nil, err
+}
+
+// latestCheckpoint returns the millisecond epoch of the latest checkpoint at or before asOf, 0 if none
+func latestCheckpoint(dbs *sql.DB, asOf int64) (int64, error) {
+	selectQuery := fmt.Sprintf(`SELECT COALESCE(MAX(%s), 0) FROM %s WHERE %s <= %d;`,
+		pq.QuoteIdentifier(timestampColumn),
+		pq.QuoteIdentifier(checkpointsTable),
+		pq.QuoteIdentifier(timestampColumn),
+		asOf,
+	)
+
+	var latest int64
+	if err := dbs.QueryRow(selectQuery).Scan(&latest); err != nil {
+		return 0, errors.Wrap(err, "error in retrieving the latest checkpoint")
+	}
+	return latest, nil
+}
+
+// ensurePartitions creates the monthly partitions of the journal that hold the events
+func ensurePartitions(dbs *sql.DB, events []TagEvent) error {
+	for _, event := range events {
+		if err := ensurePartition(dbs, event.Timestamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertEvents inserts the events in batches with the exec function of a database or a transaction
+func insertEvents(exec func(query string, args ...interface{}) (sql.Result, error), events []TagEvent) error {
+	for start := 0; start < len(events); start += insertBatchSize {
+		end := start + insertBatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		values := make([]string, 0, end-start)
+		for _, event := range events[start:end] {
+			obj, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			values = append(values, fmt.Sprintf("(%d, %s)", event.Timestamp, pq.QuoteLiteral(string(obj))))
+		}
+
+		insertStmt := fmt.Sprintf(`INSERT INTO %s (%s, %s) VALUES %s;`,
+			pq.QuoteIdentifier(tagEventsTable),
+			pq.QuoteIdentifier(timestampColumn),
+			pq.QuoteIdentifier(jsonb),
+			strings.Join(values, ", "),
+		)
+
+		if _, err := exec(insertStmt); err != nil {
+			return errors.Wrap(err, "error in inserting tag events")
+		}
+	}
+	return nil
+}
+
+// ensurePartition creates the monthly partition of the journal that holds the timestamp
+func ensurePartition(dbs *sql.DB, timestamp int64) error {
+	eventTime := time.Unix(0, timestamp*int64(time.Millisecond)).UTC()
+	start := time.Date(eventTime.Year(), eventTime.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	partitionName := fmt.Sprintf(partitionNameFmt, tagEventsTable, start.Year(), start.Month())
+	cacheKey := fmt.Sprintf("%p/%s", dbs, partitionName)
+
+	partitionMutex.Lock()
+	defer partitionMutex.Unlock()
+
+	if partitions[cacheKey] {
+		return nil
+	}
+
+	createStmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%d) TO (%d);`,
+		pq.QuoteIdentifier(partitionName),
+		pq.QuoteIdentifier(tagEventsTable),
+		start.UnixNano()/int64(time.Millisecond),
+		end.UnixNano()/int64(time.Millisecond),
+	)
+
+	if _, err := dbs.Exec(createStmt); err != nil {
+		return errors.Wrapf(err, "unable to create tag events partition %s", partitionName)
+	}
+
+	partitions[cacheKey] = true
+	return nil
+}
+
+func forgetPartitions(dbs *sql.DB) {
+	prefix := fmt.Sprintf("%p/", dbs)
+
+	partitionMutex.Lock()
+	defer partitionMutex.Unlock()
+
+	for cacheKey := range partitions {
+		if strings.HasPrefix(cacheKey, prefix) {
+			delete(partitions, cacheKey)
+		}
+	}
+}
+
+// Retrieve retrieves tag events matching the query in chronological order, limited to maxSize events
+func Retrieve(dbs *sql.DB, query Query, maxSize int) ([]TagEvent, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.TagEvent.Retrieve.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Retrieve.Success`, nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.Retrieve.Retrieve-Error`, nil)
+	mRetrieveLatency := metrics.GetOrRegisterTimer(`Inventory.TagEvent.Retrieve.Retrieve-Latency`, nil)
+
+	if query.StartTime != 0 && query.EndTime != 0 && query.StartTime > query.EndTime {
+		return nil, errors.Wrap(web.ErrValidation, "starttime must not be after endtime")
+	}
+
+	size := maxSize
+	if query.Size > 0 && query.Size < maxSize {
+		size = query.Size
+	}
+
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s %s ORDER BY %s, %s LIMIT %d;`,
+		pq.QuoteIdentifier(jsonb),
+		pq.QuoteIdentifier(tagEventsTable),
+		buildWhereClause(query),
+		pq.QuoteIdentifier(timestampColumn),
+		pq.QuoteIdentifier("id"),
+		size,
+	)
+
+	retrieveTimer := time.Now()
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving tag events")
+	}
+	mRetrieveLatency.Update(time.Since(retrieveTimer))
+	defer rows.Close()
+
+	events := make([]TagEvent, 0)
+	for rows.Next() {
+		var event TagEvent
+		if err := rows.Scan(&event); err != nil {
+			mRetrieveErr.Update(1)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		mRetrieveErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return events, nil
+}
+
+// RetrieveAsOf rebuilds the inventory that was present at the asOf millisecond epoch by replaying the journal:
+// the state of each EPC is the current state of the latest event journaled for it at or before asOf.
+// The facility is an optional filter applied to that state. Only the journal since the latest checkpoint at or
+// before asOf is replayed, along with the events received after it which happened shortly before.
+func RetrieveAsOf(dbs *sql.DB, asOf int64, facilityID string) (Snapshot, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.TagEvent.RetrieveAsOf.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.TagEvent.RetrieveAsOf.Success`, nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.RetrieveAsOf.Retrieve-Error`, nil)
+	mRetrieveLatency := metrics.GetOrRegisterTimer(`Inventory.TagEvent.RetrieveAsOf.Retrieve-Latency`, nil)
+
+	snapshot := Snapshot{AsOf: asOf, Results: []ProductInventory{}}
+
+	checkpoint, err := latestCheckpoint(dbs, asOf)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return snapshot, err
+	}
+	window := fmt.Sprintf("%s <= %d", pq.QuoteIdentifier(timestampColumn), asOf)
+	if checkpoint != 0 {
+		// the events before the checkpoint are already part of it, unless they were received after it
+		window += fmt.Sprintf(" AND %s >= %d AND (%s >= %d OR (%s ->> %s)::BIGINT >= %d)",
+			pq.QuoteIdentifier(timestampColumn), checkpoint-checkpointLateness,
+			pq.QuoteIdentifier(timestampColumn), checkpoint,
+			pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("processed_on"), checkpoint)
+	}
+
+	currentState := fmt.Sprintf("%s -> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("current_state"))
+	conditions := []string{fmt.Sprintf("%s ->> %s = %s",
+		currentState, pq.QuoteLiteral("epc_state"), pq.QuoteLiteral(statePresent))}
+	if facilityID != "" {
+		conditions = append(conditions, fmt.Sprintf("%s ->> %s = %s",
+			currentState, pq.QuoteLiteral(facilityColumn), pq.QuoteLiteral(facilityID)))
+	}
+
+	epc := fmt.Sprintf("%s ->> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(epcColumn))
+	selectQuery := fmt.Sprintf(`SELECT %s, %s, %s ->> %s FROM (
+		SELECT DISTINCT ON (%s) %s FROM %s WHERE %s
+		ORDER BY %s, %s DESC, (%s ->> %s)::BIGINT DESC
+	) latest WHERE %s ORDER BY 3, 2, 1;`,
+		epc,
+		fmt.Sprintf("%s ->> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(productIDColumn)),
+		currentState, pq.QuoteLiteral(facilityColumn),
+		epc, pq.QuoteIdentifier(jsonb), pq.QuoteIdentifier(tagEventsTable), window,
+		epc, pq.QuoteIdentifier(timestampColumn), pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("processed_on"),
+		strings.Join(conditions, " AND "),
+	)
+
+	retrieveTimer := time.Now()
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return snapshot, errors.Wrap(err, "error in retrieving inventory as of time")
+	}
+	mRetrieveLatency.Update(time.Since(retrieveTimer))
+	defer rows.Close()
+
+	for rows.Next() {
+		var epcCode, product, facility string
+		if err := rows.Scan(&epcCode, &product, &facility); err != nil {
+			mRetrieveErr.Update(1)
+			return snapshot, err
+		}
+
+		// rows are ordered by facility then product, so each group is contiguous
+		last := len(snapshot.Results) - 1
+		if last < 0 || snapshot.Results[last].FacilityID != facility || snapshot.Results[last].ProductID != product {
+			snapshot.Results = append(snapshot.Results, ProductInventory{FacilityID: facility, ProductID: product})
+			last++
+		}
+		snapshot.Results[last].Epcs = append(snapshot.Results[last].Epcs, epcCode)
+		snapshot.Results[last].Count++
+		snapshot.Count++
+	}
+	if err = rows.Err(); err != nil {
+		mRetrieveErr.Update(1)
+		return snapshot, err
+	}
+
+	mSuccess.Update(1)
+	return snapshot, nil
+}
+
+// RetrieveReadGaps counts, for every facility, the gaps between the consecutive days each tag was read there
+// from the since millisecond epoch until the until one, optionally by product. Only the reads of present tags
+// are counted. Days are the business days starting at the given millisecond epochs in the facilities which
+// have them, UTC days in the others. A tag still present at until which was not read the day after its last read
+// was missed then, and the following days it was not read are counted as unread days too, although the gap is
+// not over yet, so that tags rarely read are not underrepresented. The current day is not over either, so it is
+// never counted as unread.
+func RetrieveReadGaps(dbs *sql.DB, since int64, until int64, dayStarts map[string][]int64, byProduct bool) ([]ReadGaps, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.TagEvent.RetrieveReadGaps.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.TagEvent.RetrieveReadGaps.Success`, nil)
+	mRetrieveErr := metrics.GetOrRegisterGauge(`Inventory.TagEvent.RetrieveReadGaps.Retrieve-Error`, nil)
+	mRetrieveLatency := metrics.GetOrRegisterTimer(`Inventory.TagEvent.RetrieveReadGaps.Retrieve-Latency`, nil)
+
+	currentState := fmt.Sprintf("%s -> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("current_state"))
+	facility := fmt.Sprintf("%s ->> %s", currentState, pq.QuoteLiteral(facilityColumn))
+	epc := fmt.Sprintf("%s ->> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(epcColumn))
+	present := fmt.Sprintf("%s ->> %s = %s", currentState, pq.QuoteLiteral("epc_state"), pq.QuoteLiteral(statePresent))
+	product := pq.QuoteLiteral("")
+	if byProduct {
+		product = fmt.Sprintf("%s ->> %s", pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(productIDColumn))
+	}
+	window := fmt.Sprintf("%s >= %d AND %s < %d",
+		pq.QuoteIdentifier(timestampColumn), since, pq.QuoteIdentifier(timestampColumn), until)
+	read := fmt.Sprintf("%s ->> %s <> %s",
+		pq.QuoteIdentifier(jsonb), pq.QuoteLiteral(eventTypeColumn), pq.QuoteLiteral(CheckpointEvent))
+
+	// the days each tag was read, then the number of days since the previous one, and for the last one
+	// of a tag still present, the number of days it was not read since then
+	selectQuery := fmt.Sprintf(`SELECT facility, product,
+		COUNT(*) FILTER (WHERE gap = 1),
+		COUNT(*) FILTER (WHERE gap > 1),
+		COUNT(*) FILTER (WHERE unread > 0),
+		COALESCE(SUM(gap - 1) FILTER (WHERE gap > 1), 0) + COALESCE(SUM(unread - 1) FILTER (WHERE unread > 1), 0)
+	FROM (
+		SELECT facility, product, day - LAG(day) OVER tag_days AS gap,
+			CASE WHEN COALESCE(still_present, false) AND LEAD(day) OVER tag_days IS NULL THEN current_day - day - 1 END AS unread
+		FROM (
+			SELECT DISTINCT %s AS facility, %s AS product, %s AS epc, %s AS day, %s AS current_day
+			FROM %s WHERE %s AND %s AND %s
+		) days LEFT JOIN (
+			SELECT DISTINCT ON (%s) %s AS latest_epc, %s AS latest_facility, %s AS still_present
+			FROM %s WHERE %s ORDER BY %s, %s DESC, (%s ->> %s)::BIGINT DESC
+		) latest ON latest_epc = epc AND latest_facility = facility
+		WINDOW tag_days AS (PARTITION BY facility, product, epc ORDER BY day)
+	) gaps GROUP BY facility, product HAVING COUNT(gap) > 0 OR COUNT(*) FILTER (WHERE unread > 0) > 0
+	ORDER BY facility, product;`,
+		facility, product, epc,
+		dayExpression(pq.QuoteIdentifier(timestampColumn), facility, dayStarts),
+		dayExpression(fmt.Sprintf("%d::BIGINT", until), facility, dayStarts),
+		pq.QuoteIdentifier(tagEventsTable), window, present, read,
+		epc, epc, facility, present,
+		pq.QuoteIdentifier(tagEventsTable), window,
+		epc, pq.QuoteIdentifier(timestampColumn), pq.QuoteIdentifier(jsonb), pq.QuoteLiteral("processed_on"),
+	)
+
+	retrieveTimer := time.Now()
+	rows, err := dbs.Query(selectQuery)
+	if err != nil {
+		mRetrieveErr.Update(1)
+		return nil, errors.Wrap(err, "error in retrieving read gaps")
+	}
+	mRetrieveLatency.Update(time.Since(retrieveTimer))
+	defer rows.Close()
+
+	gaps := make([]ReadGaps, 0)
+	for rows.Next() {
+		var group ReadGaps
+		if err := rows.Scan(&group.FacilityID, &group.ProductID, &group.NextDay, &group.Later, &group.Missed, &group.UnreadDays); err != nil {
+			mRetrieveErr.Update(1)
+			return nil, err
+		}
+		gaps = append(gaps, group)
+	}
+	if err = rows.Err(); err != nil {
+		mRetrieveErr.Update(1)
+		return nil, err
+	}
+
+	mSuccess.Update(1)
+	return gaps, nil
+}
+
+// dayExpression returns the expression of the number of the day of the millisecond epoch operand in the
+// facility: the business day it is in if the facility has business days, else the UTC day
+func dayExpression(operand string, facility string, dayStarts map[string][]int64) string {
+	utcDay := fmt.Sprintf("%s / %d", operand, millisecondsInDay)
+
+	facilities := make([]string, 0, len(dayStarts))
+	for facilityID := range dayStarts {
+		facilities = append(facilities, facilityID)
+	}
+	sort.Strings(facilities)
+
+	cases := make([]string, 0, len(facilities))
+	for _, facilityID := range facilities {
+		if len(dayStarts[facilityID]) == 0 {
+			continue
+		}
+		starts := make([]string, len(dayStarts[facilityID]))
+		for i, start := range dayStarts[facilityID] {
+			starts[i] = strconv.FormatInt(start, 10)
+		}
+		cases = append(cases, fmt.Sprintf("WHEN %s THEN width_bucket(%s, ARRAY[%s]::BIGINT[])",
+			pq.QuoteLiteral(facilityID), operand, strings.Join(starts, ", ")))
+	}
+	if len(cases) == 0 {
+		return utcDay
+	}
+	return fmt.Sprintf("CASE %s %s ELSE %s END", facility, strings.Join(cases, " "), utcDay)
+}
+
+func buildWhereClause(query Query) string {
+	var conditions []string
+
+	jsonbConditions := []struct {
+		column string
+		value  string
+	}{
+		{epcColumn, query.Epc},
+		{productIDColumn, query.ProductID},
+		{facilityColumn, query.FacilityID},
+		{eventTypeColumn, query.EventType},
+	}
+	for _, condition := range jsonbConditions {
+		if condition.value != "" {
+			conditions = append(conditions, fmt.Sprintf("%s ->> %s = %s",
+				pq.QuoteIdentifier(jsonb),
+				pq.QuoteLiteral(condition.column),
+				pq.QuoteLiteral(condition.value),
+			))
+		}
+	}
+
+	// filtering on the partition key lets postgres skip partitions outside the time range
+	if query.StartTime != 0 {
+		conditions = append(conditions, fmt.Sprintf("%s >= %d", pq.QuoteIdentifier(timestampColumn), query.StartTime))
+	}
+	if query.EndTime != 0 {
+		conditions = append(conditions, fmt.Sprintf("%s <= %d", pq.QuoteIdentifier(timestampColumn), query.EndTime))
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// Value implements driver.Valuer interfaces
+func (event TagEvent) Value() (driver.Value, error) {
+	return json.Marshal(event)
+}
+
+// Scan implements sql.Scanner interfaces
+func (event *TagEvent) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, event)
+}
@@ -0,0 +1,361 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tagevent
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/integrationtest"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
+	"github.com/pkg/errors"
+)
+
+var dbHost integrationtest.DBHost
+
+func TestMain(m *testing.M) {
+	dbHost = integrationtest.InitHost("tagEvent_test")
+	exitCode := m.Run()
+	dbHost.Close()
+	os.Exit(exitCode)
+}
+
+func TestInsertAndRetrieve(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	// spans two months so that two partitions are created
+	firstRead := time.Date(2019, time.January, 31, 23, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	secondRead := time.Date(2019, time.February, 1, 1, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+
+	events := []TagEvent{
+		{Epc: "30143639F84191AD22900104", ProductID: "00888446671424", FacilityID: "Tavern", EventType: "arrival", Timestamp: firstRead},
+		{Epc: "30143639F84191AD22900104", ProductID: "00888446671424", FacilityID: "Tavern", EventType: "departed", Timestamp: secondRead},
+		{Epc: "30143639F84191AD22900105", ProductID: "00888446671425", FacilityID: "Saloon", EventType: "arrival", Timestamp: secondRead},
+	}
+	if err := Insert(testDB.DB, events); err != nil {
+		t.Fatalf("error inserting tag events: %+v", err)
+	}
+
+	results, err := Retrieve(testDB.DB, Query{}, 100)
+	if err != nil {
+		t.Fatalf("error retrieving tag events: %+v", err)
+	}
+	if len(results) != len(events) {
+		t.Fatalf("expected %d events, got %d", len(events), len(results))
+	}
+	if results[0].Timestamp != firstRead {
+		t.Errorf("expected events in chronological order, got %+v", results)
+	}
+
+	results, err = Retrieve(testDB.DB, Query{Epc: "30143639F84191AD22900104", EventType: "departed"}, 100)
+	if err != nil {
+		t.Fatalf("error retrieving tag events: %+v", err)
+	}
+	if len(results) != 1 || results[0].Timestamp != secondRead {
+		t.Errorf("expected the departed event only, got %+v", results)
+	}
+
+	results, err = Retrieve(testDB.DB, Query{FacilityID: "Tavern", StartTime: secondRead}, 100)
+	if err != nil {
+		t.Fatalf("error retrieving tag events: %+v", err)
+	}
+	if len(results) != 1 || results[0].EventType != "departed" {
+		t.Errorf("expected one Tavern event after the start time, got %+v", results)
+	}
+
+	results, err = Retrieve(testDB.DB, Query{Size: 1}, 100)
+	if err != nil {
+		t.Fatalf("error retrieving tag events: %+v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected size to limit the results to 1, got %d", len(results))
+	}
+}
+
+func TestRetrieveInvalidTimeRange(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	if _, err := Retrieve(testDB.DB, Query{StartTime: 2, EndTime: 1}, 100); err == nil {
+		t.Error("expected an error when starttime is after endtime")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	read := time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	tags := []tag.Tag{{Epc: "30143639F84191AD22900104", FacilityID: "Tavern", LastRead: read}}
+	events := []TagEvent{{Epc: "30143639F84191AD22900104", FacilityID: "Tavern", EventType: "arrival", Timestamp: read}}
+
+	journal, err := Journal(testDB.DB, events)
+	if err != nil {
+		t.Fatalf("error preparing the journal: %+v", err)
+	}
+	if err := tag.ReplaceIfUnchanged(testDB.DB, tags, journal); err != nil {
+		t.Fatalf("error replacing tags: %+v", err)
+	}
+
+	// a conflicting write journals nothing
+	stale := []tag.Tag{{Epc: "30143639F84191AD22900104", FacilityID: "Tavern", LastRead: read + 1}}
+	if err := tag.ReplaceIfUnchanged(testDB.DB, stale, journal); errors.Cause(err) != web.ErrPreconditionFailed {
+		t.Fatalf("expected a failed precondition, got %+v", err)
+	}
+
+	results, err := Retrieve(testDB.DB, Query{}, 100)
+	if err != nil {
+		t.Fatalf("error retrieving tag events: %+v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected only the written tags to be journaled, got %+v", results)
+	}
+}
+
+func TestRemovalJournal(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	read := helper.UnixMilliNow() - 60*1000
+	tags := []tag.Tag{{Epc: "30143639F84191AD22900104", FacilityID: "Tavern", EpcState: statePresent, LastRead: read}}
+	journal, err := Journal(testDB.DB, []TagEvent{{Epc: tags[0].Epc, FacilityID: "Tavern", EventType: "arrival", Timestamp: read,
+		CurrentState: State{EpcState: statePresent, FacilityID: "Tavern", LastRead: read}}})
+	if err != nil {
+		t.Fatalf("error preparing the journal: %+v", err)
+	}
+	if err := tag.ReplaceIfUnchanged(testDB.DB, tags, journal); err != nil {
+		t.Fatalf("error replacing tags: %+v", err)
+	}
+
+	removal, err := RemovalJournal(testDB.DB, ResetSource)
+	if err != nil {
+		t.Fatalf("error preparing the removal journal: %+v", err)
+	}
+	if _, err := tag.Reset(testDB.DB, tag.ResetScope{FacilityID: "Tavern"}, false, removal); err != nil {
+		t.Fatalf("error resetting tags: %+v", err)
+	}
+
+	snapshot, err := RetrieveAsOf(testDB.DB, read, "")
+	if err != nil {
+		t.Fatalf("error retrieving inventory: %+v", err)
+	}
+	if snapshot.Count != 1 {
+		t.Errorf("expected the tag present before the reset, got %+v", snapshot)
+	}
+
+	snapshot, err = RetrieveAsOf(testDB.DB, helper.UnixMilliNow()+1000, "")
+	if err != nil {
+		t.Fatalf("error retrieving inventory: %+v", err)
+	}
+	if snapshot.Count != 0 {
+		t.Errorf("expected the reset tag no longer present, got %+v", snapshot)
+	}
+}
+
+func TestRetrieveAsOf(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	present := State{EpcState: "present", FacilityID: "Tavern"}
+	departed := State{EpcState: "departed", FacilityID: "Tavern"}
+	moved := State{EpcState: "present", FacilityID: "Saloon"}
+
+	events := []TagEvent{
+		{Epc: "EPC1", ProductID: "P1", EventType: "arrival", Timestamp: 1000, CurrentState: present},
+		{Epc: "EPC2", ProductID: "P1", EventType: "arrival", Timestamp: 1000, CurrentState: present},
+		{Epc: "EPC3", ProductID: "P2", EventType: "arrival", Timestamp: 1000, CurrentState: present},
+		{Epc: "EPC1", ProductID: "P1", EventType: "departed", Timestamp: 2000, PreviousState: present, CurrentState: departed},
+		{Epc: "EPC3", ProductID: "P2", EventType: "arrival", Timestamp: 3000, PreviousState: present, CurrentState: moved},
+	}
+	if err := Insert(testDB.DB, events); err != nil {
+		t.Fatalf("error inserting tag events: %+v", err)
+	}
+
+	snapshot, err := RetrieveAsOf(testDB.DB, 1500, "Tavern")
+	if err != nil {
+		t.Fatalf("error retrieving inventory: %+v", err)
+	}
+	if snapshot.Count != 3 || len(snapshot.Results) != 2 {
+		t.Fatalf("expected 3 EPCs of 2 products before the departure, got %+v", snapshot)
+	}
+	if snapshot.Results[0].ProductID != "P1" || snapshot.Results[0].Count != 2 {
+		t.Errorf("expected 2 EPCs of P1, got %+v", snapshot.Results[0])
+	}
+
+	snapshot, err = RetrieveAsOf(testDB.DB, 2500, "Tavern")
+	if err != nil {
+		t.Fatalf("error retrieving inventory: %+v", err)
+	}
+	if snapshot.Count != 2 {
+		t.Errorf("expected the departed EPC to be excluded, got %+v", snapshot)
+	}
+
+	snapshot, err = RetrieveAsOf(testDB.DB, 3500, "")
+	if err != nil {
+		t.Fatalf("error retrieving inventory: %+v", err)
+	}
+	if snapshot.Count != 2 || snapshot.Results[0].FacilityID != "Saloon" {
+		t.Errorf("expected EPC3 to have moved to Saloon, got %+v", snapshot)
+	}
+
+	snapshot, err = RetrieveAsOf(testDB.DB, 500, "")
+	if err != nil {
+		t.Fatalf("error retrieving inventory: %+v", err)
+	}
+	if snapshot.Count != 0 || snapshot.Results == nil {
+		t.Errorf("expected an empty inventory before any event, got %+v", snapshot)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	// tags present before the journal was kept
+	now := helper.UnixMilliNow()
+	tags := []tag.Tag{
+		{Epc: "30143639F84191AD22900104", ProductID: "00888446671424", FacilityID: "Tavern", EpcState: statePresent,
+			LastRead: now - 60*1000, LocationHistory: []tag.LocationHistory{{Location: "RSP-1", Timestamp: now - 60*1000}}},
+		{Epc: "30143639F84191AD22900105", ProductID: "00888446671424", FacilityID: "Tavern", EpcState: "departed",
+			LastRead: now - 60*1000},
+	}
+	if err := tag.Replace(testDB.DB, tags); err != nil {
+		t.Fatalf("error replacing tags: %+v", err)
+	}
+	// an event journaled long before the checkpoint, whose tag was removed since without journaling it
+	old := now - 2*millisecondsInDay
+	if err := Insert(testDB.DB, []TagEvent{{Epc: "30143639F84191AD22900106", ProductID: "00888446671424", EventType: "arrival",
+		Timestamp: old, ProcessedOn: old, CurrentState: State{EpcState: statePresent, FacilityID: "Tavern"}}}); err != nil {
+		t.Fatalf("error inserting tag events: %+v", err)
+	}
+
+	taken, err := EnsureBaseline(testDB.DB)
+	if err != nil {
+		t.Fatalf("error taking the baseline: %+v", err)
+	}
+	if !taken {
+		t.Errorf("expected the baseline to be taken")
+	}
+	if taken, err = EnsureBaseline(testDB.DB); err != nil || taken {
+		t.Errorf("expected the baseline to be taken only once, got %v %+v", taken, err)
+	}
+
+	// an event which happened before the checkpoint but was received after it
+	late := helper.UnixMilliNow() + 1000
+	if err := Insert(testDB.DB, []TagEvent{{Epc: "30143639F84191AD22900107", ProductID: "00888446671424", EventType: "arrival",
+		Timestamp: now - 1000, ProcessedOn: late, CurrentState: State{EpcState: statePresent, FacilityID: "Tavern"}}}); err != nil {
+		t.Fatalf("error inserting tag events: %+v", err)
+	}
+
+	snapshot, err := RetrieveAsOf(testDB.DB, late, "Tavern")
+	if err != nil {
+		t.Fatalf("error retrieving inventory: %+v", err)
+	}
+	if snapshot.Count != 2 || len(snapshot.Results) != 1 || snapshot.Results[0].Epcs[0] != tags[0].Epc ||
+		snapshot.Results[0].Epcs[1] != "30143639F84191AD22900107" {
+		t.Errorf("expected the present tag from the checkpoint and the late event, got %+v", snapshot)
+	}
+
+	events, err := Retrieve(testDB.DB, Query{Epc: tags[0].Epc}, 100)
+	if err != nil {
+		t.Fatalf("error retrieving tag events: %+v", err)
+	}
+	if len(events) != 1 || events[0].EventType != CheckpointEvent || events[0].CurrentState.Location != "RSP-1" ||
+		events[0].CurrentState.LastRead != tags[0].LastRead {
+		t.Errorf("expected the checkpoint of the present tag, got %+v", events)
+	}
+}
+
+func TestRetrieveReadGaps(t *testing.T) {
+	testDB := dbHost.CreateDB(t)
+	defer testDB.Close()
+
+	day := int64(24 * time.Hour / time.Millisecond)
+	start := time.Date(2019, time.March, 1, 10, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+	present := State{EpcState: "present", FacilityID: "Tavern"}
+	departed := State{EpcState: "departed", FacilityID: "Tavern"}
+
+	events := []TagEvent{
+		// read on days 0, 1, 2 and 5, twice on day 1
+		{Epc: "EPC1", ProductID: "P1", EventType: "arrival", Timestamp: start, CurrentState: present},
+		{Epc: "EPC1", ProductID: "P1", EventType: "cycle_count", Timestamp: start + day, CurrentState: present},
+		{Epc: "EPC1", ProductID: "P1", EventType: "cycle_count", Timestamp: start + day + 1000, CurrentState: present},
+		{Epc: "EPC1", ProductID: "P1", EventType: "cycle_count", Timestamp: start + 2*day, CurrentState: present},
+		{Epc: "EPC1", ProductID: "P1", EventType: "cycle_count", Timestamp: start + 5*day, CurrentState: present},
+		// read on day 0 and 3, then departed
+		{Epc: "EPC2", ProductID: "P2", EventType: "arrival", Timestamp: start, CurrentState: present},
+		{Epc: "EPC2", ProductID: "P2", EventType: "cycle_count", Timestamp: start + 3*day, CurrentState: present},
+		{Epc: "EPC2", ProductID: "P2", EventType: "departed", Timestamp: start + 4*day, CurrentState: departed},
+	}
+	if err := Insert(testDB.DB, events); err != nil {
+		t.Fatalf("error inserting tag events: %+v", err)
+	}
+
+	gaps, err := RetrieveReadGaps(testDB.DB, start, start+6*day, nil, false)
+	if err != nil {
+		t.Fatalf("error retrieving read gaps: %+v", err)
+	}
+	expected := ReadGaps{FacilityID: "Tavern", NextDay: 2, Later: 2, UnreadDays: 4}
+	if len(gaps) != 1 || gaps[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, gaps)
+	}
+
+	gaps, err = RetrieveReadGaps(testDB.DB, start, start+6*day, nil, true)
+	if err != nil {
+		t.Fatalf("error retrieving read gaps: %+v", err)
+	}
+	if len(gaps) != 2 || gaps[0].ProductID != "P1" || gaps[0].NextDay != 2 || gaps[1].UnreadDays != 2 {
+		t.Errorf("expected the gaps of P1 and P2, got %+v", gaps)
+	}
+
+	// the reads before the start of the window are not counted
+	gaps, err = RetrieveReadGaps(testDB.DB, start+day, start+6*day, nil, false)
+	if err != nil {
+		t.Fatalf("error retrieving read gaps: %+v", err)
+	}
+	expected = ReadGaps{FacilityID: "Tavern", NextDay: 1, Later: 1, UnreadDays: 2}
+	if len(gaps) != 1 || gaps[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, gaps)
+	}
+
+	// the present tag not read since day 5 was missed on day 6, and the departed tag is not counted
+	gaps, err = RetrieveReadGaps(testDB.DB, start, start+7*day, nil, false)
+	if err != nil {
+		t.Fatalf("error retrieving read gaps: %+v", err)
+	}
+	expected = ReadGaps{FacilityID: "Tavern", NextDay: 2, Later: 2, Missed: 1, UnreadDays: 4}
+	if len(gaps) != 1 || gaps[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, gaps)
+	}
+
+	// then not read on day 7 either
+	gaps, err = RetrieveReadGaps(testDB.DB, start, start+8*day, nil, false)
+	if err != nil {
+		t.Fatalf("error retrieving read gaps: %+v", err)
+	}
+	expected = ReadGaps{FacilityID: "Tavern", NextDay: 2, Later: 2, Missed: 1, UnreadDays: 5}
+	if len(gaps) != 1 || gaps[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, gaps)
+	}
+
+	// business days starting at 12:00 UTC move the reads at 10:00 to the business day before
+	var dayStarts []int64
+	for dayStart := start - 22*time.Hour.Nanoseconds()/int64(time.Millisecond); dayStart < start+8*day; dayStart += day {
+		dayStarts = append(dayStarts, dayStart)
+	}
+	gaps, err = RetrieveReadGaps(testDB.DB, start, start+8*day, map[string][]int64{"Tavern": dayStarts}, false)
+	if err != nil {
+		t.Fatalf("error retrieving read gaps: %+v", err)
+	}
+	if len(gaps) != 1 || gaps[0] != expected {
+		t.Errorf("expected the same gaps by business day, got %+v", gaps)
+	}
+}
@@ -0,0 +1,164 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package tagevent
+
+import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
+)
+
+const (
+	// RemovedEvent is the event type of the journal entry of a tag removed from the inventory,
+	// whose current state is empty
+	RemovedEvent = "removed"
+	// ResetSource is the source of the tags removed by a reset
+	ResetSource = "reset"
+	// PurgeSource is the source of the tags removed by a purge
+	PurgeSource = "purge"
+	// ArchiveSource is the source of the tags removed by archiving
+	ArchiveSource = "archive"
+	// CheckpointEvent is the event type of the journal entry of the state of a present tag at a checkpoint,
+	// which is not a read
+	CheckpointEvent = "checkpoint"
+)
+
+// TagEvent is the journal entry of a processed tag event and the state change it caused
+//swagger:model TagEvent
+type TagEvent struct {
+	// SGTIN EPC code
+	Epc string `json:"epc"`
+	// ProductID decoded from the EPC
+	ProductID string `json:"product_id"`
+	// Facility ID the event was received for
+	FacilityID string `json:"facility_id"`
+	// Location the tag was read at
+	Location string `json:"location"`
+	// Event received (arrival, moved, departed, returned or cycle_count), removed or checkpoint
+	EventType string `json:"event_type"`
+	// Where the event came from (fixed or handheld), what removed the tag (reset, purge or archive), or checkpoint
+	Source string `json:"source"`
+	// Event time in milliseconds epoch
+	Timestamp int64 `json:"timestamp"`
+	// Time the event was processed in milliseconds epoch
+	ProcessedOn int64 `json:"processed_on"`
+	// State of the tag before the event
+	PreviousState State `json:"previous_state"`
+	// State of the tag after the event
+	CurrentState State `json:"current_state"`
+}
+
+// State is the part of a tag's state that can be changed by a tag event
+type State struct {
+	// Last event recorded for tag
+	Event string `json:"event"`
+	// Either 'present' or 'departed', empty if the tag did not exist
+	EpcState string `json:"epc_state"`
+	// Facility ID
+	FacilityID string `json:"facility_id"`
+	// Most recent location of the tag
+	Location string `json:"location"`
+	// Tag last read time in milliseconds epoch
+	LastRead int64 `json:"last_read"`
+}
+
+// Query is the model of the filters used to retrieve tag events
+type Query struct {
+	Epc        string
+	ProductID  string
+	FacilityID string
+	EventType  string
+	// Millisecond epoch start time
+	StartTime int64
+	// Millisecond epoch end time
+	EndTime int64
+	// Maximum number of events returned
+	Size int
+}
+
+// Response is the model used to return the query response
+type Response struct {
+	Results interface{} `json:"results"`
+	Count   *int        `json:"count,omitempty"`
+}
+
+// NewTagEvent creates the journal entry of the tag event received from the source,
+// which changed the tag from its previous state to its current state
+func NewTagEvent(event jsonrpc.TagEvent, source string, previous tag.Tag, current tag.Tag, processedOn int64) TagEvent {
+	return TagEvent{
+		Epc:           event.EpcCode,
+		ProductID:     current.ProductID,
+		FacilityID:    event.FacilityID,
+		Location:      event.Location,
+		EventType:     event.EventType,
+		Source:        source,
+		Timestamp:     event.Timestamp,
+		ProcessedOn:   processedOn,
+		PreviousState: stateOf(previous),
+		CurrentState:  stateOf(current),
+	}
+}
+
+// NewRemovedEvent creates the journal entry of the tag removed from the inventory by the source
+func NewRemovedEvent(removed tag.Tag, source string, processedOn int64) TagEvent {
+	return TagEvent{
+		Epc:           removed.Epc,
+		ProductID:     removed.ProductID,
+		FacilityID:    removed.FacilityID,
+		EventType:     RemovedEvent,
+		Source:        source,
+		Timestamp:     processedOn,
+		ProcessedOn:   processedOn,
+		PreviousState: stateOf(removed),
+	}
+}
+
+func stateOf(tagState tag.Tag) State {
+	state := State{
+		Event:      tagState.Event,
+		EpcState:   tagState.EpcState,
+		FacilityID: tagState.FacilityID,
+		LastRead:   tagState.LastRead,
+	}
+	if len(tagState.LocationHistory) > 0 {
+		state.Location = tagState.LocationHistory[0].Location
+	}
+	return state
+}
+
+// ProductInventory is the set of EPCs of a product that were present in a facility at a point in time
+type ProductInventory struct {
+	FacilityID string   `json:"facility_id"`
+	ProductID  string   `json:"product_id"`
+	Count      int      `json:"count"`
+	Epcs       []string `json:"epcs"`
+}
+
+// Snapshot is the inventory that was on hand at a point in time
+type Snapshot struct {
+	// Millisecond epoch of the snapshot
+	AsOf int64 `json:"as_of"`
+	// Total number of EPCs present
+	Count int `json:"count"`
+	// Present EPCs grouped by facility and product
+	Results []ProductInventory `json:"results"`
+}
+
+// ReadGaps counts the gaps between the consecutive days present tags were read in a facility, which tell how
+// likely a present tag is to be read in a day
+type ReadGaps struct {
+	FacilityID string `json:"facility_id"`
+	// Empty unless the gaps are counted by product
+	ProductID string `json:"product_id,omitempty"`
+	// Number of times a tag was read again the next day
+	NextDay int64 `json:"next_day"`
+	// Number of times a tag was read again after at least one day without read
+	Later int64 `json:"later"`
+	// Number of times a present tag was not read the day after its last read, and not read again yet
+	Missed int64 `json:"missed"`
+	// Total number of days without read after the day a tag was missed, until it was read again or until now
+	UnreadDays int64 `json:"unread_days"`
+}
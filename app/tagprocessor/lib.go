@@ -11,9 +11,11 @@ import (
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/sensor"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"sort"
 	"sync"
 	"time"
 )
@@ -22,6 +24,9 @@ var (
 	inventory   = make(map[string]*Tag)
 	exitingTags = make(map[string][]*Tag)
 
+	// deviceWatermarks holds the newest read timestamp received from each sensor
+	deviceWatermarks = make(map[string]int64)
+
 	weighter = newRssiAdjuster()
 
 	inventoryMutex = &sync.Mutex{}
@@ -53,6 +58,15 @@ func ProcessInventoryData(dbs *sql.DB, invData *jsonrpc.InventoryData) (*jsonrpc
 		}
 	}
 
+	if config.AppConfig.LateReadPolicy == config.LateReadOrdered {
+		// buffered data is not guaranteed to be in chronological order
+		sort.SliceStable(invData.Params.Data, func(i, j int) bool {
+			return invData.Params.Data[i].LastReadOn < invData.Params.Data[j].LastReadOn
+		})
+	}
+
+	updateDeviceWatermark(rsp.DeviceId, invData.Params.Data)
+
 	invEvent := jsonrpc.NewInventoryEvent()
 
 	for _, read := range invData.Params.Data {
@@ -62,6 +76,40 @@ func ProcessInventoryData(dbs *sql.DB, invData *jsonrpc.InventoryData) (*jsonrpc
 	return invEvent, nil
 }
 
+// updateDeviceWatermark advances the watermark of the sensor to the newest read it sent,
+// and reports how far behind the watermark the oldest read of the payload was
+func updateDeviceWatermark(deviceId string, reads []jsonrpc.TagRead) {
+	if len(reads) == 0 {
+		return
+	}
+
+	mLateDeviceData := metrics.GetOrRegisterGaugeCollection("Inventory.ProcessInventoryData.LateDeviceData", nil)
+	mDeviceLagMillis := metrics.GetOrRegisterGauge("Inventory.ProcessInventoryData.DeviceLag-Millis", nil)
+
+	oldest, newest := reads[0].LastReadOn, reads[0].LastReadOn
+	for _, read := range reads[1:] {
+		if read.LastReadOn < oldest {
+			oldest = read.LastReadOn
+		}
+		if read.LastReadOn > newest {
+			newest = read.LastReadOn
+		}
+	}
+
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	watermark := deviceWatermarks[deviceId]
+	if oldest < watermark-int64(config.AppConfig.LateReadToleranceMillis) {
+		mLateDeviceData.Add(1)
+		mDeviceLagMillis.Update(watermark - oldest)
+		logrus.Debugf("sensor %s sent data %d ms older than its watermark %d", deviceId, watermark-oldest, watermark)
+	}
+	if newest > watermark {
+		deviceWatermarks[deviceId] = newest
+	}
+}
+
 func processReadData(invEvent *jsonrpc.InventoryEvent, read *jsonrpc.TagRead, rsp *sensor.RSP) {
 	inventoryMutex.Lock()
 
@@ -71,6 +119,19 @@ func processReadData(invEvent *jsonrpc.InventoryEvent, read *jsonrpc.TagRead, rs
 		inventory[read.Epc] = tag
 	}
 
+	if exists && config.LateReadsGuarded() && tag.isLateRead(read) {
+		// a late read must never move the tag back in time, nor undo a departure
+		if config.AppConfig.LateReadPolicy == config.LateReadOrdered {
+			tag.updateStats(rsp, read)
+			metrics.GetOrRegisterGaugeCollection("Inventory.ProcessReadData.LateRead-Applied", nil).Add(1)
+		} else {
+			metrics.GetOrRegisterGaugeCollection("Inventory.ProcessReadData.LateRead-Ignored", nil).Add(1)
+		}
+		logrus.Debugf("late read of %s from %s at %d, tag last read at %d", read.Epc, rsp.DeviceId, read.LastReadOn, tag.LastRead)
+		inventoryMutex.Unlock()
+		return
+	}
+
 	prev := tag.asPreviousTag()
 	tag.update(rsp, read, &weighter)
 
@@ -232,6 +293,58 @@ func DoAgeoutTask() int {
 	return numRemoved
 }
 
+// ResetInventory forgets the tags of the facility, optionally only the ones located at one of the sensors,
+// or only counts them on a dry run. An empty facility forgets all the tags.
+func ResetInventory(facilityId string, deviceIds []string, dryRun bool) int {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	inScope := func(tag *Tag) bool {
+		if facilityId == "" {
+			return true
+		}
+		if tag.FacilityId != facilityId {
+			return false
+		}
+		if len(deviceIds) == 0 {
+			return true
+		}
+		for _, deviceId := range deviceIds {
+			if tag.DeviceLocation == deviceId {
+				return true
+			}
+		}
+		return false
+	}
+
+	// it is safe to remove from map while iterating in golang
+	var numRemoved int
+	for epc, tag := range inventory {
+		if inScope(tag) {
+			numRemoved++
+			if !dryRun {
+				delete(inventory, epc)
+			}
+		}
+	}
+	if dryRun {
+		return numRemoved
+	}
+
+	for facility, tags := range exitingTags {
+		kept := tags[:0]
+		for _, tag := range tags {
+			if !inScope(tag) {
+				kept = append(kept, tag)
+			}
+		}
+		exitingTags[facility] = kept
+	}
+
+	logrus.Infof("inventory reset removed %d tags", numRemoved)
+	return numRemoved
+}
+
 func DoAggregateDepartedTask() *jsonrpc.InventoryEvent {
 	inventoryMutex.Lock()
 	defer inventoryMutex.Unlock()
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/sensor"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"testing"
 )
 
@@ -462,3 +464,134 @@ func TestTagDepartAndReturnPOS(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestLateReadDoesNotUndoDeparture(t *testing.T) {
+	lateReadPolicy := config.AppConfig.LateReadPolicy
+	config.AppConfig.LateReadPolicy = config.LateReadIgnore
+	defer func() { config.AppConfig.LateReadPolicy = lateReadPolicy }()
+
+	ds := newTestDataset(5)
+
+	back := generateTestSensor(backStock, sensor.NoPersonality)
+	frontPos := generateTestSensor(salesFloor, sensor.POS)
+	front := generateTestSensor(salesFloor, sensor.NoPersonality)
+
+	ds.readAll(back, rssiMin, 1)
+	ds.updateTagRefs()
+	ds.resetEvents()
+
+	// depart the tags through the POS
+	departedAt := ds.readTimeOrig + int64(config.AppConfig.PosDepartedThresholdMillis) + 1000
+	ds.setLastReadOnAll(departedAt)
+	ds.readAll(frontPos, rssiWeak, 1)
+	if err := ds.verifyStateAll(DepartedPos); err != nil {
+		t.Error(err)
+	}
+	ds.resetEvents()
+
+	// buffered reads from before the departure arrive late, and must be ignored
+	ds.setLastReadOnAll(departedAt - int64(config.AppConfig.LateReadToleranceMillis) - 5000)
+	ds.readAll(front, rssiStrong, 20)
+	if err := ds.verifyStateAll(DepartedPos); err != nil {
+		t.Error(err)
+	}
+	if err := ds.verifyNoEvents(); err != nil {
+		t.Error(err)
+	}
+	for i, tag := range ds.tags {
+		if tag.LastRead != departedAt {
+			t.Errorf("tag index %d (%s): last read moved from %d to %d", i, tag.Epc, departedAt, tag.LastRead)
+		}
+	}
+}
+
+func TestLateReadOrderedUpdatesStatsOnly(t *testing.T) {
+	lateReadPolicy := config.AppConfig.LateReadPolicy
+	config.AppConfig.LateReadPolicy = config.LateReadOrdered
+	defer func() { config.AppConfig.LateReadPolicy = lateReadPolicy }()
+
+	ds := newTestDataset(1)
+
+	back := generateTestSensor(backStock, sensor.NoPersonality)
+	front := generateTestSensor(salesFloor, sensor.NoPersonality)
+
+	ds.readAll(back, rssiMin, 1)
+	ds.updateTagRefs()
+	ds.resetEvents()
+
+	lastRead := ds.tags[0].LastRead
+	ds.setLastReadOnAll(lastRead - int64(config.AppConfig.LateReadToleranceMillis) - 5000)
+	ds.readAll(front, rssiStrong, 20)
+
+	// the late reads are counted, but the tag does not move or go back in time
+	if err := ds.verifyAll(Present, back); err != nil {
+		t.Error(err)
+	}
+	if err := ds.verifyNoEvents(); err != nil {
+		t.Error(err)
+	}
+	if ds.tags[0].LastRead != lastRead {
+		t.Errorf("last read moved from %d to %d", lastRead, ds.tags[0].LastRead)
+	}
+	stats, found := ds.tags[0].deviceStatsMap[front.AntennaAlias(0)]
+	if !found {
+		t.Fatal("expected late reads to be added to the sensor stats")
+	}
+	if stats.getCount() != 20 {
+		t.Errorf("expected 20 reads in the sensor stats, but was %d", stats.getCount())
+	}
+}
+
+func TestUpdateDeviceWatermark(t *testing.T) {
+	rsp := generateTestSensor(backStock, sensor.NoPersonality)
+	now := helper.UnixMilliNow()
+
+	updateDeviceWatermark(rsp.DeviceId, []jsonrpc.TagRead{{LastReadOn: now - 10}, {LastReadOn: now}})
+	if deviceWatermarks[rsp.DeviceId] != now {
+		t.Errorf("expected watermark %d, but was %d", now, deviceWatermarks[rsp.DeviceId])
+	}
+
+	// older data never moves the watermark back
+	updateDeviceWatermark(rsp.DeviceId, []jsonrpc.TagRead{{LastReadOn: now - 60000}})
+	if deviceWatermarks[rsp.DeviceId] != now {
+		t.Errorf("expected watermark %d, but was %d", now, deviceWatermarks[rsp.DeviceId])
+	}
+}
+
+func TestResetInventory(t *testing.T) {
+	first := newTestDataset(5)
+	second := newTestDataset(3)
+	other := newTestDataset(2)
+
+	firstSensor := generateTestSensor("ResetStore", sensor.NoPersonality)
+	secondSensor := generateTestSensor("ResetStore", sensor.NoPersonality)
+	otherSensor := generateTestSensor("OtherStore", sensor.NoPersonality)
+
+	first.readAll(firstSensor, rssiStrong, 1)
+	second.readAll(secondSensor, rssiStrong, 1)
+	other.readAll(otherSensor, rssiStrong, 1)
+
+	if count := ResetInventory("ResetStore", nil, true); count != 8 {
+		t.Errorf("expected 8 tags in the facility, but was %d", count)
+	}
+	if _, found := inventory[first.tagReads[0].Epc]; !found {
+		t.Error("expected a dry run to keep the tags")
+	}
+
+	if count := ResetInventory("ResetStore", []string{firstSensor.DeviceId}, false); count != 5 {
+		t.Errorf("expected 5 tags at the sensor, but was %d", count)
+	}
+	if _, found := inventory[first.tagReads[0].Epc]; found {
+		t.Error("expected the tags at the sensor to be forgotten")
+	}
+	if _, found := inventory[second.tagReads[0].Epc]; !found {
+		t.Error("expected the tags at other sensors to be kept")
+	}
+
+	if count := ResetInventory("ResetStore", nil, false); count != 3 {
+		t.Errorf("expected 3 remaining tags in the facility, but was %d", count)
+	}
+	if _, found := inventory[other.tagReads[0].Epc]; !found {
+		t.Error("expected the tags of other facilities to be kept")
+	}
+}
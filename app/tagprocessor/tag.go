@@ -7,6 +7,7 @@
 package tagprocessor
 
 import (
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/sensor"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
 )
@@ -66,15 +67,12 @@ func (tag *Tag) update(rsp *sensor.RSP, read *jsonrpc.TagRead, weighter *rssiAdj
 		tag.Tid = read.Tid
 	}
 
-	// update timestamp
-	tag.LastRead = read.LastReadOn
-
-	curStats, found := tag.deviceStatsMap[srcAlias]
-	if !found {
-		curStats = NewTagStats()
-		tag.deviceStatsMap[srcAlias] = curStats
+	// update timestamp, which only moves forward unless late reads are applied as received
+	if read.LastReadOn > tag.LastRead || !config.LateReadsGuarded() {
+		tag.LastRead = read.LastReadOn
 	}
-	curStats.update(read)
+
+	curStats := tag.updateStats(rsp, read)
 
 	if tag.Location == srcAlias {
 		// nothing to do
@@ -105,6 +103,26 @@ func (tag *Tag) update(rsp *sensor.RSP, read *jsonrpc.TagRead, weighter *rssiAdj
 	}
 }
 
+// isLateRead returns true if the read is older than the tag's current state
+// by more than the configured tolerance
+func (tag *Tag) isLateRead(read *jsonrpc.TagRead) bool {
+	return read.LastReadOn < tag.LastRead-int64(config.AppConfig.LateReadToleranceMillis)
+}
+
+// updateStats refreshes the signal statistics of the device alias that read the tag
+// without affecting the location, timestamps or state of the tag
+func (tag *Tag) updateStats(rsp *sensor.RSP, read *jsonrpc.TagRead) *TagStats {
+	srcAlias := rsp.AntennaAlias(read.AntennaId)
+
+	curStats, found := tag.deviceStatsMap[srcAlias]
+	if !found {
+		curStats = NewTagStats()
+		tag.deviceStatsMap[srcAlias] = curStats
+	}
+	curStats.update(read)
+	return curStats
+}
+
 func (tag *Tag) setState(newState TagState) {
 	tag.setStateAt(newState, tag.LastRead)
 }
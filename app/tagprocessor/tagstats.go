@@ -23,10 +23,14 @@ func NewTagStats() *TagStats {
 }
 
 func (stats *TagStats) update(read *jsonrpc.TagRead) {
-	if stats.LastRead != 0 {
-		stats.readInterval.AddValue(float64(read.LastReadOn - stats.LastRead))
+	// LastRead acts as the watermark for this device, so an out-of-order read
+	// only contributes its signal strength and never moves the watermark back
+	if read.LastReadOn >= stats.LastRead {
+		if stats.LastRead != 0 {
+			stats.readInterval.AddValue(float64(read.LastReadOn - stats.LastRead))
+		}
+		stats.LastRead = read.LastReadOn
 	}
-	stats.LastRead = read.LastReadOn
 
 	mw := rssiToMilliwatts(float64(read.Rssi) / 10.0)
 	stats.rssiMw.AddValue(mw)
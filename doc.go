@@ -45,3 +45,13 @@ type externalServiceTimeout struct {
 //swagger:response internalError
 type internalError struct {
 }
+
+// Not Found
+//swagger:response notFound
+type notFound struct {
+}
+
+// Conflict
+//swagger:response conflict
+type conflict struct {
+}
@@ -18,18 +18,29 @@ import (
 	"github.com/edgexfoundry/app-functions-sdk-go/pkg/transforms"
 	"github.com/edgexfoundry/go-mod-core-contracts/models"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/alert"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/cloudconnector"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/cloudconnector/event"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/dailyturn"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/estimation"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/facility"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/heartbeat"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/missing"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/qualifiedstate"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/routes"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/routes/handlers"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/rules"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/sensor"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagprocessor"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/migration"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/statemodel"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
 	reporter "github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics-influxdb"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -43,6 +54,8 @@ import (
 
 const (
 	serviceKey = "inventory-service"
+	// migrateCommand is the command line argument which exits once the database is migrated
+	migrateCommand = "migrate"
 )
 
 const (
@@ -57,6 +70,9 @@ const (
 	controllerReady          = "controller_ready"
 )
 
+// probablyMissingControllerID is the controller id of the probably missing events, which no controller read
+const probablyMissingControllerID = "inventory-service"
+
 var (
 	// Filter data by value descriptors (aka device resource name)
 	valueDescriptors = []string{
@@ -134,11 +150,21 @@ func main() {
 	defer db.Close()
 	mDbConnection.Update(1)
 
+	// The migrate command only migrates the database, e.g. before rolling out a new version to a fleet
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		log.WithFields(log.Fields{"Method": "main", "Action": "Migrate"}).Info("Database migrated.")
+		return
+	}
+
 	// Verify Intel Architecture(IA) when using Probabilistic Algorithm plugin
 	if config.AppConfig.ProbabilisticAlgorithmPlugin {
 		verifyProbabilisticPlugin()
 	}
 
+	if err := handlers.SelectConfidenceModel(config.AppConfig.ConfidenceModel); err != nil {
+		log.Warnf("%s All Confidence values will be set to 0.", err.Error())
+	}
+
 	invApp := newInventoryApp(db)
 
 	// Connect to EdgeX zeroMQ bus
@@ -227,6 +253,8 @@ func startWebServer(masterDB *sql.DB, port string, responseLimit int, serviceNam
 // with config.AppConfig.AdvancedShippingNotice
 func processShippingNotice(data []byte, masterDB *sql.DB, tagsGauge *metrics.GaugeCollection) error {
 
+	mVersionConflict := metrics.GetOrRegisterGauge(`Inventory.ProcessShippingNotice.Version-Conflict`, nil)
+
 	var incomingDataSlice []tag.AdvanceShippingNotice
 	decoder := json.NewDecoder(bytes.NewBuffer(data))
 	if err := decoder.Decode(&incomingDataSlice); err != nil {
@@ -236,8 +264,6 @@ func processShippingNotice(data []byte, masterDB *sql.DB, tagsGauge *metrics.Gau
 	// do this before inserting the data into the database
 	dailyturn.ProcessIncomingASNList(masterDB, incomingDataSlice)
 
-	var tagData []tag.Tag
-
 	for _, asn := range incomingDataSlice {
 		if asn.ID == "" || asn.EventTime == "" || asn.SiteID == "" || asn.Items == nil {
 			return errors.New("ASN is missing data")
@@ -246,69 +272,115 @@ func processShippingNotice(data []byte, masterDB *sql.DB, tagsGauge *metrics.Gau
 			(*tagsGauge).Add(int64(len(asn.Items)))
 		}
 
-		for _, asnItem := range asn.Items {
-			for _, asnEpc := range asnItem.EPCs {
-				// create a temporary tag so we can check if it's whitelisted
-				tempTag := tag.Tag{}
-				tempTag.Epc = asnEpc
-				tempTag.ProductID, tempTag.URI, _ = tag.DecodeTagData(asnEpc)
-				// TODO: why aren't we checking for invalid tag encodings?
-
-				if len(config.AppConfig.EpcFilters) > 0 {
-					// ignore tags that don't match our filters
-					if !statemodel.IsTagWhitelisted(tempTag.Epc, config.AppConfig.EpcFilters) {
-						continue
-					}
-				}
+		// The tags are written back only if none was written since it was read, otherwise they are read again
+		for attempt := 0; ; attempt++ {
+			tagData, err := shippingNoticeTags(masterDB, asn)
+			if err != nil {
+				return err
+			}
+			if len(tagData) == 0 {
+				break
+			}
 
-				// marshal the ASNContext
-				asnContextBytes, err := json.Marshal(tag.ASNContext{
-					ASNID:     asn.ID,
-					EventTime: asn.EventTime,
-					SiteID:    asn.SiteID,
-					ItemGTIN:  asnItem.ItemGTIN,
-					ItemID:    asnItem.ItemID,
-				})
-				if err != nil {
-					return errors.Wrap(err, "Unable to marshal ASNContext")
+			err = tag.ReplaceIfUnchanged(masterDB, tagData, nil)
+			if err == nil {
+				break
+			}
+			if errors.Cause(err) != web.ErrPreconditionFailed || attempt >= config.AppConfig.VersionConflictRetries {
+				return errors.Wrap(err, "error replacing tags")
+			}
+			mVersionConflict.Update(1)
+			log.Debugf("Retrying shipping notice tags: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// shippingNoticeTags returns the tags of the shipping notice, with their epc context set to the shipping notice
+func shippingNoticeTags(masterDB *sql.DB, asn tag.AdvanceShippingNotice) ([]tag.Tag, error) {
+	var tagData []tag.Tag
+
+	for _, asnItem := range asn.Items {
+		for _, asnEpc := range asnItem.EPCs {
+			// create a temporary tag so we can check if it's whitelisted
+			tempTag := tag.Tag{}
+			tempTag.Epc = asnEpc
+			tempTag.ProductID, tempTag.URI, _ = tag.DecodeTagData(asnEpc)
+			// TODO: why aren't we checking for invalid tag encodings?
+
+			if len(config.AppConfig.EpcFilters) > 0 {
+				// ignore tags that don't match our filters
+				if !statemodel.IsTagWhitelisted(tempTag.Epc, config.AppConfig.EpcFilters) {
+					continue
 				}
+			}
 
-				// If the tag exists, update it with the new EPCContext.
-				// If it is new, insert it with default FacilityID
-				// Note: If bottlenecks may need to redesign to eliminate large number
-				// of queries to DB currently this will make a call to the DB PER tag
-				tagFromDB, err := tag.FindByEpc(masterDB, tempTag.Epc)
-				if err != nil {
-					if dbErr := errors.Wrap(err, "Error retrieving tag from database"); dbErr != nil {
-						log.Debug(dbErr)
-					}
+			// marshal the ASNContext
+			asnContextBytes, err := json.Marshal(tag.ASNContext{
+				ASNID:     asn.ID,
+				EventTime: asn.EventTime,
+				SiteID:    asn.SiteID,
+				ItemGTIN:  asnItem.ItemGTIN,
+				ItemID:    asnItem.ItemID,
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "Unable to marshal ASNContext")
+			}
+
+			// If the tag exists, update it with the new EPCContext.
+			// If it is new, insert it with default FacilityID
+			// Note: If bottlenecks may need to redesign to eliminate large number
+			// of queries to DB currently this will make a call to the DB PER tag
+			tagFromDB, err := tag.FindByEpc(masterDB, tempTag.Epc)
+			if err != nil {
+				if dbErr := errors.Wrap(err, "Error retrieving tag from database"); dbErr != nil {
+					log.Debug(dbErr)
+				}
+			} else {
+				if tagFromDB.IsEmpty() {
+					// Tag is not in database, add with defaults
+					tempTag.FacilityID = config.AppConfig.AdvancedShippingNoticeFacilityID
+					tempTag.EpcContext = string(asnContextBytes)
+					tagData = append(tagData, tempTag)
 				} else {
-					if tagFromDB.IsEmpty() {
-						// Tag is not in database, add with defaults
-						tempTag.FacilityID = config.AppConfig.AdvancedShippingNoticeFacilityID
-						tempTag.EpcContext = string(asnContextBytes)
-						tagData = append(tagData, tempTag)
-					} else {
-						// Found tag, only update the epc context
-						tagFromDB.EpcContext = string(asnContextBytes)
-						tagData = append(tagData, tagFromDB)
-					}
+					// Found tag, only update the epc context
+					tagFromDB.EpcContext = string(asnContextBytes)
+					tagData = append(tagData, tagFromDB)
 				}
 			}
 		}
-		if len(tagData) > 0 {
-			if err := tag.Replace(masterDB, tagData); err != nil {
-				return errors.Wrap(err, "error replacing tags")
-			}
-		}
 	}
 
-	return nil
+	return tagData, nil
 }
 
 func callDeleteTagCollection(masterDB *sql.DB) error {
 	log.Debug("received request to delete tag db collection...")
-	return tag.DeleteTagCollection(masterDB)
+	if err := tag.DeleteTagCollection(masterDB); err != nil {
+		return err
+	}
+	tagprocessor.ResetInventory("", nil, false)
+	return nil
+}
+
+// resetFacilityOfSensor resets the tags of the facility the sensor is in
+func resetFacilityOfSensor(masterDB *sql.DB, deviceID string) error {
+	log.Debugf("received request to reset the facility of sensor %s...", deviceID)
+	rsp, err := sensor.FindRSP(masterDB, deviceID)
+	if err != nil {
+		return err
+	}
+	if rsp == nil {
+		return errors.Errorf("unable to reset the facility of unknown sensor %s", deviceID)
+	}
+
+	result, err := handlers.ResetInventory(masterDB, tag.ResetRequest{FacilityID: rsp.FacilityId})
+	if err != nil {
+		return err
+	}
+	log.Infof("reset facility %s: deleted %d tags, forgot %d tags", rsp.FacilityId, result.Deleted, result.Forgotten)
+	return nil
 }
 
 // POC only implementation
@@ -453,6 +525,10 @@ func (invApp *inventoryApp) processEvents(edgexcontext *appcontext.Context, para
 				return false, errors.Wrapf(err, "unable to upsert sensor config notification for sensor %s", notification.Params.DeviceId)
 			}
 
+			if err := facility.Register(invApp.masterDB, notification.Params.FacilityId); err != nil {
+				return false, errors.Wrapf(err, "unable to register facility of sensor %s", notification.Params.DeviceId)
+			}
+
 		case schedulerRunState:
 			log.Debugf("Received scheduler run state notification:\n%s", reading.Value)
 
@@ -472,6 +548,11 @@ func (invApp *inventoryApp) processEvents(edgexcontext *appcontext.Context, para
 				return false, err
 			}
 
+			// the tags are processed even if their facility cannot be registered
+			if err := facility.Register(invApp.masterDB, invData.Params.FacilityId); err != nil {
+				log.Warnf("unable to register facility of sensor %s: %s", invData.Params.DeviceId, err)
+			}
+
 			invEvent, err := tagprocessor.ProcessInventoryData(invApp.masterDB, invData)
 			if err != nil {
 				return false, err
@@ -489,10 +570,11 @@ func (invApp *inventoryApp) processEvents(edgexcontext *appcontext.Context, para
 
 			if rrsAlert.IsInventoryUnloadAlert() {
 				mRRSResetEventReceived.Add(1)
+				// only the facility of the sensor is unloaded
 				go func(errorGauge *metrics.Gauge) {
-					err := callDeleteTagCollection(invApp.masterDB)
+					err := resetFacilityOfSensor(invApp.masterDB, rrsAlert.DeviceId)
 					if err != nil {
-						errorHandler("error calling delete tag collection", err, errorGauge)
+						errorHandler("error resetting the facility of the sensor", err, errorGauge)
 						return
 					}
 
@@ -547,6 +629,10 @@ func (invApp *inventoryApp) processInventoryEventChannel() {
 func (invApp *inventoryApp) processScheduledTasks() {
 	aggregateDepartedTicker := time.NewTicker(time.Duration(config.AppConfig.AggregateDepartedThresholdMillis/5) * time.Millisecond)
 	ageoutTicker := time.NewTicker(1 * time.Hour)
+	purgeTicker := time.NewTicker(time.Duration(config.AppConfig.PurgingIntervalHours) * time.Hour)
+	probablyMissingTicker := time.NewTicker(time.Duration(config.AppConfig.ProbablyMissingIntervalMinutes) * time.Minute)
+	estimationTicker := time.NewTicker(time.Duration(config.AppConfig.CoefficientEstimationIntervalHours) * time.Hour)
+	checkpointTicker := time.NewTicker(time.Duration(config.AppConfig.JournalCheckpointIntervalHours) * time.Hour)
 
 	for {
 		select {
@@ -554,6 +640,10 @@ func (invApp *inventoryApp) processScheduledTasks() {
 			log.Info("done called. stopping scheduled tasks")
 			aggregateDepartedTicker.Stop()
 			ageoutTicker.Stop()
+			purgeTicker.Stop()
+			probablyMissingTicker.Stop()
+			estimationTicker.Stop()
+			checkpointTicker.Stop()
 			return
 
 		case t := <-aggregateDepartedTicker.C:
@@ -565,38 +655,271 @@ func (invApp *inventoryApp) processScheduledTasks() {
 		case t := <-ageoutTicker.C:
 			log.Debugf("DoAgeoutTask: %v", t)
 			tagprocessor.DoAgeoutTask()
+
+		case t := <-purgeTicker.C:
+			if config.AppConfig.ArchiveDepartedDays > 0 {
+				log.Debugf("archiveDepartedTags: %v", t)
+				invApp.archiveDepartedTags()
+				invApp.purgeArchivedTags()
+			} else {
+				log.Debugf("purgeDepartedTags: %v", t)
+				invApp.purgeDepartedTags()
+			}
+
+		case t := <-probablyMissingTicker.C:
+			log.Debugf("sendProbablyMissingEvents: %v", t)
+			invApp.sendProbablyMissingEvents()
+
+		case t := <-estimationTicker.C:
+			log.Debugf("estimateCoefficients: %v", t)
+			invApp.estimateCoefficients()
+
+		case t := <-checkpointTicker.C:
+			log.Debugf("checkpointJournal: %v", t)
+			invApp.checkpointJournal()
 		}
 	}
 }
 
-func (invApp *inventoryApp) pushEventsToCoreData(sentOn int64, controllerId string, tagEvents []tag.Tag) {
-	if len(tagEvents) > 0 {
-		log.Debugf("%+v", tagEvents)
-
-		payload, err := json.Marshal(event.DataPayload{
-			SentOn:             sentOn,
-			ControllerId:       controllerId,
-			EventSegmentNumber: 1,
-			TotalEventSegments: 1,
-			TagEvent:           tagEvents,
-		})
+// archiveDepartedTags moves the departed tags which were last read more than ArchiveDepartedDays ago
+// to the archive, where they are kept until they are purged from it
+func (invApp *inventoryApp) archiveDepartedTags() {
+	cutoffs, err := handlers.PurgeCutoffs(invApp.masterDB, helper.UnixMilliNow(), config.AppConfig.ArchiveDepartedDays)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "archiveDepartedTags",
+			"Action": "Find the business days of the facilities",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	journal, err := tagevent.RemovalJournal(invApp.masterDB, tagevent.ArchiveSource)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "archiveDepartedTags",
+			"Action": "Prepare the journal of the archived tags",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	archived, err := tag.Archive(invApp.masterDB, cutoffs, config.AppConfig.PurgingBatchSize, journal)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "archiveDepartedTags",
+			"Action": "Archive departed tags",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	log.Infof("Archived %d departed tags last read before %d", archived, cutoffs.Default)
+}
+
+// purgeDepartedTags deletes the departed tags which were last read more than PurgingDays ago.
+// A PurgingDays of 0 or less disables purging.
+func (invApp *inventoryApp) purgeDepartedTags() {
+	if config.AppConfig.PurgingDays <= 0 {
+		return
+	}
+
+	cutoffs, err := handlers.PurgeCutoffs(invApp.masterDB, helper.UnixMilliNow(), config.AppConfig.PurgingDays)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "purgeDepartedTags",
+			"Action": "Find the business days of the facilities",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	journal, err := tagevent.RemovalJournal(invApp.masterDB, tagevent.PurgeSource)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "purgeDepartedTags",
+			"Action": "Prepare the journal of the purged tags",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	purged, err := tag.Purge(invApp.masterDB, cutoffs, config.AppConfig.PurgingBatchSize, journal)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "purgeDepartedTags",
+			"Action": "Purge departed tags",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	log.Infof("Purged %d departed tags last read before %d", purged, cutoffs.Default)
+}
+
+// purgeArchivedTags deletes the archived tags which were last read more than ArchiveRetentionDays ago.
+// An ArchiveRetentionDays of 0, the default, keeps the archived tags forever.
+func (invApp *inventoryApp) purgeArchivedTags() {
+	if config.AppConfig.ArchiveRetentionDays <= 0 {
+		return
+	}
+
+	cutoffs, err := handlers.PurgeCutoffs(invApp.masterDB, helper.UnixMilliNow(), config.AppConfig.ArchiveRetentionDays)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "purgeArchivedTags",
+			"Action": "Find the business days of the facilities",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	purged, err := tag.PurgeArchive(invApp.masterDB, cutoffs, config.AppConfig.PurgingBatchSize)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "purgeArchivedTags",
+			"Action": "Purge archived tags",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	log.Infof("Purged %d archived tags last read before %d", purged, cutoffs.Default)
+}
+
+// checkpointJournal journals the state of every present tag, so that the inventory as of a later time is
+// rebuilt from the journal since then
+func (invApp *inventoryApp) checkpointJournal() {
+	journaled, err := tagevent.Checkpoint(invApp.masterDB)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "checkpointJournal",
+			"Action": "Checkpoint the tag event journal",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	log.Infof("Checkpointed %d present tags in the tag event journal", journaled)
+}
+
+// estimateCoefficients estimates the read probabilities of the facilities from the reads of the last
+// CoefficientEstimationDays, and applies them if ApplyEstimatedCoefficients. A CoefficientEstimationDays of 0
+// disables the estimation.
+func (invApp *inventoryApp) estimateCoefficients() {
+	if config.AppConfig.CoefficientEstimationDays <= 0 {
+		return
+	}
+
+	estimates, changes, err := estimation.Run(invApp.masterDB, helper.UnixMilliNow())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "estimateCoefficients",
+			"Action": "Estimate coefficients",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+	log.Infof("Estimated %d coefficients, applied to %d facilities", len(estimates), len(changes))
+}
+
+// sendProbablyMissingEvents sends the probably_missing events of the present tags whose confidence fell below
+// the threshold of their facility, and the recovered events of the probably missing tags read again, to
+// core data, the rules service and the cloud connector. The tags are only flagged once their events are sent,
+// so that events which failed to be sent are sent again on the next run.
+func (invApp *inventoryApp) sendProbablyMissingEvents() {
+	evaluation, err := handlers.DetectProbablyMissing(invApp.masterDB, invApp.skuMapping.url)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Method": "sendProbablyMissingEvents",
+			"Action": "Detect probably missing tags",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+		return
+	}
+
+	sent := true
+	if events := evaluation.Events(); len(events) > 0 {
+		log.Infof("%d tags probably missing, %d recovered", len(evaluation.Missing), len(evaluation.Recovered))
+		sent = invApp.sendEvaluationEvents(evaluation, events)
+	}
+	if !sent {
+		log.Warn("Probably missing events not sent, they are sent again on the next run")
+		return
+	}
+
+	if err := missing.Save(invApp.masterDB, evaluation); err != nil {
+		log.WithFields(log.Fields{
+			"Method": "sendProbablyMissingEvents",
+			"Action": "Save probably missing tags",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
+	}
+}
+
+// sendEvaluationEvents sends the events of the evaluation to all the destinations, and reports whether they
+// were all sent
+func (invApp *inventoryApp) sendEvaluationEvents(evaluation *missing.Evaluation, events []tag.Tag) bool {
+	sent := true
+
+	if config.AppConfig.CloudConnectorUrl != "" {
+		if err := cloudconnector.SendTagEvents(probablyMissingControllerID, events); err != nil {
+			sent = false
+			log.WithFields(log.Fields{
+				"Method": "sendProbablyMissingEvents",
+				"Action": "Trigger Cloud Connector",
+				"Error":  err.Error(),
+			}).Error(err)
+		}
+	}
+
+	if config.AppConfig.RulesUrl != "" {
+		definitions, err := qualifiedstate.CreateDefinitionMap(invApp.masterDB)
 		if err != nil {
+			sent = false
 			log.WithFields(log.Fields{
-				"Method": "pushEventsToCoreData",
-				"Action": "Publish Events to Core Data",
+				"Method": "sendProbablyMissingEvents",
+				"Action": "Retrieve qualified state definitions",
+				"Error":  fmt.Sprintf("%+v", err),
+			}).Error(err)
+		} else if err := rules.TriggerRules(config.AppConfig.RulesUrl+config.AppConfig.TriggerRulesEndpoint+"?ruletype="+tag.StateChangeEvent,
+			withoutBlockedAlerts(definitions, evaluation.StateChanges)); err != nil {
+			sent = false
+			log.WithFields(log.Fields{
+				"Method": "sendProbablyMissingEvents",
+				"Action": "Trigger Rules",
 				"Error":  fmt.Sprintf("%+v", err),
 			}).Error(err)
-			return
 		}
+	}
 
-		if invApp.edgexSdkContext == nil {
-			log.Error("unable to push event to core data due to app-functions-sdk context has not been grabbed yet")
-			return
-		}
-		if _, err = invApp.edgexSdkContext.PushToCoreData(controllerId, inventoryEvent, string(payload)); err != nil {
-			log.Errorf("unable to push inventory event to core-data: %v", err)
-		}
+	if err := invApp.pushEventsToCoreData(helper.UnixMilliNow(), probablyMissingControllerID, events); err != nil {
+		sent = false
+		log.WithFields(log.Fields{
+			"Method": "sendProbablyMissingEvents",
+			"Action": "Publish Events to Core Data",
+			"Error":  fmt.Sprintf("%+v", err),
+		}).Error(err)
 	}
+
+	return sent
+}
+
+func (invApp *inventoryApp) pushEventsToCoreData(sentOn int64, controllerId string, tagEvents []tag.Tag) error {
+	if len(tagEvents) == 0 {
+		return nil
+	}
+	log.Debugf("%+v", tagEvents)
+
+	payload, err := json.Marshal(event.DataPayload{
+		SentOn:             sentOn,
+		ControllerId:       controllerId,
+		EventSegmentNumber: 1,
+		TotalEventSegments: 1,
+		TagEvent:           tagEvents,
+	})
+	if err != nil {
+		return err
+	}
+
+	if invApp.edgexSdkContext == nil {
+		return errors.New("app-functions-sdk context has not been grabbed yet")
+	}
+	if _, err = invApp.edgexSdkContext.PushToCoreData(controllerId, inventoryEvent, string(payload)); err != nil {
+		return errors.Wrap(err, "unable to push inventory event to core-data")
+	}
+	return nil
 }
 
 func dbSetup(host, port, user, password, dbname, sslmode string) (*sql.DB, error) {
@@ -619,9 +942,21 @@ func dbSetup(host, port, user, password, dbname, sslmode string) (*sql.DB, error
 	log.Info("Connected to postgreSQL database...")
 
 	// Create tables and indexes
-	_, errExec := db.Exec(config.DbSchema)
-	if errExec != nil {
-		return nil, errExec
+	applied, err := migration.Apply(db, config.Migrations)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) > 0 {
+		log.Infof("Applied schema migrations %v", applied)
+	}
+
+	// The tags present before the tag event journal was kept are journaled once
+	baseline, err := tagevent.EnsureBaseline(db)
+	if err != nil {
+		return nil, err
+	}
+	if baseline {
+		log.Info("Journaled the baseline of the present tags")
 	}
 
 	return db, nil
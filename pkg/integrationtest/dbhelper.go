@@ -21,6 +21,7 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/migration"
 	"github.com/sirupsen/logrus"
 	"log"
 	"strings"
@@ -32,7 +33,7 @@ type TestDB struct {
 	DB     *sql.DB
 	dbName string
 	dbHost *DBHost
-	t      *testing.T
+	t      testing.TB
 }
 
 type DBHost struct {
@@ -59,7 +60,7 @@ func InitHost(name string) DBHost {
 var dbNamesToInstances = map[string]int{}
 var dbNameLock = sync.Mutex{}
 
-func (dbHost *DBHost) CreateDB(t *testing.T) TestDB {
+func (dbHost *DBHost) CreateDB(t testing.TB) TestDB {
 	t.Helper()
 
 	if testing.Short() {
@@ -109,7 +110,7 @@ func (dbHost *DBHost) CreateDB(t *testing.T) TestDB {
 	}
 
 	// Creation of tables and indexes
-	if _, err = db.Exec(config.DbSchema); err != nil {
+	if _, err = migration.Apply(db, config.Migrations); err != nil {
 		t.Fatalf("Unable to create to db tables and indexes for: %s: %v", dbName, err)
 	}
 
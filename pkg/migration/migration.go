@@ -0,0 +1,180 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package migration applies ordered, versioned schema migrations to a PostgreSQL database.
+// The versions applied are recorded in the schema_migrations table, and a session advisory lock
+// is held while migrating so that concurrent instances of the service do not race: the first one
+// applies the pending migrations, the others wait and then find nothing left to apply.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	"github.com/pkg/errors"
+)
+
+// lockKey identifies the advisory lock held while migrating
+const lockKey = 0x696e76656e746f72 // "inventor"
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`
+
+// Migration is a change of schema. Once released, a migration must never be modified:
+// changes go in a new migration with a greater version.
+type Migration struct {
+	// Version orders the migrations, starting at 1
+	Version int
+	// Description is recorded along with the version
+	Description string
+	// Up holds the SQL statements applying the migration, all run in one transaction
+	Up string
+	// NoTransaction runs the statements of Up one at a time outside of a transaction, as required by
+	// CREATE INDEX CONCURRENTLY. Each statement must end a line with a semicolon, and must be safe to run
+	// again: the migration is only recorded once all of them succeeded.
+	NoTransaction bool
+}
+
+// Validate checks that the migrations are ordered by strictly increasing, positive versions
+func Validate(migrations []Migration) error {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version <= previous {
+			return errors.Errorf("migration %d (%s) must have a version greater than %d",
+				migration.Version, migration.Description, previous)
+		}
+		if migration.Up == "" {
+			return errors.Errorf("migration %d (%s) is empty", migration.Version, migration.Description)
+		}
+		previous = migration.Version
+	}
+	return nil
+}
+
+// Apply applies the migrations which are not recorded in schema_migrations yet, in order, each in its own
+// transaction unless flagged NoTransaction, and returns the versions it applied
+func Apply(db *sql.DB, migrations []Migration) ([]int, error) {
+
+	// Metrics
+	metrics.GetOrRegisterGauge(`Inventory.Migration.Apply.Attempt`, nil).Update(1)
+	mSuccess := metrics.GetOrRegisterGauge(`Inventory.Migration.Apply.Success`, nil)
+	mApplyErr := metrics.GetOrRegisterGauge(`Inventory.Migration.Apply.Apply-Error`, nil)
+	mApplied := metrics.GetOrRegisterGauge(`Inventory.Migration.Apply.Applied`, nil)
+
+	if err := Validate(migrations); err != nil {
+		return nil, err
+	}
+
+	// advisory locks belong to a session, so the lock and the migrations use the same connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get a connection to migrate")
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return nil, errors.Wrap(err, "unable to acquire the migration lock")
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey) //nolint:errcheck
+
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, errors.Wrap(err, "unable to create the schema_migrations table")
+	}
+
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int
+	for _, migration := range migrations {
+		if done[migration.Version] {
+			continue
+		}
+		if err := apply(ctx, conn, migration); err != nil {
+			mApplyErr.Update(1)
+			return applied, err
+		}
+		applied = append(applied, migration.Version)
+	}
+
+	mApplied.Update(int64(len(applied)))
+	mSuccess.Update(1)
+	return applied, nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the applied migrations")
+	}
+	defer rows.Close()
+
+	versions := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+	return versions, rows.Err()
+}
+
+func apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if migration.NoTransaction {
+		return applyWithoutTransaction(ctx, conn, migration)
+	}
+
+	transaction, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+
+	if _, err := transaction.ExecContext(ctx, migration.Up); err != nil {
+		_ = transaction.Rollback()
+		return errors.Wrap(err, fmt.Sprintf("error applying migration %d (%s)", migration.Version, migration.Description))
+	}
+	if _, err := transaction.ExecContext(ctx, "INSERT INTO schema_migrations (version, description) VALUES ($1, $2)",
+		migration.Version, migration.Description); err != nil {
+		_ = transaction.Rollback()
+		return errors.Wrapf(err, "error recording migration %d", migration.Version)
+	}
+
+	return errors.Wrapf(transaction.Commit(), "error committing migration %d", migration.Version)
+}
+
+func applyWithoutTransaction(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	for _, statement := range statements(migration.Up) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error applying migration %d (%s)", migration.Version, migration.Description))
+		}
+	}
+	if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, description) VALUES ($1, $2)",
+		migration.Version, migration.Description); err != nil {
+		return errors.Wrapf(err, "error recording migration %d", migration.Version)
+	}
+	return nil
+}
+
+// statements splits the SQL on the semicolons ending a line, as statements run outside of a transaction
+// must be sent one at a time
+func statements(up string) []string {
+	var result []string
+	for _, statement := range strings.SplitAfter(up, ";\n") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			result = append(result, statement)
+		}
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package migration
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	valid := []Migration{
+		{Version: 1, Description: "baseline", Up: "SELECT 1;"},
+		{Version: 3, Description: "gap", Up: "SELECT 1;"},
+	}
+	if err := Validate(valid); err != nil {
+		t.Errorf("expected valid migrations: %v", err)
+	}
+
+	for name, migrations := range map[string][]Migration{
+		"unordered": {{Version: 2, Up: "SELECT 1;"}, {Version: 1, Up: "SELECT 1;"}},
+		"duplicate": {{Version: 1, Up: "SELECT 1;"}, {Version: 1, Up: "SELECT 1;"}},
+		"zero":      {{Version: 0, Up: "SELECT 1;"}},
+		"empty":     {{Version: 1}},
+	} {
+		if err := Validate(migrations); err == nil {
+			t.Errorf("expected %s migrations to be invalid", name)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	up := `
+-- first index
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_a
+ON a ((data->>'a'));
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_b ON b ((data->>'b'));
+SELECT 'no; split'`
+
+	expected := []string{
+		"-- first index\nCREATE INDEX CONCURRENTLY IF NOT EXISTS idx_a\nON a ((data->>'a'));",
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_b ON b ((data->>'b'));",
+		"SELECT 'no; split'",
+	}
+	actual := statements(up)
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d statements, got %q", len(expected), actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("expected statement %q, got %q", expected[i], actual[i])
+		}
+	}
+}
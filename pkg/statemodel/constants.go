@@ -17,6 +17,10 @@ const (
 	DepartedEvent = "departed"
 	//ReturnedEvent is the constant for the returned event
 	ReturnedEvent = "returned"
+	//ProbablyMissingEvent is the constant for the event of a present tag whose confidence fell below the threshold
+	ProbablyMissingEvent = "probably_missing"
+	//RecoveredEvent is the constant for the event of a probably missing tag read again
+	RecoveredEvent = "recovered"
 	//UnknownQualifiedState is the constant for the qualified state to be set initially
 	UnknownQualifiedState = "unknown"
 	//PresentEpcState is the constant for epc state of present
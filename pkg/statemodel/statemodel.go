@@ -10,6 +10,7 @@ import (
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
 	"strings"
 )
 
@@ -50,13 +51,18 @@ func UpdateTag(currentState tag.Tag, newTagEvent jsonrpc.TagEvent, source string
 		currentState.QualifiedState = UnknownQualifiedState
 	} else {
 		// Not a new TAG
-		if config.AppConfig.NewerHandheldHavePriority &&
-			source == "fixed" && currentState.Source == "handheld" &&
-			currentState.LastRead > newTagEvent.Timestamp {
+		if hasNewerHandheldRead(currentState, newTagEvent, source) {
 			// Skip updating existing newer handheld tag with incoming older fixed tag
 			return currentState
 		}
 
+		if isLateEvent(currentState, newTagEvent) {
+			// Skip events older than the tag's current state, such as buffered data
+			// arriving after a network outage, so the tag never moves back in time
+			metrics.GetOrRegisterGaugeCollection("Inventory.UpdateTag.LateEvent-Ignored", nil).Add(1)
+			return currentState
+		}
+
 		//if any existing tags do not have a qualified state
 		//update it with the "unknown" value
 		if len(currentState.QualifiedState) == 0 {
@@ -175,8 +181,37 @@ func AddLocationIfNew(locationHistory []tag.LocationHistory, locationToAdd tag.L
 	return locationHistory
 }
 
+// IsIgnored determines if the event leaves the existing tag unchanged, either because a newer handheld
+// read has priority over it or because it is late, so that it is not journaled as a change of the tag
+func IsIgnored(currentState tag.Tag, newTagEvent jsonrpc.TagEvent, source string) bool {
+	if currentState.IsEmpty() || currentState.IsShippingNoticeEntry() {
+		return false
+	}
+	return hasNewerHandheldRead(currentState, newTagEvent, source) || isLateEvent(currentState, newTagEvent)
+}
+
+// hasNewerHandheldRead determines if the tag was read by a handheld after the fixed read of the event
+// and handheld reads have priority
+func hasNewerHandheldRead(currentState tag.Tag, newTagEvent jsonrpc.TagEvent, source string) bool {
+	return config.AppConfig.NewerHandheldHavePriority &&
+		source == "fixed" && currentState.Source == "handheld" &&
+		currentState.LastRead > newTagEvent.Timestamp
+}
+
+// isLateEvent determines if the event is older than the last read of the tag by
+// more than the configured tolerance. Events are never late when the late read
+// policy is to apply them as they are received.
+func isLateEvent(currentState tag.Tag, newTagEvent jsonrpc.TagEvent) bool {
+	if !config.LateReadsGuarded() {
+		return false
+	}
+	return newTagEvent.Timestamp < currentState.LastRead-int64(config.AppConfig.LateReadToleranceMillis)
+}
+
 func getBestLastRead(currentLastRead int64, newLastRead int64, currentSource string, newSource string) int64 {
-	if currentSource == newSource && currentLastRead > newLastRead {
+	// an older read from a different source only wins when late reads are applied as received
+	if currentLastRead > newLastRead &&
+		(currentSource == newSource || config.LateReadsGuarded()) {
 		return currentLastRead
 	}
 
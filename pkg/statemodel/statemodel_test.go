@@ -14,6 +14,7 @@ import (
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
 	"github.com/lib/pq"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -302,9 +303,12 @@ func TestUpdateTag_HHPriorityNewerFixed(t *testing.T) {
 }
 
 func TestUpdateTag_NotHHPriorityOlderFixed(t *testing.T) {
-	// HH has no priority, so any fixed tag will overwrite.
+	// HH has no priority, so any fixed tag will overwrite when late reads are applied.
 
 	config.AppConfig.NewerHandheldHavePriority = false
+	lateReadPolicy := config.AppConfig.LateReadPolicy
+	config.AppConfig.LateReadPolicy = config.LateReadApply
+	defer func() { config.AppConfig.LateReadPolicy = lateReadPolicy }()
 
 	currentTagState := getHelperTag()
 	currentTagState.Source = "handheld"
@@ -408,6 +412,10 @@ func TestLastReadFixedOldLastRead(t *testing.T) {
 }
 
 func TestLastReadNewFixedOldHandheld(t *testing.T) {
+	lateReadPolicy := config.AppConfig.LateReadPolicy
+	config.AppConfig.LateReadPolicy = config.LateReadApply
+	defer func() { config.AppConfig.LateReadPolicy = lateReadPolicy }()
+
 	// Default values created are new last read > current last read
 	currentTagState := getHelperTag()
 	newTagEvent := getHelperTagEvent()
@@ -421,6 +429,68 @@ func TestLastReadNewFixedOldHandheld(t *testing.T) {
 	}
 }
 
+func TestUpdateTag_IgnoreLateEvent(t *testing.T) {
+	lateReadPolicy := config.AppConfig.LateReadPolicy
+	config.AppConfig.LateReadPolicy = config.LateReadIgnore
+	defer func() { config.AppConfig.LateReadPolicy = lateReadPolicy }()
+
+	currentTagState := getHelperTag()
+	currentTagState.Event = DepartedEvent
+	currentTagState.EpcState = DepartedEpcState
+
+	// a buffered read from before the departure must not undo it
+	newTagEvent := getHelperTagEvent()
+	newTagEvent.EventType = MovedEvent
+	newTagEvent.Location = "Back"
+	newTagEvent.Timestamp = helper.UnixMilli(existingTagTime.Add(-time.Hour))
+
+	tagState := UpdateTag(currentTagState, newTagEvent, "handheld")
+
+	if tagState.EpcState != DepartedEpcState {
+		t.Errorf("Expected epc state %s, received %s", DepartedEpcState, tagState.EpcState)
+	}
+	if tagState.LastRead != currentTagState.LastRead {
+		t.Error("tagState LastRead should not have changed")
+	}
+	if !reflect.DeepEqual(tagState.LocationHistory, currentTagState.LocationHistory) {
+		t.Error("tagState LocationHistory should not have changed")
+	}
+	if !IsIgnored(currentTagState, newTagEvent, "handheld") {
+		t.Error("expected the late event to be ignored")
+	}
+	if IsIgnored(tag.Tag{}, newTagEvent, "handheld") {
+		t.Error("expected an event of a new tag never to be ignored")
+	}
+}
+
+func TestUpdateTag_LateEventWithinTolerance(t *testing.T) {
+	lateReadPolicy := config.AppConfig.LateReadPolicy
+	tolerance := config.AppConfig.LateReadToleranceMillis
+	config.AppConfig.LateReadPolicy = config.LateReadIgnore
+	config.AppConfig.LateReadToleranceMillis = 1000
+	defer func() {
+		config.AppConfig.LateReadPolicy = lateReadPolicy
+		config.AppConfig.LateReadToleranceMillis = tolerance
+	}()
+
+	currentTagState := getHelperTag()
+
+	newTagEvent := getHelperTagEvent()
+	newTagEvent.EventType = MovedEvent
+	newTagEvent.Location = "Back"
+	newTagEvent.Timestamp = currentTagState.LastRead - 500
+
+	tagState := UpdateTag(currentTagState, newTagEvent, "handheld")
+
+	if tagState.LocationHistory[0].Location != newTagEvent.Location {
+		t.Errorf("Expected location %s, received %s", newTagEvent.Location, tagState.LocationHistory[0].Location)
+	}
+	// even within the tolerance, last read never moves backwards
+	if tagState.LastRead != currentTagState.LastRead {
+		t.Error("tagState LastRead should not have changed")
+	}
+}
+
 func TestIsTagWhitelisted_False(t *testing.T) {
 
 	tagEvent := getHelperTagEvent()
@@ -1259,7 +1329,11 @@ func TestGetBestLastRead(t *testing.T) {
 		t.Error("getBestLastRead failed to return currentLastRead")
 	}
 
+	lateReadPolicy := config.AppConfig.LateReadPolicy
+	defer func() { config.AppConfig.LateReadPolicy = lateReadPolicy }()
+
 	newSource = "handheld"
+	config.AppConfig.LateReadPolicy = config.LateReadApply
 	expected = newLastRead
 	actual = getBestLastRead(currentLastRead, newLastRead, currentSource, newSource)
 
@@ -1267,6 +1341,14 @@ func TestGetBestLastRead(t *testing.T) {
 		t.Error("getBestLastRead failed to return newLastRead")
 	}
 
+	config.AppConfig.LateReadPolicy = config.LateReadIgnore
+	expected = currentLastRead
+	actual = getBestLastRead(currentLastRead, newLastRead, currentSource, newSource)
+
+	if actual != expected {
+		t.Error("getBestLastRead failed to return currentLastRead")
+	}
+
 	newLastRead = int64(1516684239999)
 	newSource = "fixed"
 	expected = newLastRead
@@ -44,6 +44,15 @@ var (
 
 	// ErrEntityTooLarge occurs when the input data is invalid
 	ErrEntityTooLarge = errors.New("Request entity too large")
+
+	// ErrNotAcceptable occurs when the response cannot be produced in any of the accepted formats
+	ErrNotAcceptable = errors.New("Not acceptable")
+
+	// ErrPreconditionFailed occurs when the entity was modified since the version the request is based on
+	ErrPreconditionFailed = errors.New("Precondition failed")
+
+	// ErrConflict occurs when the entity to create already exists
+	ErrConflict = errors.New("Entity already exists")
 )
 
 // Error handles all error responses for the API.
@@ -74,6 +83,18 @@ func Error(ctx context.Context, writer http.ResponseWriter, err error) {
 	case ErrEntityTooLarge:
 		RespondError(ctx, writer, err, http.StatusRequestEntityTooLarge)
 		return
+
+	case ErrNotAcceptable:
+		RespondError(ctx, writer, err, http.StatusNotAcceptable)
+		return
+
+	case ErrPreconditionFailed:
+		RespondError(ctx, writer, err, http.StatusPreconditionFailed)
+		return
+
+	case ErrConflict:
+		RespondError(ctx, writer, err, http.StatusConflict)
+		return
 	}
 
 	// Handler server error
@@ -0,0 +1,147 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package productdata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// cache holds the product data maps fetched from the mapping SKU service, by url. A product data map is
+// refetched in the background once it is older than the TTL, while the previous map keeps being used, so that
+// callers never wait for the service once the map was fetched. If the service cannot be reached, the fetch is
+// retried after the retry interval.
+type cache struct {
+	sync.Mutex
+	entries map[string]*cacheEntry
+	// refreshing holds the channel closed once the fetch in flight of a url is done, so that a url is
+	// only fetched once at a time
+	refreshing map[string]chan struct{}
+	fetch      func(url string) (map[string]ProductMetadata, error)
+	now        func() time.Time
+}
+
+type cacheEntry struct {
+	productDataMap map[string]ProductMetadata
+	expires        time.Time
+}
+
+var productDataCache = newCache(CreateProductDataMap)
+
+func newCache(fetch func(url string) (map[string]ProductMetadata, error)) *cache {
+	return &cache{
+		entries:    make(map[string]*cacheEntry),
+		refreshing: make(map[string]chan struct{}),
+		fetch:      fetch,
+		now:        time.Now,
+	}
+}
+
+// CachedProductDataMap returns the product data map of the mapping SKU service at the url like
+// CreateProductDataMap, but only calls the service when the cached map expired, returning the expired map
+// meanwhile. Should the service be down before any map was fetched, the map is empty. Caching is disabled
+// when the configured TTL is 0.
+func CachedProductDataMap(url string) (map[string]ProductMetadata, error) {
+	return productDataCache.get(url,
+		time.Duration(config.AppConfig.ProductDataCacheTTLSeconds)*time.Second,
+		time.Duration(config.AppConfig.ProductDataCacheRetrySeconds)*time.Second)
+}
+
+// InvalidateCache expires the cached product data, so that it is fetched again on the next use.
+// The expired data is still used until it is fetched, or if the mapping SKU service cannot be reached.
+func InvalidateCache() {
+	productDataCache.invalidate()
+}
+
+func (c *cache) get(url string, ttl time.Duration, retry time.Duration) (map[string]ProductMetadata, error) {
+	mHit := metrics.GetOrRegisterMeter(`Inventory.ProductDataCache.Hit`, nil)
+	mMiss := metrics.GetOrRegisterMeter(`Inventory.ProductDataCache.Miss`, nil)
+	mStale := metrics.GetOrRegisterMeter(`Inventory.ProductDataCache.Stale`, nil)
+
+	if ttl <= 0 {
+		return c.fetch(url)
+	}
+
+	c.Lock()
+	entry, found := c.entries[url]
+	if found && c.now().Before(entry.expires) {
+		c.Unlock()
+		mHit.Mark(1)
+		return entry.productDataMap, nil
+	}
+	done := c.refresh(url, ttl, retry)
+	c.Unlock()
+
+	if found {
+		mStale.Mark(1)
+		return entry.productDataMap, nil
+	}
+
+	// nothing to use meanwhile, so wait for the fetch, which always caches a map
+	mMiss.Mark(1)
+	<-done
+	c.Lock()
+	defer c.Unlock()
+	return c.entries[url].productDataMap, nil
+}
+
+// refresh fetches the product data map of the url in the background, unless it is already being fetched,
+// and returns the channel closed once it is cached. It must be called with the lock held.
+func (c *cache) refresh(url string, ttl time.Duration, retry time.Duration) chan struct{} {
+	if done, found := c.refreshing[url]; found {
+		return done
+	}
+
+	mRefreshErr := metrics.GetOrRegisterGauge(`Inventory.ProductDataCache.Refresh-Error`, nil)
+	done := make(chan struct{})
+	c.refreshing[url] = done
+
+	go func() {
+		productDataMap, err := c.fetch(url)
+
+		c.Lock()
+		defer c.Unlock()
+		defer close(done)
+		delete(c.refreshing, url)
+
+		if err == nil {
+			c.entries[url] = &cacheEntry{productDataMap: productDataMap, expires: c.now().Add(ttl)}
+			return
+		}
+
+		mRefreshErr.Update(1)
+		entry, found := c.entries[url]
+		if found {
+			log.WithFields(log.Fields{
+				"Method": "CachedProductDataMap",
+				"Error":  err.Error(),
+			}).Warn("Unable to refresh product data, using the cached product data.")
+		} else {
+			log.WithFields(log.Fields{
+				"Method": "CachedProductDataMap",
+				"Error":  err.Error(),
+			}).Error("Unable to fetch product data, using no product data until it is fetched.")
+			entry = &cacheEntry{productDataMap: make(map[string]ProductMetadata)}
+			c.entries[url] = entry
+		}
+		entry.expires = c.now().Add(retry)
+	}()
+
+	return done
+}
+
+func (c *cache) invalidate() {
+	c.Lock()
+	defer c.Unlock()
+	for _, entry := range c.entries {
+		entry.expires = time.Time{}
+	}
+}
@@ -0,0 +1,147 @@
+/* Apache v2 license
+*  Copyright (C) <2019> Intel Corporation
+*
+*  SPDX-License-Identifier: Apache-2.0
+ */
+
+package productdata
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeService struct {
+	sync.Mutex
+	calls   int
+	down    bool
+	data    map[string]ProductMetadata
+	blocked chan struct{}
+}
+
+func (service *fakeService) fetch(url string) (map[string]ProductMetadata, error) {
+	if service.blocked != nil {
+		<-service.blocked
+	}
+	service.Lock()
+	defer service.Unlock()
+	service.calls++
+	if service.down {
+		return nil, errors.New("mapping SKU service down")
+	}
+	return service.data, nil
+}
+
+// waitForRefresh waits for the fetch in flight of the url, if any
+func waitForRefresh(c *cache, url string) {
+	c.Lock()
+	done, found := c.refreshing[url]
+	c.Unlock()
+	if found {
+		<-done
+	}
+}
+
+func TestCache(t *testing.T) {
+	service := &fakeService{data: map[string]ProductMetadata{"00111111": {ProductID: "00111111", DailyTurn: 0.1}}}
+	now := time.Unix(1573000000, 0)
+	c := newCache(service.fetch)
+	c.now = func() time.Time { return now }
+	ttl, retry := 5*time.Minute, 30*time.Second
+
+	get := func(expectedCalls int, expectedDailyTurn float64) {
+		t.Helper()
+		productDataMap, err := c.get("/skus", ttl, retry)
+		if err != nil {
+			t.Fatalf("Unable to get product data: %s", err)
+		}
+		waitForRefresh(c, "/skus")
+		if service.calls != expectedCalls {
+			t.Errorf("Expected %d calls to the service, got %d", expectedCalls, service.calls)
+		}
+		if productDataMap["00111111"].DailyTurn != expectedDailyTurn {
+			t.Errorf("Expected daily turn %v, got %v", expectedDailyTurn, productDataMap["00111111"].DailyTurn)
+		}
+	}
+
+	// miss, then hits until the ttl expires
+	get(1, 0.1)
+	now = now.Add(ttl - time.Second)
+	get(1, 0.1)
+
+	// the expired product data is used while it is refreshed
+	service.data = map[string]ProductMetadata{"00111111": {ProductID: "00111111", DailyTurn: 0.2}}
+	now = now.Add(time.Second)
+	get(2, 0.1)
+	get(2, 0.2)
+
+	// stale product data while the service is down, retried after the retry interval
+	service.down = true
+	now = now.Add(ttl)
+	get(3, 0.2)
+	get(3, 0.2)
+	now = now.Add(retry)
+	get(4, 0.2)
+
+	// invalidation refetches on the next use
+	service.down = false
+	service.data = map[string]ProductMetadata{"00111111": {ProductID: "00111111", DailyTurn: 0.3}}
+	c.invalidate()
+	get(5, 0.2)
+	get(5, 0.3)
+}
+
+func TestCacheRefreshesOnce(t *testing.T) {
+	service := &fakeService{data: map[string]ProductMetadata{"00111111": {ProductID: "00111111", DailyTurn: 0.1}}}
+	c := newCache(service.fetch)
+	if _, err := c.get("/skus", time.Minute, time.Second); err != nil {
+		t.Fatalf("Unable to get product data: %s", err)
+	}
+
+	// callers do not wait for a slow service, which is called once
+	service.blocked = make(chan struct{})
+	c.invalidate()
+	for i := 0; i < 3; i++ {
+		productDataMap, err := c.get("/skus", time.Minute, time.Second)
+		if err != nil || productDataMap["00111111"].DailyTurn != 0.1 {
+			t.Fatalf("Expected the stale product data, got %v: %v", productDataMap, err)
+		}
+	}
+	close(service.blocked)
+	waitForRefresh(c, "/skus")
+	if service.calls != 2 {
+		t.Errorf("Expected a single refresh, got %d calls", service.calls)
+	}
+}
+
+func TestCacheWithoutProductData(t *testing.T) {
+	service := &fakeService{down: true}
+	c := newCache(service.fetch)
+
+	for i := 0; i < 2; i++ {
+		productDataMap, err := c.get("/skus", time.Minute, time.Minute)
+		if err != nil || productDataMap == nil || len(productDataMap) != 0 {
+			t.Errorf("Expected no product data when the service is down and nothing is cached, got %v: %v",
+				productDataMap, err)
+		}
+	}
+	if service.calls != 1 {
+		t.Errorf("Expected the service to be called again only after the retry interval, got %d calls", service.calls)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	service := &fakeService{}
+	c := newCache(service.fetch)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.get("/skus", 0, time.Second); err != nil {
+			t.Fatalf("Unable to get product data: %s", err)
+		}
+	}
+	if service.calls != 3 {
+		t.Errorf("Expected every call to reach the service without ttl, got %d calls", service.calls)
+	}
+}
@@ -10,11 +10,14 @@ import (
 	"fmt"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/cloudconnector"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/config"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/qualifiedstate"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/routes/handlers"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/rules"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tag"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/app/tagevent"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/jsonrpc"
 	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/statemodel"
+	"github.com/intel/rsp-sw-toolkit-im-suite-inventory-service/pkg/web"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/go-metrics"
 	"github.com/intel/rsp-sw-toolkit-im-suite-utilities/helper"
 	"github.com/pkg/errors"
@@ -46,8 +49,7 @@ func (skuMapping SkuMapping) processTagData(invApp *inventoryApp, invEvent *json
 	mProcessTagLatency := metrics.GetOrRegisterTimer(`Inventory.ProcessTagData-Latency`, nil)
 	processTagTimer := time.Now()
 
-	var tagData []tag.Tag
-	var tagStateChangeList []tag.TagStateChange
+	mVersionConflict := metrics.GetOrRegisterGauge(`Inventory.ProcessTagData.Version-Conflict`, nil)
 
 	// todo: is below comment still valid?
 	// POC only implementation
@@ -59,46 +61,35 @@ func (skuMapping SkuMapping) processTagData(invApp *inventoryApp, invEvent *json
 	log.Debugf("Processing %d Tag Events", numberOfTags)
 	tagsFiltered := 0
 
-	for _, tempTag := range invEvent.Params.Data {
-		if len(config.AppConfig.EpcFilters) > 0 {
-			// ignore tags that don't match our filters
-			if !statemodel.IsTagWhitelisted(tempTag.EpcCode, config.AppConfig.EpcFilters) {
-				continue
-			}
+	// The tags are written back only if none was written since it was read, e.g. by a REST update of its
+	// qualified state, otherwise the event is applied again to the tags read again
+	var tagData []tag.Tag
+	var tagStateChangeList []tag.TagStateChange
+	for attempt := 0; ; attempt++ {
+		var tagEvents []tagevent.TagEvent
+		var err error
+		tagData, tagEvents, tagStateChangeList, err = updateTags(invApp, invEvent, source, currentTimeMillis)
+		if err != nil {
+			return err
 		}
-
-		// todo: is below comment still valid?
-		// POC only implementation
-		markDepartedIfUnseen(&tempTag, config.AppConfig.AgeOuts, currentTimeMillis)
-
-		// Add source & event
-		if source == "handheld" {
-			tempTag.EventType = statemodel.ArrivalEvent
+		if len(tagData) == 0 {
+			break
 		}
 
-		// Note: If bottlenecks may need to redesign to eliminate large number
-		// of queries to DB currently this will make a call to the DB PER tag
-		tagFromDB, err := tag.FindByEpc(invApp.masterDB, tempTag.EpcCode)
-
+		// the events are journaled in the transaction of the tags, so that a failed write journals nothing
+		journal, err := tagevent.Journal(invApp.masterDB, tagEvents)
 		if err != nil {
-			return errors.Wrap(err, "Error retrieving tag from database")
+			return errors.Wrap(err, "error journaling tag events")
 		}
-
-		updatedTag := statemodel.UpdateTag(tagFromDB, tempTag, source)
-
-		tagData = append(tagData, updatedTag)
-
-		var tagStateChange tag.TagStateChange
-		tagStateChange.PreviousState = tagFromDB
-		tagStateChange.CurrentState = updatedTag
-
-		if tagStateChange.PreviousState.IsEqual(tag.Tag{}) != true &&
-			tagStateChange.CurrentState.IsEqual(tag.Tag{}) != true {
-			tagStateChangeList = append(tagStateChangeList, tagStateChange)
+		err = tag.ReplaceIfUnchanged(invApp.masterDB, tagData, journal)
+		if err == nil {
+			break
 		}
-
-		log.Trace("Previous and Current Tag State:\n")
-		log.Trace(tagStateChange)
+		if errors.Cause(err) != web.ErrPreconditionFailed || attempt >= config.AppConfig.VersionConflictRetries {
+			return errors.Wrap(err, "error replacing tags")
+		}
+		mVersionConflict.Update(1)
+		log.Debugf("Retrying tag updates: %s", err)
 	}
 
 	log.Debugf("Filtered %d Tags.", tagsFiltered)
@@ -106,10 +97,6 @@ func (skuMapping SkuMapping) processTagData(invApp *inventoryApp, invEvent *json
 	// If at least 1 tag passed the whitelist, then insert
 	if len(tagData) > 0 {
 
-		if err := tag.Replace(invApp.masterDB, tagData); err != nil {
-			return errors.Wrap(err, "error replacing tags")
-		}
-
 		if err := handlers.ApplyConfidence(invApp.masterDB, tagData, skuMapping.url); err != nil {
 			return err
 		}
@@ -130,7 +117,16 @@ func (skuMapping SkuMapping) processTagData(invApp *inventoryApp, invEvent *json
 
 		if config.AppConfig.RulesUrl != "" {
 			go func() {
-				if err := rules.ApplyRules(source, tagStateChangeList); err != nil {
+				definitions, err := qualifiedstate.CreateDefinitionMap(invApp.masterDB)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"Method": "processTagData",
+						"Action": "Retrieve qualified state definitions",
+						"Error":  fmt.Sprintf("%+v", err),
+					}).Error(err)
+					return
+				}
+				if err := rules.ApplyRules(source, withoutBlockedAlerts(definitions, tagStateChangeList)); err != nil {
 					log.WithFields(log.Fields{
 						"Method": "processTagData",
 						"Action": "Apply Rules",
@@ -140,10 +136,96 @@ func (skuMapping SkuMapping) processTagData(invApp *inventoryApp, invEvent *json
 			}()
 		}
 
-		go invApp.pushEventsToCoreData(currentTimeMillis, invEvent.Params.ControllerId, tagData)
+		go func() {
+			if err := invApp.pushEventsToCoreData(currentTimeMillis, invEvent.Params.ControllerId, tagData); err != nil {
+				log.WithFields(log.Fields{
+					"Method": "processTagData",
+					"Action": "Publish Events to Core Data",
+					"Error":  fmt.Sprintf("%+v", err),
+				}).Error(err)
+			}
+		}()
 	}
 
 	mProcessTagLatency.Update(time.Since(processTagTimer))
 
 	return nil
 }
+
+// updateTags reads the tags of the inventory event from the database and applies the event to them, returning
+// the updated tags with their journal events and state changes
+func updateTags(invApp *inventoryApp, invEvent *jsonrpc.InventoryEvent, source string,
+	currentTimeMillis int64) ([]tag.Tag, []tagevent.TagEvent, []tag.TagStateChange, error) {
+
+	var tagData []tag.Tag
+	var tagStateChangeList []tag.TagStateChange
+	var tagEvents []tagevent.TagEvent
+
+	for _, tempTag := range invEvent.Params.Data {
+		if len(config.AppConfig.EpcFilters) > 0 {
+			// ignore tags that don't match our filters
+			if !statemodel.IsTagWhitelisted(tempTag.EpcCode, config.AppConfig.EpcFilters) {
+				continue
+			}
+		}
+
+		// todo: is below comment still valid?
+		// POC only implementation
+		markDepartedIfUnseen(&tempTag, config.AppConfig.AgeOuts, currentTimeMillis)
+
+		// Add source & event
+		if source == "handheld" {
+			tempTag.EventType = statemodel.ArrivalEvent
+		}
+
+		// Note: If bottlenecks may need to redesign to eliminate large number
+		// of queries to DB currently this will make a call to the DB PER tag
+		tagFromDB, err := tag.FindByEpc(invApp.masterDB, tempTag.EpcCode)
+
+		if err != nil {
+			return nil, nil, nil, errors.Wrap(err, "Error retrieving tag from database")
+		}
+
+		// A tag read again after being archived continues from its archived state
+		if tagFromDB.Epc == "" {
+			if tagFromDB, err = tag.Restore(invApp.masterDB, tempTag.EpcCode); err != nil {
+				return nil, nil, nil, errors.Wrap(err, "Error restoring archived tag")
+			}
+		}
+
+		ignored := statemodel.IsIgnored(tagFromDB, tempTag, source)
+		updatedTag := statemodel.UpdateTag(tagFromDB, tempTag, source)
+
+		tagData = append(tagData, updatedTag)
+		// an ignored event did not change the tag, so it is not journaled as a change
+		if !ignored {
+			tagEvents = append(tagEvents, tagevent.NewTagEvent(tempTag, source, tagFromDB, updatedTag, currentTimeMillis))
+		}
+
+		var tagStateChange tag.TagStateChange
+		tagStateChange.PreviousState = tagFromDB
+		tagStateChange.CurrentState = updatedTag
+
+		if tagStateChange.PreviousState.IsEqual(tag.Tag{}) != true &&
+			tagStateChange.CurrentState.IsEqual(tag.Tag{}) != true {
+			tagStateChangeList = append(tagStateChangeList, tagStateChange)
+		}
+
+		log.Trace("Previous and Current Tag State:\n")
+		log.Trace(tagStateChange)
+	}
+
+	return tagData, tagEvents, tagStateChangeList, nil
+}
+
+// withoutBlockedAlerts drops the state changes of tags whose qualified state blocks alerts
+func withoutBlockedAlerts(definitions qualifiedstate.Definitions, tagStateChangeList []tag.TagStateChange) []tag.TagStateChange {
+	var alerted []tag.TagStateChange
+	for _, tagStateChange := range tagStateChangeList {
+		current := tagStateChange.CurrentState
+		if !definitions.BlocksAlerts(current.FacilityID, current.QualifiedState) {
+			alerted = append(alerted, tagStateChange)
+		}
+	}
+	return alerted
+}